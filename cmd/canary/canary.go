@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/getsentry/sentry-go"
+	_ "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
+)
+
+// runs the scrappers against the reference handles of the sites, and records their health.
+// Reference handles are configured as CANARY_HANDLES = <site>:<handle>;<site>:<handle>
+// and are expected to have made submissions within the window
+
+func main() {
+	interval := flag.Duration("interval", 0, "time between the runs, the canary is run once if 0")
+	window := flag.Duration("window", 90*24*time.Hour, "period in which the reference handles have made submissions")
+	flag.Parse()
+
+	handles := map[string]string{}
+	for _, entry := range beego.AppConfig.Strings("CANARY_HANDLES") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 || !scrappers.IsSiteValid(parts[0]) {
+			log.Println("invalid canary handle:", entry)
+			continue
+		}
+		handles[parts[0]] = parts[1]
+	}
+	for {
+		run(handles, *window)
+		if *interval == 0 {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
+
+func run(handles map[string]string, window time.Duration) {
+	previous := map[string]types.PlatformHealth{}
+	if health, err := models.GetPlatformHealth(); err == nil {
+		for _, h := range health {
+			previous[h.Site] = h
+		}
+	}
+	for _, site := range scrappers.Sites() {
+		handle, ok := handles[site]
+		if !ok {
+			continue
+		}
+		report, err := scrappers.RunCanary(site, handle, time.Now().Add(-window), context.Background())
+		if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+		if !report.Healthy() {
+			log.Println(site, "canary failed:", report.Kind(), report.Err, report.Violations)
+			// Failures are reported once, till the site is healthy again
+			if h, ok := previous[site]; !ok || h.Healthy {
+				sentry.CurrentHub().CaptureMessage(fmt.Sprintf("%s scrapper is unhealthy: %s %v %v",
+					site, report.Kind(), report.Err, report.Violations))
+			}
+		}
+		if err := models.RecordCanaryReport(report); err != nil {
+			log.Println(err.Error())
+		}
+	}
+	sentry.Flush(5 * time.Second)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/scrappers"
+	"log"
+)
+
+// updates the problem catalog from the problems listed by the sites
+
+func main() {
+	for _, site := range scrappers.Sites() {
+		// Sites whose problems could not be listed are populated from the submissions only
+		if _, err := scrappers.NewProblemLister(site, context.Background()); err != nil {
+			continue
+		}
+		err := models.UpdateProblemCatalog(site, context.Background())
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	_ "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/services/lifecycle"
+	"github.com/mdg-iitr/Codephile/services/redis"
+	"github.com/mdg-iitr/Codephile/services/scheduler"
+	"github.com/mdg-iitr/Codephile/services/worker"
+)
+
+// performs the fetch jobs queued by the API instances, and runs the refresh scheduler.
+// Run along with the API instances in WORKER_MODE = standalone, so that the two are scaled apart.
+// Every instance runs MAX_WORKER_POOL workers, the one holding the scheduler lock queues the refreshes.
+// On SIGTERM, the jobs in flight are given SHUTDOWN_GRACE_PERIOD seconds to finish
+
+func main() {
+	// Handlers of the jobs are registered by the models
+	worker.Start()
+	scheduler.Start(models.RefreshStore{}, redis.GetRedisClient())
+	log.Println("performing the queued jobs")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	<-signals
+	// Jobs in flight are drained, those still running after the grace period are handed out again
+	log.Println("shutting down")
+	if !lifecycle.Shutdown() {
+		log.Println("jobs were cut off by the shutdown")
+	}
+	sentry.Flush(2 * time.Second)
+}
@@ -1,60 +1,61 @@
 package conf
 
 import (
-	"fmt"
-	"strings"
+	"time"
 )
 
+// Sites supported by codephile, their scrapper packages register the platforms
 const (
-	CODECHEF   = "codechef"
-	CODEFORCES = "codeforces"
-	HACKERRANK = "hackerrank"
-	SPOJ       = "spoj"
-	LEETCODE   = "leetcode"
+	CODECHEF    = "codechef"
+	CODEFORCES  = "codeforces"
+	HACKERRANK  = "hackerrank"
+	SPOJ        = "spoj"
+	LEETCODE    = "leetcode"
+	ATCODER     = "atcoder"
+	HACKEREARTH = "hackerearth"
 )
 
-var ValidSites = []string{HACKERRANK, CODECHEF, CODEFORCES, SPOJ, LEETCODE}
-
-func GetRegexSite(site string) string {
-	switch site {
-	case CODECHEF:
-		return "https://www.codechef.com"
-	case CODEFORCES:
-		return "http://codeforces.com"
-	case HACKERRANK:
-		return "https://www.hackerrank.com"
-	case SPOJ:
-		return "https://www.spoj.com"
-	case LEETCODE:
-		return "https://leetcode.com/"
-	}
-	return " "
-}
-
-func IsSiteValid(s string) bool {
-	for _, vs := range ValidSites {
-		if s == vs {
-			return true
-		}
-	}
-	return false
-}
-
-func GetSiteFromURL(url string) (string, error) {
-	for _, vs := range ValidSites {
-		if strings.Contains(url, vs) {
-			return vs, nil
-		}
-	}
-	return url, fmt.Errorf("unrecognised platform URL: %s", url)
-}
+const (
+	StatusCorrect               = "AC"
+	StatusWrongAnswer           = "WA"
+	StatusCompilationError      = "CE"
+	StatusRuntimeError          = "RE"
+	StatusTimeLimitExceeded     = "TLE"
+	StatusMemoryLimitExceeded   = "MLE"
+	StatusPartial               = "PTL"
+	StatusPresentationError     = "PE"
+	StatusIdlenessLimitExceeded = "ILE"
+	StatusOutputLimitExceeded   = "OLE"
+	StatusInternalError         = "IE"
+	// Accepted submission which was hacked later
+	StatusChallenged = "HCK"
+	StatusSkipped    = "SKP"
+	StatusRejected   = "REJ"
+	// Submission which is in queue or being judged
+	StatusPending = "PND"
+)
+
+// Metadata of problems is fetched again after this long, to pick up changed tags and ratings
+const ProblemMetadataTTL = 7 * 24 * time.Hour
+
+// Tokens issued to prove the ownership of handles are valid for this long
+const HandleVerificationTokenTTL = 24 * time.Hour
 
+// Uploaded submission histories larger than this are rejected, in bytes
+const MaxSubmissionImportSize = 10 << 20
+
+// Snapshots of the profile stats are kept for this long after the stats were last seen
+const ProfileSnapshotRetention = 2 * 365 * 24 * time.Hour
+
+// Statuses of the jobs are kept for this long after they are queued
+const JobStatusTTL = 7 * 24 * time.Hour
+
+// Handles are refreshed by the scheduler at intervals between these, shorter for the users
+// who are active on the site or on codephile lately
 const (
-	StatusCorrect             = "AC"
-	StatusWrongAnswer         = "WA"
-	StatusCompilationError    = "CE"
-	StatusRuntimeError        = "RE"
-	StatusTimeLimitExceeded   = "TLE"
-	StatusMemoryLimitExceeded = "MLE"
-	StatusPartial             = "PTL"
+	MinRefreshInterval = 10 * time.Minute
+	MaxRefreshInterval = 72 * time.Hour
 )
+
+// Users are marked as seen at most once in this long
+const LastSeenResolution = time.Hour
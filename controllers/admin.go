@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/astaxie/beego"
+	"github.com/getsentry/sentry-go"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/services/worker"
+)
+
+// Operations of codephile, authorized by the admin token instead of the users' tokens
+type AdminController struct {
+	beego.Controller
+}
+
+// @Title Platform Health
+// @Description Gives the health of the scrappers of the sites, as found by the canary runs against their reference handles
+// @Param	Authorization	header	string	true	"Bearer {admin token}"
+// @Success 200 {object} []types.PlatformHealth
+// @Failure 401 : Unauthorized
+// @Failure 500 server_error
+// @router /health [get]
+func (a *AdminController) GetHealth() {
+	health, err := models.GetPlatformHealth()
+	if err != nil {
+		hub := sentry.GetHubFromContext(a.Ctx.Request.Context())
+		hub.CaptureException(err)
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		a.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		a.ServeJSON()
+		return
+	}
+	a.Data["json"] = health
+	a.ServeJSON()
+}
+
+// @Title Platform Metrics
+// @Description Gives the health of the scrappers of the sites in the text format of prometheus
+// @Param	Authorization	header	string	true	"Bearer {admin token}"
+// @Success 200 {string} metrics
+// @Failure 401 : Unauthorized
+// @Failure 500 server_error
+// @router /metrics [get]
+func (a *AdminController) GetMetrics() {
+	health, err := models.GetPlatformHealth()
+	if err != nil {
+		hub := sentry.GetHubFromContext(a.Ctx.Request.Context())
+		hub.CaptureException(err)
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	a.Ctx.Output.Header("Content-Type", "text/plain; version=0.0.4")
+	_ = a.Ctx.Output.Body(healthMetrics(health))
+}
+
+// @Title Dead Jobs
+// @Description Gives the jobs which have exhausted their retries, latest first, along with the reason they failed
+// @Param	Authorization	header	string	true	"Bearer {admin token}"
+// @Success 200 {object} []worker.DeadJob
+// @Failure 401 : Unauthorized
+// @Failure 500 server_error
+// @router /jobs/dead [get]
+func (a *AdminController) GetDeadJobs() {
+	dead, err := worker.DeadJobs()
+	if err != nil {
+		hub := sentry.GetHubFromContext(a.Ctx.Request.Context())
+		hub.CaptureException(err)
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		a.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		a.ServeJSON()
+		return
+	}
+	a.Data["json"] = dead
+	a.ServeJSON()
+}
+
+// @Title Retry Dead Job
+// @Description Queues a dead-lettered job again, with its retries renewed
+// @Param	Authorization	header	string	true	"Bearer {admin token}"
+// @Param	id		path 	string	true		"ID of the job"
+// @Success 202 job queued
+// @Failure 401 : Unauthorized
+// @Failure 404 job not found
+// @Failure 500 server_error
+// @router /jobs/dead/:id/retry [post]
+func (a *AdminController) RetryDeadJob() {
+	err := worker.ReviveDeadJob(a.GetString(":id"))
+	if err == DeadJobNotFoundError {
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		a.Data["json"] = NotFoundError("Job not found")
+		a.ServeJSON()
+		return
+	} else if err != nil {
+		hub := sentry.GetHubFromContext(a.Ctx.Request.Context())
+		hub.CaptureException(err)
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		a.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		a.ServeJSON()
+		return
+	}
+	a.Ctx.ResponseWriter.WriteHeader(http.StatusAccepted)
+	a.Data["json"] = map[string]string{"status": "job queued"}
+	a.ServeJSON()
+}
+
+// @Title Purge Dead Job
+// @Description Removes a dead-lettered job
+// @Param	Authorization	header	string	true	"Bearer {admin token}"
+// @Param	id		path 	string	true		"ID of the job"
+// @Success 200 job removed
+// @Failure 401 : Unauthorized
+// @Failure 404 job not found
+// @Failure 500 server_error
+// @router /jobs/dead/:id [delete]
+func (a *AdminController) PurgeDeadJob() {
+	err := worker.PurgeDeadJobs(a.GetString(":id"))
+	if err == DeadJobNotFoundError {
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		a.Data["json"] = NotFoundError("Job not found")
+		a.ServeJSON()
+		return
+	} else if err != nil {
+		hub := sentry.GetHubFromContext(a.Ctx.Request.Context())
+		hub.CaptureException(err)
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		a.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		a.ServeJSON()
+		return
+	}
+	a.Data["json"] = map[string]string{"status": "job removed"}
+	a.ServeJSON()
+}
+
+// @Title Purge Dead Jobs
+// @Description Removes all the dead-lettered jobs
+// @Param	Authorization	header	string	true	"Bearer {admin token}"
+// @Success 200 jobs removed
+// @Failure 401 : Unauthorized
+// @Failure 500 server_error
+// @router /jobs/dead [delete]
+func (a *AdminController) PurgeDeadJobs() {
+	if err := worker.PurgeDeadJobs(); err != nil {
+		hub := sentry.GetHubFromContext(a.Ctx.Request.Context())
+		hub.CaptureException(err)
+		a.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		a.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		a.ServeJSON()
+		return
+	}
+	a.Data["json"] = map[string]string{"status": "jobs removed"}
+	a.ServeJSON()
+}
+
+// Metrics of the health of the sites, one sample for each site
+var platformMetrics = []struct {
+	name   string
+	kind   string
+	help   string
+	sample func(h types.PlatformHealth) float64
+}{
+	{"codephile_platform_healthy", "gauge", "Whether the last canary run of the scrapper passed", func(h types.PlatformHealth) float64 {
+		if h.Healthy {
+			return 1
+		}
+		return 0
+	}},
+	{"codephile_platform_last_run_timestamp_seconds", "gauge", "Time of the last canary run of the scrapper", func(h types.PlatformHealth) float64 {
+		return float64(h.LastRun.Unix())
+	}},
+	{"codephile_platform_last_success_timestamp_seconds", "gauge", "Time of the last canary run of the scrapper which passed, 0 if none did", func(h types.PlatformHealth) float64 {
+		if h.LastSuccess.IsZero() {
+			return 0
+		}
+		return float64(h.LastSuccess.Unix())
+	}},
+	{"codephile_platform_consecutive_failures", "gauge", "Canary runs of the scrapper which failed since the last one which passed", func(h types.PlatformHealth) float64 {
+		return float64(h.ConsecutiveFailures)
+	}},
+	{"codephile_platform_parse_failures_total", "counter", "Canary runs of the scrapper which could not parse the responses of the site", func(h types.PlatformHealth) float64 {
+		return float64(h.ParseFailures)
+	}},
+	{"codephile_platform_canary_runs_total", "counter", "Canary runs of the scrapper", func(h types.PlatformHealth) float64 {
+		return float64(h.Runs)
+	}},
+	{"codephile_platform_canary_duration_seconds", "gauge", "Time taken by the last canary run of the scrapper", func(h types.PlatformHealth) float64 {
+		return h.Duration
+	}},
+}
+
+func healthMetrics(health []types.PlatformHealth) []byte {
+	var buf bytes.Buffer
+	for _, metric := range platformMetrics {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", metric.name, metric.help, metric.name, metric.kind)
+		for _, h := range health {
+			fmt.Fprintf(&buf, "%s{site=%q} %s\n", metric.name, h.Site, strconv.FormatFloat(metric.sample(h), 'f', -1, 64))
+		}
+	}
+	return buf.Bytes()
+}
@@ -4,7 +4,7 @@ import (
 	// "encoding/json"
 	"github.com/astaxie/beego"
 	"github.com/getsentry/sentry-go"
-	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers"
 	"github.com/mdg-iitr/Codephile/errors"
 	"log"
 	"net/http"
@@ -50,7 +50,7 @@ func (u *ContestController) GetContests() {
 // @router /:site [get]
 func (u *ContestController) GetSpecificContests() {
 	site := u.GetString(":site")
-	if !IsSiteValid(site) {
+	if !scrappers.IsSiteValid(site) {
 		u.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
 		u.Data["json"] = errors.BadInputError("Invalid contest site")
 		u.ServeJSON()
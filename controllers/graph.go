@@ -5,9 +5,12 @@ import (
 
 	"github.com/astaxie/beego"
 	"github.com/getsentry/sentry-go"
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
 )
 
 type GraphController struct {
@@ -87,3 +90,100 @@ func (g *GraphController) GetStatusCounts() {
 	g.Data["json"] = status
 	g.ServeJSON()
 }
+
+// @Title Rating Graph
+// @Description Gives the contest rating history on a site for a user with given uid, (Logged-in user if uid is empty)
+// @Security token_auth read:user
+// @Param	site		path 	string	true		"site name"
+// @Param	uid		path 	string	false		"uid of user"
+// @Success 200 {object} types.RatingGraph
+// @Failure 401 : Unauthorized
+// @Failure 400 :uid or site is invalid
+// @Failure 404 user not found
+// @Failure 500 server_error
+// @router /rating/:site [get]
+// @router /rating/:site/:uid [get]
+func (g *GraphController) GetRatingGraph() {
+	site := g.GetString(":site")
+	if !scrappers.IsSiteValid(site) {
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		g.Data["json"] = BadInputError("Invalid contest site")
+		g.ServeJSON()
+		return
+	}
+	uidString := g.GetString(":uid")
+	var uid bson.ObjectId
+	if bson.IsObjectIdHex(uidString) {
+		uid = bson.ObjectIdHex(uidString)
+	} else if uidString == "" {
+		uid = g.Ctx.Input.GetData("uid").(bson.ObjectId)
+	} else {
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		g.Data["json"] = BadInputError("Invalid UID")
+		g.ServeJSON()
+		return
+	}
+	ratings, err := models.GetRatingGraph(uid, site)
+	if err == mgo.ErrNotFound {
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		g.Data["json"] = NotFoundError("User not found")
+		g.ServeJSON()
+		return
+	} else if err != nil {
+		hub := sentry.GetHubFromContext(g.Ctx.Request.Context())
+		hub.CaptureException(err)
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		g.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		g.ServeJSON()
+		return
+	}
+	if ratings == nil {
+		ratings = types.RatingGraph{}
+	}
+	g.Data["json"] = ratings
+	g.ServeJSON()
+}
+
+// @Title Profile Snapshots
+// @Description Gives the snapshots of the profile stats on a site for a user with given uid, oldest first (Logged-in user if uid is empty)
+// @Security token_auth read:user
+// @Param	site		path 	string	true		"site name"
+// @Param	uid		path 	string	false		"uid of user"
+// @Success 200 {object} []types.ProfileSnapshot
+// @Failure 401 : Unauthorized
+// @Failure 400 :uid or site is invalid
+// @Failure 500 server_error
+// @router /snapshots/:site [get]
+// @router /snapshots/:site/:uid [get]
+func (g *GraphController) GetProfileSnapshots() {
+	site := g.GetString(":site")
+	if !scrappers.IsSiteValid(site) {
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		g.Data["json"] = BadInputError("Invalid contest site")
+		g.ServeJSON()
+		return
+	}
+	uidString := g.GetString(":uid")
+	var uid bson.ObjectId
+	if bson.IsObjectIdHex(uidString) {
+		uid = bson.ObjectIdHex(uidString)
+	} else if uidString == "" {
+		uid = g.Ctx.Input.GetData("uid").(bson.ObjectId)
+	} else {
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		g.Data["json"] = BadInputError("Invalid UID")
+		g.ServeJSON()
+		return
+	}
+	snapshots, err := models.GetProfileSnapshots(uid, site)
+	if err != nil {
+		hub := sentry.GetHubFromContext(g.Ctx.Request.Context())
+		hub.CaptureException(err)
+		g.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		g.Data["json"] = InternalServerError("Server error.. Please report to admin")
+		g.ServeJSON()
+		return
+	}
+	g.Data["json"] = snapshots
+	g.ServeJSON()
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/astaxie/beego"
+	"github.com/getsentry/sentry-go"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models"
+)
+
+// Jobs queued on the requests of the users, eg. to fetch their submissions
+type JobController struct {
+	beego.Controller
+}
+
+// @Title Jobs
+// @Description Gives the status of the latest jobs queued by the logged-in user, latest first
+// @Security token_auth read:user
+// @Success 200 {object} []types.JobStatus
+// @Failure 401 Unauthenticated
+// @Failure 500 server_error
+// @router / [get]
+func (j *JobController) GetJobs() {
+	uid := j.Ctx.Input.GetData("uid").(bson.ObjectId)
+	jobs, err := models.GetJobs(uid)
+	if err != nil {
+		hub := sentry.GetHubFromContext(j.Ctx.Request.Context())
+		hub.CaptureException(err)
+		log.Println(err.Error())
+		j.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		j.Data["json"] = InternalServerError("Internal server error")
+		j.ServeJSON()
+		return
+	}
+	j.Data["json"] = jobs
+	j.ServeJSON()
+}
+
+// @Title Job
+// @Description Gives the status of a job queued by the logged-in user, along with its results or the reason it failed
+// @Security token_auth read:user
+// @Param	id		path 	string	true		"ID of the job"
+// @Success 200 {object} types.JobStatus
+// @Failure 401 Unauthenticated
+// @Failure 404 job not found
+// @Failure 500 server_error
+// @router /:id [get]
+func (j *JobController) GetJob() {
+	uid := j.Ctx.Input.GetData("uid").(bson.ObjectId)
+	job, err := models.GetJob(uid, j.GetString(":id"))
+	if err == mgo.ErrNotFound {
+		j.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		j.Data["json"] = NotFoundError("Job not found")
+		j.ServeJSON()
+		return
+	} else if err != nil {
+		hub := sentry.GetHubFromContext(j.Ctx.Request.Context())
+		hub.CaptureException(err)
+		log.Println(err.Error())
+		j.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		j.Data["json"] = InternalServerError("Internal server error")
+		j.ServeJSON()
+		return
+	}
+	j.Data["json"] = job
+	j.ServeJSON()
+}
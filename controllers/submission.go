@@ -8,6 +8,7 @@ import (
 	. "github.com/mdg-iitr/Codephile/conf"
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/scrappers"
 	"github.com/mdg-iitr/Codephile/services/worker"
 	"log"
 	"net/http"
@@ -117,22 +118,22 @@ func (s *SubmissionController) PaginatedSubmissions() {
 // @Description Triggers saving of user's submissions across a particular platform into database
 // @Security token_auth write:submission
 // @Param	site		path 	string	true		"Platform site name"
-// @Success 200 submission successfully saved
+// @Success 200 submission will be saved, by the job whose ID is returned
 // @Failure 400 site invalid
 // @Failure 503 Could not save submission, try later
 // @router /:site [post]
 func (s *SubmissionController) SaveSubmission() {
 	uid := s.Ctx.Input.GetData("uid").(bson.ObjectId)
 	site := s.GetString(":site")
-	if !IsSiteValid(site) {
+	if !scrappers.IsSiteValid(site) {
 		s.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
 		s.Data["json"] = BadInputError("Invalid contest site")
 		s.ServeJSON()
 		return
 	}
 
-	job := worker.NewJob(uid, site, models.AddSubmissions)
-	err := worker.Enqueue(job)
+	job := worker.NewJob(uid, site, worker.SubmissionsJob)
+	jobID, err := worker.Enqueue(job)
 	if err != nil {
 		s.Ctx.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
 		s.Data["json"] = UnavailableError("slow down cowboy")
@@ -140,7 +141,72 @@ func (s *SubmissionController) SaveSubmission() {
 		return
 	}
 
-	s.Data["json"] = map[string]string{"status": "submission will be saved in a moment"}
+	s.Data["json"] = map[string]string{"status": "submission will be saved in a moment", "job_id": jobID}
+	s.ServeJSON()
+}
+
+// @Title Import
+// @Description Merges the submission history uploaded by the user into the stored one. The history is either the export offered by the site (LeetCode submissions dump, Codeforces user.status JSON) or a CSV with name, url, status and created_at columns. Only the handles whose ownership is verified accept uploads
+// @Security token_auth write:submission
+// @Param	site		path 	string	true		"Platform site name"
+// @Param	file		formData 	file	true		"Submission history"
+// @Param	format		formData 	string	false		"export(default) or csv"
+// @Success 200 {object} map[string]int
+// @Failure 400 site invalid/export could not be read
+// @Failure 403 ownership of the handle not verified
+// @Failure 404 user/handle not found
+// @Failure 500 server_error
+// @router /import/:site [post]
+func (s *SubmissionController) ImportSubmissions() {
+	uid := s.Ctx.Input.GetData("uid").(bson.ObjectId)
+	site := s.GetString(":site")
+	if !scrappers.IsSiteValid(site) {
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		s.Data["json"] = BadInputError("Invalid contest site")
+		s.ServeJSON()
+		return
+	}
+	f, fh, err := s.GetFile("file")
+	if err != nil {
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		s.Data["json"] = BadInputError("could not get file")
+		s.ServeJSON()
+		return
+	}
+	defer f.Close()
+	if fh.Size > MaxSubmissionImportSize {
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		s.Data["json"] = BadInputError("file is too large")
+		s.ServeJSON()
+		return
+	}
+	format := s.GetString("format", scrappers.ExportFormatSite)
+	imported, err := models.ImportSubmissions(uid, site, format, f)
+	if IsImportError(err) {
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		s.Data["json"] = BadInputError(err.Error())
+		s.ServeJSON()
+		return
+	} else if err == HandleUnverifiedError {
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusForbidden)
+		s.Data["json"] = BadInputError("Verify the ownership of the handle before importing its submissions")
+		s.ServeJSON()
+		return
+	} else if err == UserNotFoundError || err == HandleNotFoundError {
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusNotFound)
+		s.Data["json"] = NotFoundError(err.Error())
+		s.ServeJSON()
+		return
+	} else if err != nil {
+		hub := sentry.GetHubFromContext(s.Ctx.Request.Context())
+		hub.CaptureException(err)
+		log.Println(err.Error())
+		s.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		s.Data["json"] = InternalServerError("Internal server error")
+		s.ServeJSON()
+		return
+	}
+	s.Data["json"] = map[string]int{"imported": imported}
 	s.ServeJSON()
 }
 
@@ -18,7 +18,6 @@ import (
 	"github.com/getsentry/sentry-go"
 	"github.com/globalsign/mgo/bson"
 	"github.com/gorilla/schema"
-	. "github.com/mdg-iitr/Codephile/conf"
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models"
 	"github.com/mdg-iitr/Codephile/models/types"
@@ -48,6 +47,8 @@ type UserController struct {
 // @Param	handle.hackerrank	formData	string 	false "Hackerrank Handle"
 // @Param	handle.spoj			formData	string 	false "Spoj Handle"
 // @Param	handle.leetcode		formData	string 	false "Leetcode Handle"
+// @Param	handle.atcoder		formData	string 	false "Atcoder Handle"
+// @Param	handle.hackerearth	formData	string 	false "Hackerearth Handle"
 // @Success 201 {int} types.User.Id
 // @Failure 409 username already exists
 // @Failure 400 bad request body or blank username/password/full name
@@ -182,6 +183,8 @@ func (u *UserController) Get() {
 // @Param	handle.hackerrank	formData	string 	false "New Hackerrank Handle"
 // @Param	handle.spoj			formData	string 	false "New Spoj Handle"
 // @Param	handle.leetcode		formData	string 	false "New Leetcode Handle"
+// @Param	handle.atcoder		formData	string 	false "New Atcoder Handle"
+// @Param	handle.hackerearth	formData	string 	false "New Hackerearth Handle"
 // @Success 202 {object} types.User
 // @Failure 409 username already exists
 // @Failure 400 bad request body
@@ -376,6 +379,13 @@ func (u *UserController) parseRequestBody() (types.User, error) {
 	} else {
 		decoder.IgnoreUnknownKeys(true)
 		err = decoder.Decode(&user, u.Ctx.Request.PostForm)
+		// Handles are posted as handle.<site>
+		user.Handle = make(types.Handle)
+		for _, site := range scrappers.Sites() {
+			if handle := u.Ctx.Request.PostForm.Get("handle." + site); handle != "" {
+				user.Handle[site] = handle
+			}
+		}
 	}
 	if err != nil {
 		log.Println(err.Error())
@@ -385,13 +395,15 @@ func (u *UserController) parseRequestBody() (types.User, error) {
 }
 
 // @Title Verify site handles
-// @Description verify user handles across different websites
+// @Description verify that the handles exist on different websites, their ownership is proven by /verify/:site/token
 // @Security token_auth read:user
 // @Param	site		path 	string	true		"site name"
 // @Param	handle		query 	string	true		"handle to verify"
 // @Success 200 {string} Handle valid
 // @Failure 400 invalid contest site
 // @Failure 403 incorrect handle
+// @Failure 429 rate limited by the site
+// @Failure 503 site unavailable
 // @router /verify/:site [get]
 func (u *UserController) Verify() {
 	handle := u.GetString("handle")
@@ -405,7 +417,12 @@ func (u *UserController) Verify() {
 	}
 	valid, err := scrapper.CheckHandle()
 	if err != nil {
-		u.Ctx.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		if ScrapeErrorKind(err) == RateLimited {
+			u.Ctx.ResponseWriter.WriteHeader(http.StatusTooManyRequests)
+		} else {
+			u.Ctx.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		}
+		u.Data["json"] = UnavailableError("Could not verify handle: " + err.Error())
 		u.ServeJSON()
 		return
 	}
@@ -417,11 +434,78 @@ func (u *UserController) Verify() {
 	u.ServeJSON()
 }
 
+// @Title Issue handle verification token
+// @Description Issues the token to be put in the name, bio or organization on the profile of the logged-in user's handle on the site (or in the source of a submission which fails to compile, on codeforces) to prove its ownership. The ownership is then checked by /verify/:site/confirm
+// @Security token_auth write:user
+// @Param	site		path 	string	true		"site name"
+// @Success 200 {object} types.HandleVerification
+// @Failure 400 invalid contest site or handle not set
+// @Failure 401 Unauthenticated
+// @Failure 500 server_error
+// @router /verify/:site/token [post]
+func (u *UserController) IssueVerificationToken() {
+	site := u.GetString(":site")
+	uid := u.Ctx.Input.GetData("uid").(bson.ObjectId)
+	if !scrappers.IsSiteValid(site) {
+		u.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		u.Data["json"] = BadInputError("Invalid contest site")
+		u.ServeJSON()
+		return
+	}
+	verification, err := models.IssueVerificationToken(uid, site)
+	if err == HandleNotFoundError {
+		u.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		u.Data["json"] = BadInputError("Handle of the site is not set")
+		u.ServeJSON()
+		return
+	} else if err != nil {
+		hub := sentry.GetHubFromContext(u.Ctx.Request.Context())
+		hub.CaptureException(err)
+		u.Ctx.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		u.Data["json"] = InternalServerError("Internal server error")
+		u.ServeJSON()
+		return
+	}
+	u.Data["json"] = verification
+	u.ServeJSON()
+}
+
+// @Title Confirm handle ownership
+// @Description Checks in a moment whether the token issued by /verify/:site/token is on the site, and marks the logged-in user's handle as verified if it is
+// @Security token_auth write:user
+// @Param	site		path 	string	true		"site name"
+// @Success 202 handle will be verified, by the job whose ID is returned
+// @Failure 400 invalid contest site
+// @Failure 401 Unauthenticated
+// @Failure 503 job queue full
+// @router /verify/:site/confirm [post]
+func (u *UserController) ConfirmHandle() {
+	site := u.GetString(":site")
+	uid := u.Ctx.Input.GetData("uid").(bson.ObjectId)
+	if !scrappers.IsSiteValid(site) {
+		u.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		u.Data["json"] = BadInputError("Invalid contest site")
+		u.ServeJSON()
+		return
+	}
+	job := worker.NewJob(uid, site, worker.VerifyHandleJob)
+	jobID, err := worker.Enqueue(job)
+	if err != nil {
+		u.Ctx.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+		u.Data["json"] = UnavailableError("slow down cowboy")
+		u.ServeJSON()
+		return
+	}
+	u.Ctx.ResponseWriter.WriteHeader(http.StatusAccepted)
+	u.Data["json"] = map[string]string{"status": "handle will be verified in a moment", "job_id": jobID}
+	u.ServeJSON()
+}
+
 // @Title Fetch User Info
 // @Description Fetches user info from different websites and store them into the database
 // @Security token_auth write:user
 // @Param	site		path 	string	true		"site name"
-// @Success 201 Success
+// @Success 201 data will be fetched, by the job whose ID is returned
 // @Failure 400 incorrect site or handle
 // @Failure 401 Unauthenticated
 // @Failure 500 server_error
@@ -429,14 +513,14 @@ func (u *UserController) Verify() {
 func (u *UserController) Fetch() {
 	site := u.GetString(":site")
 	uid := u.Ctx.Input.GetData("uid").(bson.ObjectId)
-	if !IsSiteValid(site) {
+	if !scrappers.IsSiteValid(site) {
 		u.Ctx.ResponseWriter.WriteHeader(http.StatusBadRequest)
 		u.Data["json"] = BadInputError("Invalid contest site")
 		u.ServeJSON()
 		return
 	}
-	job := worker.NewJob(uid, site, models.AddOrUpdateProfile)
-	err := worker.Enqueue(job)
+	job := worker.NewJob(uid, site, worker.ProfileJob)
+	jobID, err := worker.Enqueue(job)
 	if err != nil {
 		u.Ctx.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
 		u.Data["json"] = UnavailableError("slow down cowboy")
@@ -444,7 +528,7 @@ func (u *UserController) Fetch() {
 		return
 	}
 	u.Ctx.ResponseWriter.WriteHeader(http.StatusCreated)
-	u.Data["json"] = map[string]string{"status": "data will be fetched in a moment"}
+	u.Data["json"] = map[string]string{"status": "data will be fetched in a moment", "job_id": jobID}
 	u.ServeJSON()
 }
 
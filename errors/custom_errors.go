@@ -14,6 +14,68 @@ var HandleNotFoundError = errors.New("handle not available")
 
 var ErrJobQueueFull = errors.New("Job queue completely full")
 
+var DeadJobNotFoundError = errors.New("job not found among the dead-lettered ones")
+
 var FieldEmptyError = errors.New("empty field forbidden")
 
-var UserUnverifiedError = errors.New("E-mail not verified")
\ No newline at end of file
+var UserUnverifiedError = errors.New("E-mail not verified")
+
+var PlatformUnavailableError = errors.New("platform is unavailable, try again later")
+
+var VerificationTokenNotFoundError = errors.New("verification token not issued or expired")
+
+var HandleOwnershipUnprovenError = errors.New("verification token not found on the handle's profile")
+
+var HandleUnverifiedError = errors.New("ownership of the handle is not verified")
+
+// Reasons for which the data of a platform could not be scrapped
+const (
+	RateLimited         = "rate_limited"
+	HandleNotFound      = "handle_not_found"
+	PlatformUnavailable = "platform_unavailable"
+	ParseFailure        = "parse_failure"
+	// Site serves only the latest submissions, the older ones are to be uploaded by the user
+	SubmissionsTruncated = "submissions_truncated"
+)
+
+// ScrapeError is returned by the scrappers, Kind tells why the scrapping failed
+type ScrapeError struct {
+	Kind string
+	Site string
+	Err  error
+}
+
+func (e *ScrapeError) Error() string {
+	return e.Site + ": " + e.Kind + ": " + e.Err.Error()
+}
+
+func NewScrapeError(kind string, site string, err error) error {
+	return &ScrapeError{Kind: kind, Site: site, Err: err}
+}
+
+// ScrapeErrorKind returns the kind of a scrapper error, or an empty string for other errors
+func ScrapeErrorKind(err error) string {
+	if e, ok := err.(*ScrapeError); ok {
+		return e.Kind
+	}
+	return ""
+}
+
+// ImportError is returned for the uploaded exports of the submissions which could not be read
+type ImportError struct {
+	Site string
+	Err  error
+}
+
+func (e *ImportError) Error() string {
+	return e.Site + ": invalid export: " + e.Err.Error()
+}
+
+func NewImportError(site string, err error) error {
+	return &ImportError{Site: site, Err: err}
+}
+
+func IsImportError(err error) bool {
+	_, ok := err.(*ImportError)
+	return ok
+}
@@ -1,10 +1,23 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	_ "github.com/mdg-iitr/Codephile/conf"
 	"github.com/astaxie/beego"
 	_ "github.com/mdg-iitr/Codephile/routers"
+	"github.com/getsentry/sentry-go"
 	sentryhttp "github.com/getsentry/sentry-go/http"
+	"github.com/mdg-iitr/Codephile/models"
+	"github.com/mdg-iitr/Codephile/services/lifecycle"
+	"github.com/mdg-iitr/Codephile/services/redis"
+	"github.com/mdg-iitr/Codephile/services/scheduler"
+	"github.com/mdg-iitr/Codephile/services/worker"
 	
 )
 
@@ -13,8 +26,35 @@ func main() {
 		beego.BConfig.WebConfig.DirectoryIndex = true
 		beego.BConfig.WebConfig.StaticDir["/docs"] = "swagger"
 	}
+	switch mode := worker.Mode(); mode {
+	case worker.EmbeddedMode:
+		// Queued jobs are performed by the workers of every instance
+		worker.Start()
+		// Handles are refreshed in the background, by the instance holding the scheduler lock
+		scheduler.Start(models.RefreshStore{}, redis.GetRedisClient())
+	case worker.StandaloneMode:
+		// Jobs are only queued here, cmd/worker performs them and runs the scheduler
+	default:
+		log.Fatalln("invalid WORKER_MODE:", mode)
+	}
 	sentryHandler := sentryhttp.New(sentryhttp.Options{
 		Repanic: true,
 	})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		<-signals
+		// Requests being served are given a few seconds, the server returns once they are done
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := beego.BeeApp.Server.Shutdown(ctx); err != nil {
+			log.Println(err.Error())
+		}
+	}()
 	beego.RunWithMiddleWares("", sentryHandler.Handle)
+	// Jobs in flight are drained, those still running after the grace period are handed out again
+	if !lifecycle.Shutdown() {
+		log.Println("background work was cut off by the shutdown")
+	}
+	sentry.Flush(2 * time.Second)
 }
@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"os"
+	"strings"
+
+	"github.com/astaxie/beego/context"
+)
+
+//Checks if the request carries the admin token. Admin endpoints are disabled if the token is not set
+func AuthenticateAdmin(ctx *context.Context) {
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	requestToken := strings.TrimPrefix(ctx.Input.Header("Authorization"), "Bearer ")
+	if adminToken == "" || subtle.ConstantTimeCompare([]byte(requestToken), []byte(adminToken)) != 1 {
+		ctx.ResponseWriter.WriteHeader(401)
+		_, _ = ctx.ResponseWriter.Write([]byte("401 Unauthorized\n"))
+	}
+}
@@ -47,6 +47,7 @@ func Authenticate(ctx *context.Context) {
 			return
 		}
 		ctx.Input.SetData("uid", uid)
+		models.TouchLastSeen(uid)
 		if hub := sentry.GetHubFromContext(ctx.Request.Context()); hub != nil {
 			hub.ConfigureScope(func(scope *sentry.Scope) {
 				scope.SetUser(sentry.User{
@@ -13,6 +13,7 @@ import (
 
 	r "github.com/go-redis/redis"
 	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
 	"github.com/mdg-iitr/Codephile/services/redis"
 )
 
@@ -80,7 +81,7 @@ func fetchFromWeb() (types.Result, error) {
 	clistURL, _ := url.Parse("https://clist.by/api/v2/contest/")
 
 	values := clistURL.Query()
-	values.Set("host__regex", "codeforces.com|codechef.com|spoj.com|hackerrank.com|leetcode.com")
+	values.Set("host__regex", strings.Join(scrappers.Hosts(), "|"))
 	values.Set("end__gte", time.Now().Format(time.RFC3339))
 	values.Set("order_by", "start")
 	values.Set("total_count", "true")
@@ -106,7 +107,7 @@ func fetchFromWeb() (types.Result, error) {
 	if err != nil {
 		return types.Result{}, err
 	}
-	result, err := clistResult.ToResult()
+	result, err := clistResult.ToResult(scrappers.SiteOfHost)
 	if err != nil {
 		return types.Result{}, err
 	}
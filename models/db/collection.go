@@ -32,6 +32,30 @@ func NewUserCollectionSession() *Collection {
 	return NewCollectionSession("coduser")
 }
 
+func NewProblemMetadataCollectionSession() *Collection {
+	return NewCollectionSession("problemmetadata")
+}
+
+func NewProblemCollectionSession() *Collection {
+	return NewCollectionSession("problems")
+}
+
+func NewProfileSnapshotCollectionSession() *Collection {
+	return NewCollectionSession("profilesnapshots")
+}
+
+func NewPlatformHealthCollectionSession() *Collection {
+	return NewCollectionSession("platformhealth")
+}
+
+func NewJobCollectionSession() *Collection {
+	return NewCollectionSession("jobs")
+}
+
+func NewRefreshScheduleCollectionSession() *Collection {
+	return NewCollectionSession("refreshschedules")
+}
+
 func (c *Collection) Close() {
 	service.Close(c)
 }
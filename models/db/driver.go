@@ -24,6 +24,51 @@ var emailIndex = mgo.Index{
 	Background: true,
 }
 
+// Users who solved a problem are looked up by its catalog ID
+var submissionProblemIndex = mgo.Index{
+	Key:        []string{"submissions.problem_id"},
+	Background: true,
+}
+
+// Stale metadata of problems is removed, to be fetched again by the scrappers
+var problemMetadataTTLIndex = mgo.Index{
+	Key:         []string{"fetched_at"},
+	ExpireAfter: conf.ProblemMetadataTTL,
+	Background:  true,
+}
+
+// Snapshots are looked up by the user and site, oldest first
+var profileSnapshotIndex = mgo.Index{
+	Key:        []string{"uid", "site", "taken_at"},
+	Background: true,
+}
+
+// Snapshots superseded long ago are removed, the latest ones are seen on every profile update
+var profileSnapshotTTLIndex = mgo.Index{
+	Key:         []string{"seen_at"},
+	ExpireAfter: conf.ProfileSnapshotRetention,
+	Background:  true,
+}
+
+// Jobs of a user are listed latest first
+var jobIndex = mgo.Index{
+	Key:        []string{"uid", "-enqueued_at"},
+	Background: true,
+}
+
+// Statuses of the old jobs are removed
+var jobTTLIndex = mgo.Index{
+	Key:         []string{"enqueued_at"},
+	ExpireAfter: conf.JobStatusTTL,
+	Background:  true,
+}
+
+// Due refreshes are looked up by their time
+var refreshScheduleIndex = mgo.Index{
+	Key:        []string{"next_at"},
+	Background: true,
+}
+
 func init() {
 	var err error
 	maxPool, err = beego.AppConfig.Int("DBMaxPool")
@@ -46,12 +91,48 @@ func init() {
 		log.Println(err.Error())
 		sentry.CurrentHub().CaptureException(err)
 	}
+	err = c.Collection.EnsureIndex(submissionProblemIndex)
+	if err != nil {
+		log.Println(err.Error())
+		sentry.CurrentHub().CaptureException(err)
+	}
 	defer c.Close()
 	if err != nil {
 		sentry.CurrentHub().CaptureException(err)
 		log.Println(err.Error())
 	}
-
+	p := NewProblemMetadataCollectionSession()
+	defer p.Close()
+	err = p.Collection.EnsureIndex(problemMetadataTTLIndex)
+	if err != nil {
+		sentry.CurrentHub().CaptureException(err)
+		log.Println(err.Error())
+	}
+	s := NewProfileSnapshotCollectionSession()
+	defer s.Close()
+	for _, index := range []mgo.Index{profileSnapshotIndex, profileSnapshotTTLIndex} {
+		err = s.Collection.EnsureIndex(index)
+		if err != nil {
+			sentry.CurrentHub().CaptureException(err)
+			log.Println(err.Error())
+		}
+	}
+	j := NewJobCollectionSession()
+	defer j.Close()
+	for _, index := range []mgo.Index{jobIndex, jobTTLIndex} {
+		err = j.Collection.EnsureIndex(index)
+		if err != nil {
+			sentry.CurrentHub().CaptureException(err)
+			log.Println(err.Error())
+		}
+	}
+	r := NewRefreshScheduleCollectionSession()
+	defer r.Close()
+	err = r.Collection.EnsureIndex(refreshScheduleIndex)
+	if err != nil {
+		sentry.CurrentHub().CaptureException(err)
+		log.Println(err.Error())
+	}
 }
 
 func checkAndInitServiceConnection() {
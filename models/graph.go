@@ -52,6 +52,14 @@ func GetStatusCounts(uid bson.ObjectId) (types.StatusCounts, error) {
 			"tle_count": GetStatusQuery(conf.StatusTimeLimitExceeded),
 			"mle_count": GetStatusQuery(conf.StatusMemoryLimitExceeded),
 			"ptl_count": GetStatusQuery(conf.StatusPartial),
+			"pe_count":  GetStatusQuery(conf.StatusPresentationError),
+			"ile_count": GetStatusQuery(conf.StatusIdlenessLimitExceeded),
+			"ole_count": GetStatusQuery(conf.StatusOutputLimitExceeded),
+			"ie_count":  GetStatusQuery(conf.StatusInternalError),
+			"hck_count": GetStatusQuery(conf.StatusChallenged),
+			"skp_count": GetStatusQuery(conf.StatusSkipped),
+			"rej_count": GetStatusQuery(conf.StatusRejected),
+			"pnd_count": GetStatusQuery(conf.StatusPending),
 		}}
 	pipe := coll.Pipe([]bson.M{
 		match,
@@ -74,3 +82,22 @@ func GetStatusQuery(status string) bson.M {
 		},
 	}
 }
+
+// GetRatingGraph returns the contest rating history of the user on a site
+func GetRatingGraph(uid bson.ObjectId, site string) (types.RatingGraph, error) {
+	sess := db.NewUserCollectionSession()
+	defer sess.Close()
+	coll := sess.Collection
+
+	match := bson.M{"$match": bson.M{"_id": uid}}
+	project := bson.M{"$project": bson.M{"_id": 0, "ratings": "$ratings." + site}}
+	pipe := coll.Pipe([]bson.M{
+		match,
+		project,
+	})
+	var res struct {
+		Ratings types.RatingGraph `bson:"ratings"`
+	}
+	err := pipe.One(&res)
+	return res.Ratings, err
+}
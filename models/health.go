@@ -0,0 +1,53 @@
+package models
+
+import (
+	"github.com/globalsign/mgo/bson"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/db"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
+)
+
+// Records the outcome of a canary run in the health of the site
+func RecordCanaryReport(report scrappers.CanaryReport) error {
+	sess := db.NewPlatformHealthCollectionSession()
+	defer sess.Close()
+	coll := sess.Collection
+	violations := report.Violations
+	if violations == nil {
+		violations = []string{}
+	}
+	set := bson.M{
+		"handle":     report.Handle,
+		"healthy":    report.Healthy(),
+		"last_run":   report.At,
+		"error_kind": report.Kind(),
+		"error":      "",
+		"violations": violations,
+		"duration":   report.Duration.Seconds(),
+	}
+	inc := bson.M{"runs": 1}
+	if report.Err != nil {
+		set["error"] = report.Err.Error()
+	}
+	if report.Healthy() {
+		set["last_success"] = report.At
+		set["consecutive_failures"] = 0
+	} else {
+		inc["consecutive_failures"] = 1
+	}
+	if report.Kind() == ParseFailure {
+		inc["parse_failures"] = 1
+	}
+	_, err := coll.UpsertId(report.Site, bson.M{"$set": set, "$inc": inc})
+	return err
+}
+
+// Returns the health of the sites which are checked by the canary, ordered by site
+func GetPlatformHealth() ([]types.PlatformHealth, error) {
+	sess := db.NewPlatformHealthCollectionSession()
+	defer sess.Close()
+	health := []types.PlatformHealth{}
+	err := sess.Collection.Find(nil).Sort("_id").All(&health)
+	return health, err
+}
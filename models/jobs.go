@@ -0,0 +1,119 @@
+package models
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/globalsign/mgo/bson"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/db"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/services/redis"
+	"github.com/mdg-iitr/Codephile/services/worker"
+)
+
+func init() {
+	worker.RegisterHandler(worker.SubmissionsJob, AddSubmissions)
+	worker.RegisterHandler(worker.ProfileJob, AddOrUpdateProfile)
+	worker.RegisterHandler(worker.VerifyHandleJob, VerifyHandle)
+	worker.UseTracker(jobTracker{})
+	// Queued jobs survive restarts and are shared by the instances
+	worker.UseRedisQueue(redis.GetRedisClient())
+}
+
+// Queues the jobs fetching the submissions and profiles of the user on the sites, which are
+// listed along with the other jobs of the user. Jobs which could not be queued are reported,
+// the handles are refreshed by the scheduler anyway
+func queueFetches(uid bson.ObjectId, sites []string, ctx context.Context) {
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	for _, site := range sites {
+		for _, kind := range []string{worker.SubmissionsJob, worker.ProfileJob} {
+			if _, err := worker.Enqueue(worker.NewJob(uid, site, kind)); err != nil {
+				hub.CaptureException(err)
+				log.Println("unable to queue the job", err.Error())
+			}
+		}
+	}
+}
+
+// Jobs of a user listed at once
+const jobListLimit = 50
+
+// Records the status of the jobs in the database. Jobs could be started by a worker before
+// they are recorded as queued, so every change is an upsert which does not go back in state
+type jobTracker struct{}
+
+func jobFields(job worker.Job) bson.M {
+	return bson.M{"uid": job.User, "site": job.Site, "kind": job.Kind, "enqueued_at": job.EnqueuedAt}
+}
+
+func (jobTracker) Queued(job worker.Job) error {
+	sess := db.NewJobCollectionSession()
+	defer sess.Close()
+	fields := jobFields(job)
+	fields["state"] = types.JobQueued
+	_, err := sess.Collection.UpsertId(job.ID, bson.M{"$setOnInsert": fields})
+	return err
+}
+
+func (jobTracker) Started(job worker.Job) error {
+	sess := db.NewJobCollectionSession()
+	defer sess.Close()
+	_, err := sess.Collection.UpsertId(job.ID, bson.M{
+		"$setOnInsert": jobFields(job),
+		"$set":         bson.M{"state": types.JobRunning, "started_at": time.Now()},
+	})
+	return err
+}
+
+func (jobTracker) Finished(job worker.Job, counts map[string]int, jobErr error) error {
+	sess := db.NewJobCollectionSession()
+	defer sess.Close()
+	set := bson.M{"state": types.JobSucceeded, "finished_at": time.Now(), "counts": counts}
+	if jobErr != nil {
+		set["state"] = types.JobFailed
+		set["error"] = jobErr.Error()
+		set["error_kind"] = ScrapeErrorKind(jobErr)
+	}
+	_, err := sess.Collection.UpsertId(job.ID, bson.M{
+		"$setOnInsert": jobFields(job),
+		"$set":         set,
+		"$unset":       bson.M{"next_attempt_at": ""},
+	})
+	return err
+}
+
+func (jobTracker) Retrying(job worker.Job, jobErr error, at time.Time) error {
+	sess := db.NewJobCollectionSession()
+	defer sess.Close()
+	set := bson.M{"state": types.JobRetrying, "attempts": job.Attempt, "next_attempt_at": at}
+	if jobErr != nil {
+		set["error"] = jobErr.Error()
+		set["error_kind"] = ScrapeErrorKind(jobErr)
+	}
+	_, err := sess.Collection.UpsertId(job.ID, bson.M{"$setOnInsert": jobFields(job), "$set": set})
+	return err
+}
+
+// Returns the status of the job queued by the user, mgo.ErrNotFound if the user has no such job
+func GetJob(uid bson.ObjectId, id string) (types.JobStatus, error) {
+	sess := db.NewJobCollectionSession()
+	defer sess.Close()
+	var job types.JobStatus
+	err := sess.Collection.Find(bson.M{"_id": id, "uid": uid}).One(&job)
+	return job, err
+}
+
+// Returns the statuses of the latest jobs queued by the user
+func GetJobs(uid bson.ObjectId) ([]types.JobStatus, error) {
+	sess := db.NewJobCollectionSession()
+	defer sess.Close()
+	jobs := []types.JobStatus{}
+	err := sess.Collection.Find(bson.M{"uid": uid}).Sort("-enqueued_at").Limit(jobListLimit).All(&jobs)
+	return jobs, err
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/globalsign/mgo/bson"
+	"github.com/mdg-iitr/Codephile/models/db"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
+)
+
+// Fetches the problems of the site and adds them to the catalog
+func UpdateProblemCatalog(site string, ctx context.Context) error {
+	lister, err := scrappers.NewProblemLister(site, ctx)
+	if err != nil {
+		return err
+	}
+	problems, err := lister.GetProblems()
+	// Problems listed before the failure are still added
+	if addErr := AddProblems(problems); addErr != nil {
+		log.Println(addErr.Error())
+		return addErr
+	}
+	return err
+}
+
+// Adds the problems to the catalog, the known ones are updated with the given details
+func AddProblems(problems []types.Problem) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	sess := db.NewProblemCollectionSession()
+	defer sess.Close()
+	bulk := sess.Collection.Bulk()
+	bulk.Unordered()
+	for _, problem := range problems {
+		bulk.Upsert(bson.M{"_id": problem.ID}, bson.M{"$set": problemFields(problem)})
+	}
+	_, err := bulk.Run()
+	return err
+}
+
+// Adds the problems of the submissions which are not in the catalog yet. Details
+// of the known problems are left as they are, the catalog of the site is more complete
+func addSubmissionProblems(submissions []types.Submission) error {
+	sess := db.NewProblemCollectionSession()
+	defer sess.Close()
+	bulk := sess.Collection.Bulk()
+	bulk.Unordered()
+	added := make(map[string]bool)
+	for _, sub := range submissions {
+		if sub.ProblemID == "" || added[sub.ProblemID] {
+			continue
+		}
+		added[sub.ProblemID] = true
+		// ID is of the form <platform>/<contest>/<index>, or <platform>/<index>
+		parts := strings.Split(sub.ProblemID, "/")
+		problem := types.Problem{
+			ID:         sub.ProblemID,
+			Platform:   parts[0],
+			Index:      parts[len(parts)-1],
+			Name:       sub.Name,
+			URL:        sub.URL,
+			Tags:       sub.Tags,
+			Rating:     sub.Rating,
+			Difficulty: sub.Difficulty,
+		}
+		if len(parts) == 3 {
+			problem.Contest = parts[1]
+		}
+		bulk.Upsert(bson.M{"_id": problem.ID}, bson.M{"$setOnInsert": problemFields(problem)})
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	_, err := bulk.Run()
+	return err
+}
+
+// Returns the fields of the problem to be stored. Details missing from the source
+// are left out, so that the ones stored from the other sources are kept
+func problemFields(problem types.Problem) bson.M {
+	fields := bson.M{
+		"platform": problem.Platform,
+		"index":    problem.Index,
+		"name":     problem.Name,
+		"url":      problem.URL,
+	}
+	if problem.Contest != "" {
+		fields["contest"] = problem.Contest
+	}
+	if len(problem.Tags) != 0 {
+		fields["tags"] = problem.Tags
+	}
+	if problem.Rating != 0 {
+		fields["rating"] = problem.Rating
+	}
+	if problem.Difficulty != "" {
+		fields["difficulty"] = problem.Difficulty
+	}
+	return fields
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"github.com/globalsign/mgo"
+	"github.com/mdg-iitr/Codephile/models/db"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+func init() {
+	// Problems fetched by a scrapper are shared with every instance
+	common.UseProblemStore(problemMetadataStore{})
+}
+
+// problemMetadataStore keeps the metadata of problems in mongo
+type problemMetadataStore struct{}
+
+func (problemMetadataStore) Problem(url string) (types.ProblemMetadata, bool, error) {
+	sess := db.NewProblemMetadataCollectionSession()
+	defer sess.Close()
+	var problem types.ProblemMetadata
+	err := sess.Collection.FindId(url).One(&problem)
+	if err == mgo.ErrNotFound {
+		return problem, false, nil
+	}
+	return problem, err == nil, err
+}
+
+func (problemMetadataStore) SaveProblem(problem types.ProblemMetadata) error {
+	sess := db.NewProblemMetadataCollectionSession()
+	defer sess.Close()
+	_, err := sess.Collection.UpsertId(problem.URL, problem)
+	return err
+}
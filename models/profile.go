@@ -4,12 +4,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"time"
+
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	. "github.com/mdg-iitr/Codephile/conf"
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/db"
 	"github.com/mdg-iitr/Codephile/models/types"
 	"github.com/mdg-iitr/Codephile/scrappers"
+	"github.com/mdg-iitr/Codephile/services/worker"
 )
 
 func ResetProfile(uid bson.ObjectId, site string) error {
@@ -18,9 +23,20 @@ func ResetProfile(uid bson.ObjectId, site string) error {
 	coll := sess.Collection
 	newNode := "profiles." + site + "Profile"
 	userProfile := types.ProfileInfo{}
-	return coll.UpdateId(uid, bson.M{"$set": bson.M{newNode: userProfile}})
+	err := coll.UpdateId(uid, bson.M{"$set": bson.M{newNode: userProfile}, "$unset": bson.M{"ratings." + site: ""}})
+	if err != nil {
+		return err
+	}
+	// Snapshots of the previous handle are not of the user anymore
+	snapshots := db.NewProfileSnapshotCollectionSession()
+	defer snapshots.Close()
+	_, err = snapshots.Collection.RemoveAll(bson.M{"uid": uid, "site": site})
+	return err
 }
 
+// Fetches the profile and rating history of the site, and stores them in the database
+// along with a snapshot of the profile stats if they changed. The stored ones are kept if they could not be fetched.
+//Returns HandleNotFoundError/UserNotFoundError/ScrapeError/error
 func AddOrUpdateProfile(uid bson.ObjectId, site string, ctx context.Context) error {
 	sess := db.NewUserCollectionSession()
 	defer sess.Close()
@@ -31,34 +47,131 @@ func AddOrUpdateProfile(uid bson.ObjectId, site string, ctx context.Context) err
 		//handle the error (Invalid user)
 		return UserNotFoundError
 	}
-	handle := result["handle"].(map[string]interface{})[site].(string)
+	handle, _ := result["handle"].(map[string]interface{})[site].(string)
 	var userProfile types.ProfileInfo
 	//runs code to fetch the particular script's getProfile function
 	scrapper, err := scrappers.NewScrapper(site, handle, ctx)
 	if err != nil {
 		return err
 	}
-	userProfile = scrapper.GetProfileInfo()
-	accuracy, err := GetAccuracy(uid, site)
+	userProfile, err = scrapper.GetProfileInfo()
 	if err != nil {
-		userProfile.Accuracy = ""
+		log.Println(err.Error())
+		if recErr := recordSync(coll, uid, site, err); recErr != nil {
+			log.Println(recErr.Error())
+		}
+		return err
+	}
+	// Accuracy reported by the site covers the whole history, unlike the stored submissions
+	// which may lack the older ones. It is calculated only if the site did not report it
+	if userProfile.Accuracy == 0 {
+		if accuracy, err := GetAccuracy(uid, site); err == nil {
+			userProfile.Accuracy = accuracy
+		}
+	}
+	ratings, ratingErr := scrapper.GetRatingHistory()
+	if ratingErr != nil {
+		log.Println(ratingErr.Error())
 	} else {
-		userProfile.Accuracy = accuracy
+		fillStatsFromRatings(&userProfile.ProfileStats, ratings)
 	}
 
 	//Profile fetched. Store in database
 	newNode := "profiles." + site + "Profile"
-	return coll.UpdateId(uid, bson.M{"$set": bson.M{newNode: userProfile}})
+	update := bson.M{newNode: userProfile}
+	if ratingErr == nil && ratings != nil {
+		update["ratings."+site] = ratings
+	}
+	err = coll.UpdateId(uid, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+	if err := addProfileSnapshot(uid, site, userProfile.ProfileStats); err != nil {
+		log.Println(err.Error())
+	}
+	worker.Count(ctx, "ratings", len(ratings))
+	// Failure of the rating history is shown to the user, the profile is stored nonetheless
+	// so the job is not retried for it
+	if recErr := recordSync(coll, uid, site, ratingErr); recErr != nil {
+		log.Println(recErr.Error())
+	}
+	return nil
+}
+
+// Fills the stats which are not reported by the site from its rating history
+func fillStatsFromRatings(stats *types.ProfileStats, ratings types.RatingGraph) {
+	if len(ratings) == 0 {
+		return
+	}
+	latest := ratings[0]
+	maxRating := ratings[0].NewRating
+	for _, change := range ratings {
+		if change.CreationDate.After(latest.CreationDate) {
+			latest = change
+		}
+		if change.NewRating > maxRating {
+			maxRating = change.NewRating
+		}
+	}
+	if stats.Rating == 0 {
+		stats.Rating = latest.NewRating
+	}
+	if stats.MaxRating == 0 {
+		stats.MaxRating = maxRating
+	}
+	if stats.ContestsAttended == 0 {
+		stats.ContestsAttended = len(ratings)
+	}
+}
+
+// Takes a snapshot of the stats. If they are the same as in the latest one, it is marked
+// as seen instead, so that the latest snapshot is kept however long the stats stay the same
+func addProfileSnapshot(uid bson.ObjectId, site string, stats types.ProfileStats) error {
+	sess := db.NewProfileSnapshotCollectionSession()
+	defer sess.Close()
+	now := time.Now()
+	var latest types.ProfileSnapshot
+	err := sess.Collection.Find(bson.M{"uid": uid, "site": site}).Sort("-taken_at").One(&latest)
+	if err == nil && latest.ProfileStats == stats {
+		return sess.Collection.UpdateId(latest.ID, bson.M{"$set": bson.M{"seen_at": now}})
+	} else if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	return sess.Collection.Insert(types.ProfileSnapshot{
+		UserID:       uid,
+		Site:         site,
+		TakenAt:      now,
+		SeenAt:       now,
+		ProfileStats: stats,
+	})
+}
+
+// Returns the snapshots of the profile stats of the user on the site, oldest first
+func GetProfileSnapshots(uid bson.ObjectId, site string) ([]types.ProfileSnapshot, error) {
+	sess := db.NewProfileSnapshotCollectionSession()
+	defer sess.Close()
+	snapshots := []types.ProfileSnapshot{}
+	err := sess.Collection.Find(bson.M{"uid": uid, "site": site}).Sort("taken_at").All(&snapshots)
+	return snapshots, err
 }
 
 func GetProfiles(ID bson.ObjectId) (types.AllProfiles, error) {
 	coll := db.NewUserCollectionSession()
 	defer coll.Close()
 	user := types.User{}
-	err := coll.Collection.FindId(ID).Select(bson.M{"profiles": 1}).One(&user)
+	err := coll.Collection.FindId(ID).Select(bson.M{"profiles": 1, "verifiedhandles": 1}).One(&user)
+	markVerifiedProfiles(&user)
 	return user.Profiles, err
 }
 
+// Marks the profiles of the sites on which the user has proven the ownership of their handle
+func markVerifiedProfiles(user *types.User) {
+	for site, profile := range user.Profiles {
+		profile.Verified = user.VerifiedHandles[site]
+		user.Profiles[site] = profile
+	}
+}
+
 func CompareUser(uid1 bson.ObjectId, uid2 bson.ObjectId) (types.AllWorldRanks, error) {
 	collection := db.NewUserCollectionSession()
 	defer collection.Close()
@@ -66,32 +179,21 @@ func CompareUser(uid1 bson.ObjectId, uid2 bson.ObjectId) (types.AllWorldRanks, e
 	p1, err1 := GetProfiles(uid1)
 	p2, err2 := GetProfiles(uid2)
 	if err1 != nil || err2 != nil {
-		return types.AllWorldRanks{},
+		return nil,
 			fmt.Errorf("Could not get user: %s\n%s", err1, err2)
 	}
 
-	return types.AllWorldRanks{
-		CodechefWorldRanks: types.WorldRankComparison{
-			WorldRank1: p1.CodechefProfile.WorldRank,
-			WorldRank2: p2.CodechefProfile.WorldRank,
-		},
-		CodeforcesWorldRanks: types.WorldRankComparison{
-			WorldRank1: p1.CodeforcesProfile.WorldRank,
-			WorldRank2: p2.CodeforcesProfile.WorldRank,
-		},
-		HackerrankWorldRanks: types.WorldRankComparison{
-			WorldRank1: p1.HackerrankProfile.WorldRank,
-			WorldRank2: p2.HackerrankProfile.WorldRank,
-		},
-		SpojWorldRanks: types.WorldRankComparison{
-			WorldRank1: p1.SpojProfile.WorldRank,
-			WorldRank2: p2.SpojProfile.WorldRank,
-		},
-	}, nil
-
+	ranks := make(types.AllWorldRanks)
+	for _, site := range scrappers.Sites() {
+		ranks[site] = types.WorldRankComparison{
+			WorldRank1: p1[site].GlobalRank,
+			WorldRank2: p2[site].GlobalRank,
+		}
+	}
+	return ranks, nil
 }
 
-func getCorrectIncorrectCount(uid bson.ObjectId, websiteUrl string, correctSubmissionIdentifier string) (int, int, error) {
+func getCorrectIncorrectCount(uid bson.ObjectId, site string) (int, int, error) {
 	sess := db.NewUserCollectionSession()
 	defer sess.Close()
 	coll := sess.Collection
@@ -102,9 +204,7 @@ func getCorrectIncorrectCount(uid bson.ObjectId, websiteUrl string, correctSubmi
 		"$unwind": "$submissions",
 	}
 	match2 := bson.M{"$match": bson.M{
-		"submissions.url": bson.M{"$regex": bson.RegEx{
-			Pattern: "^" + websiteUrl,
-		}},
+		"submissions.url": bson.M{"$regex": sitePattern(site)},
 	}}
 	pipe := coll.Pipe([]bson.M{
 		match,
@@ -128,20 +228,14 @@ func getCorrectIncorrectCount(uid bson.ObjectId, websiteUrl string, correctSubmi
 }
 
 // GetAccuracy function calculates the accuracy of a particular site and returns it
-func GetAccuracy(uid bson.ObjectId, website string) (string, error) {
-	switch website {
-	case CODECHEF:
-		correct, total, err := getCorrectIncorrectCount(uid, "https://www.codechef.com/", "AC")
-		return fmt.Sprintf("%f", float64(correct)/float64(total)), err
-	case CODEFORCES:
-		correct, total, err := getCorrectIncorrectCount(uid, "http://codeforces.com/", "OK")
-		return fmt.Sprintf("%f", float64(correct)/float64(total)), err
-	case SPOJ:
-		correct, total, err := getCorrectIncorrectCount(uid, "https://www.spoj.com", "accepted")
-		return fmt.Sprintf("%f", float64(correct)/float64(total)), err
-	case HACKERRANK:
-		return "1", nil
-	default:
-		return "", errors.New("Invalid Website")
+func GetAccuracy(uid bson.ObjectId, website string) (float64, error) {
+	platform, ok := scrappers.GetPlatform(website)
+	if !ok {
+		return 0, errors.New("Invalid Website")
+	}
+	if platform.AcceptedOnly {
+		return 1, nil
 	}
+	correct, total, err := getCorrectIncorrectCount(uid, website)
+	return float64(correct) / float64(total), err
 }
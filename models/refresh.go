@@ -0,0 +1,122 @@
+package models
+
+import (
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models/db"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
+	"github.com/mdg-iitr/Codephile/services/redis"
+	"github.com/mdg-iitr/Codephile/services/scheduler"
+)
+
+// RefreshStore keeps the times the handles of the verified users are refreshed at
+type RefreshStore struct{}
+
+func refreshID(uid bson.ObjectId, site string) string {
+	return uid.Hex() + ":" + site
+}
+
+func (RefreshStore) Seed(span time.Duration) error {
+	users := db.NewUserCollectionSession()
+	defer users.Close()
+	sess := db.NewRefreshScheduleCollectionSession()
+	defer sess.Close()
+	iter := users.Collection.Find(bson.M{"verified": true}).Select(bson.M{"handle": 1}).Iter()
+	var user types.User
+	now := time.Now()
+	for iter.Next(&user) {
+		bulk := sess.Collection.Bulk()
+		bulk.Unordered()
+		for _, site := range scrappers.Sites() {
+			if user.Handle[site] == "" {
+				continue
+			}
+			next := now.Add(time.Duration(rand.Int63n(int64(span))))
+			bulk.Upsert(bson.M{"_id": refreshID(user.ID, site)}, bson.M{"$setOnInsert": types.RefreshSchedule{
+				ID:     refreshID(user.ID, site),
+				UserID: user.ID,
+				Site:   site,
+				NextAt: next,
+			}})
+		}
+		if _, err := bulk.Run(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+	return iter.Close()
+}
+
+// Refreshes of the handles which have been removed, or of the users who have been deleted,
+// are dropped instead of being returned
+func (RefreshStore) Due(now time.Time, limit int) ([]scheduler.Refresh, error) {
+	sess := db.NewRefreshScheduleCollectionSession()
+	defer sess.Close()
+	var schedules []types.RefreshSchedule
+	err := sess.Collection.Find(bson.M{"next_at": bson.M{"$lte": now}}).Sort("next_at").Limit(limit).All(&schedules)
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	ids := make([]bson.ObjectId, len(schedules))
+	for i, s := range schedules {
+		ids[i] = s.UserID
+	}
+	users := db.NewUserCollectionSession()
+	defer users.Close()
+	var found []types.User
+	err = users.Collection.Find(bson.M{"_id": bson.M{"$in": ids}, "verified": true}).
+		Select(bson.M{"handle": 1, "lastfetched": 1, "lastseen": 1, "syncfailures": 1}).All(&found)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[bson.ObjectId]types.User, len(found))
+	for _, u := range found {
+		byID[u.ID] = u
+	}
+	var due []scheduler.Refresh
+	for _, s := range schedules {
+		user, ok := byID[s.UserID]
+		if !ok || user.Handle[s.Site] == "" {
+			if err := sess.Collection.RemoveId(s.ID); err != nil {
+				log.Println(err.Error())
+			}
+			continue
+		}
+		due = append(due, scheduler.Refresh{
+			User:         s.UserID,
+			Site:         s.Site,
+			LastActivity: user.Last[s.Site],
+			LastSeen:     user.LastSeen,
+			FailureKind:  user.SyncFailures[s.Site].Kind,
+		})
+	}
+	return due, nil
+}
+
+func (RefreshStore) Reschedule(r scheduler.Refresh, next time.Time) error {
+	sess := db.NewRefreshScheduleCollectionSession()
+	defer sess.Close()
+	return sess.Collection.UpdateId(refreshID(r.User, r.Site), bson.M{"$set": bson.M{"next_at": next}})
+}
+
+// Marks the user as seen now, so that their handles are refreshed more often.
+// The database is written once in LastSeenResolution at most
+func TouchLastSeen(uid bson.ObjectId) {
+	marked, err := redis.GetRedisClient().SetNX("lastseen:"+uid.Hex(), 1, LastSeenResolution).Result()
+	if err != nil {
+		log.Println(err.Error())
+		return
+	}
+	if !marked {
+		return
+	}
+	sess := db.NewUserCollectionSession()
+	defer sess.Close()
+	if err := sess.Collection.UpdateId(uid, bson.M{"$set": bson.M{"lastseen": time.Now()}}); err != nil {
+		log.Println(err.Error())
+	}
+}
@@ -4,22 +4,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"regexp"
 	"time"
 
+	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 	. "github.com/mdg-iitr/Codephile/conf"
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/db"
 	"github.com/mdg-iitr/Codephile/models/types"
 	"github.com/mdg-iitr/Codephile/scrappers"
+	"github.com/mdg-iitr/Codephile/services/worker"
 )
 
 // Fetches Submissions which are made after the lastFetched time, and
-// adds that to the database.
-//Returns HandleNotFoundError/UserNotFoundError/error
+// adds that to the database. If the fetch fails, nothing is added so that
+// the missed submissions are fetched again by the next sync.
+// If the site serves only the latest submissions, the fetched ones are stored and a
+// SubmissionsTruncated ScrapeError is returned, which is also recorded as the sync failure.
+//Returns HandleNotFoundError/UserNotFoundError/ScrapeError/error
 func AddSubmissions(uid bson.ObjectId, site string, ctx context.Context) error {
-	if !IsSiteValid(site) {
+	if !scrappers.IsSiteValid(site) {
 		return errors.New("site invalid")
 	}
 	sess := db.NewUserCollectionSession()
@@ -32,16 +39,55 @@ func AddSubmissions(uid bson.ObjectId, site string, ctx context.Context) error {
 		return UserNotFoundError
 	}
 	var addSubmissions []types.Submission
-	lastFetched := result["lastfetched"].(map[string]interface{})[site].(time.Time)
-	handle := result["handle"].(map[string]interface{})[site].(string)
+	// Users registered before a site was supported do not have its fields
+	lastFetched, _ := result["lastfetched"].(map[string]interface{})[site].(time.Time)
+	handle, _ := result["handle"].(map[string]interface{})[site].(string)
 	scrapper, err := scrappers.NewScrapper(site, handle, ctx)
 	if err != nil {
 		return err
 	}
-	addSubmissions = scrapper.GetSubmissions(lastFetched)
+	addSubmissions, err = scrapper.GetSubmissions(lastFetched)
+	// Submissions missed by a site serving only the latest ones are never fetched, the ones
+	// fetched are stored all the same, and the user is told to upload the rest
+	var truncated error
+	if ScrapeErrorKind(err) == SubmissionsTruncated {
+		truncated, err = err, nil
+	}
+	if err != nil {
+		log.Println(err.Error())
+		if recErr := recordSync(coll, uid, site, err); recErr != nil {
+			log.Println(recErr.Error())
+		}
+		return err
+	}
+	// Stored submissions which were in queue are replaced by the fetched ones
+	err = coll.UpdateId(uid, bson.M{"$pull": bson.M{"submissions": bson.M{
+		"status":     StatusPending,
+		"created_at": bson.M{"$gt": lastFetched},
+		"url":        bson.M{"$regex": sitePattern(site)},
+	}}})
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
 	if len(addSubmissions) != 0 {
 		lastFetched = addSubmissions[0].CreationDate
 	}
+	// Submissions in queue are fetched again by the next sync, till they are judged
+	for _, sub := range addSubmissions {
+		if sub.Status == StatusPending && sub.CreationDate.Add(-time.Second).Before(lastFetched) {
+			lastFetched = sub.CreationDate.Add(-time.Second)
+		}
+	}
+	addSubmissions, err = dropStoredSubmissions(coll, uid, addSubmissions)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+	// Submissions are stored even if their problems could not be added to the catalog
+	if err := addSubmissionProblems(addSubmissions); err != nil {
+		log.Println(err.Error())
+	}
 
 	change := bson.M{
 		"$push": bson.M{
@@ -49,13 +95,140 @@ func AddSubmissions(uid bson.ObjectId, site string, ctx context.Context) error {
 				"$each": addSubmissions,
 				"$sort": bson.M{"created_at": -1},
 			}},
-		"$set": bson.M{"lastfetched." + site: lastFetched}}
+		"$set":   bson.M{"lastfetched." + site: lastFetched},
+		"$unset": bson.M{"syncfailures." + site: ""}}
+	if truncated != nil {
+		delete(change, "$unset")
+		change["$set"].(bson.M)["syncfailures."+site] = syncFailureOf(truncated)
+	}
 	err = coll.UpdateId(uid, change)
 	if err != nil {
 		log.Println(err.Error())
 		return err
 	}
-	return nil
+	worker.Count(ctx, "submissions", len(addSubmissions))
+	return truncated
+}
+
+// Merges the uploaded submission history of the user on the site into the stored one.
+// Histories are accepted only for the handles whose ownership is verified.
+// Submissions which are already stored, or still in queue, are skipped. The last fetched
+// time is left as is, so the submissions missed by the upload are fetched by the next sync.
+// Returns the number of submissions added
+//Returns HandleNotFoundError/HandleUnverifiedError/UserNotFoundError/ImportError/error
+func ImportSubmissions(uid bson.ObjectId, site string, format string, r io.Reader) (int, error) {
+	if !scrappers.IsSiteValid(site) {
+		return 0, errors.New("site invalid")
+	}
+	sess := db.NewUserCollectionSession()
+	defer sess.Close()
+	coll := sess.Collection
+	var user types.User
+	err := coll.FindId(uid).Select(bson.M{"handle": 1, "verifiedhandles": 1}).One(&user)
+	if err == mgo.ErrNotFound {
+		return 0, UserNotFoundError
+	} else if err != nil {
+		return 0, err
+	}
+	handle := user.Handle[site]
+	if handle == "" {
+		return 0, HandleNotFoundError
+	}
+	// Uploads could claim any solves, unlike the syncs
+	if !user.VerifiedHandles[site] {
+		return 0, HandleUnverifiedError
+	}
+	imported, err := scrappers.ParseSubmissionExport(site, handle, format, r)
+	if err != nil {
+		return 0, err
+	}
+	// Judged submissions replace the ones in queue only when fetched by a sync
+	var judged []types.Submission
+	for _, sub := range imported {
+		if sub.Status != StatusPending {
+			judged = append(judged, sub)
+		}
+	}
+	judged, err = dropStoredSubmissions(coll, uid, judged)
+	if err != nil {
+		log.Println(err.Error())
+		return 0, err
+	}
+	if len(judged) == 0 {
+		return 0, nil
+	}
+	// Problems of the uploaded submissions are not added to the catalog shared by all the users,
+	// they are added once fetched by a sync
+	err = coll.UpdateId(uid, bson.M{
+		"$push": bson.M{
+			"submissions": bson.M{
+				"$each": judged,
+				"$sort": bson.M{"created_at": -1},
+			}},
+	})
+	if err != nil {
+		log.Println(err.Error())
+		return 0, err
+	}
+	return len(judged), nil
+}
+
+// Drops the submissions which are already stored for the user, along with the repeated ones.
+// Submissions made in the second of the last fetch could be returned again by the scrappers
+func dropStoredSubmissions(coll *mgo.Collection, uid bson.ObjectId, submissions []types.Submission) ([]types.Submission, error) {
+	if len(submissions) == 0 {
+		return submissions, nil
+	}
+	oldest := submissions[0].CreationDate
+	for _, sub := range submissions {
+		if sub.CreationDate.Before(oldest) {
+			oldest = sub.CreationDate
+		}
+	}
+	pipe := coll.Pipe([]bson.M{
+		{"$match": bson.M{"_id": uid}},
+		{"$unwind": "$submissions"},
+		{"$match": bson.M{"submissions.created_at": bson.M{"$gte": oldest}}},
+		{"$replaceRoot": bson.M{"newRoot": "$submissions"}},
+	})
+	var stored []types.Submission
+	err := pipe.All(&stored)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(stored))
+	// Times of all the submissions, and of those lacking a permalink. A submission and its
+	// stored copy are matched by time if either of them lacks the permalink
+	timed := make(map[string]bool, len(stored))
+	timedOnly := make(map[string]bool)
+	mark := func(sub types.Submission) {
+		seen[sub.Key()] = true
+		timed[sub.TimeKey()] = true
+		if sub.SubmissionURL == "" {
+			timedOnly[sub.TimeKey()] = true
+		}
+	}
+	for _, sub := range stored {
+		mark(sub)
+	}
+	var fresh []types.Submission
+	for _, sub := range submissions {
+		if seen[sub.Key()] || timedOnly[sub.TimeKey()] || (sub.SubmissionURL == "" && timed[sub.TimeKey()]) {
+			continue
+		}
+		mark(sub)
+		fresh = append(fresh, sub)
+	}
+	return fresh, nil
+}
+
+// Matches the URLs of the problems of the site, and nothing if the site is unknown
+func sitePattern(site string) bson.RegEx {
+	platform, ok := scrappers.GetPlatform(site)
+	if !ok {
+		return bson.RegEx{Pattern: `[^\s\S]`}
+	}
+	return bson.RegEx{Pattern: "^" + regexp.QuoteMeta(platform.URL)}
 }
 
 func DeleteSubmissions(uid bson.ObjectId, site string) error {
@@ -68,8 +241,7 @@ func DeleteSubmissions(uid bson.ObjectId, site string) error {
 		"$pull": bson.M{
 			"submissions": bson.M{
 				"url": bson.M{
-					"$regex": bson.RegEx{
-						Pattern: "^" + GetRegexSite(site)},
+					"$regex": sitePattern(site),
 				}},
 		},
 		"$set": bson.M{"lastfetched." + site: resetTime},
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// Records the outcome of a sync with the site, so that the user could be told why it failed.
+// A successful sync clears the previous failure, a sync cut off by the shutdown is not recorded
+func recordSync(coll *mgo.Collection, uid bson.ObjectId, site string, syncErr error) error {
+	if syncErr == context.Canceled {
+		return nil
+	}
+	if syncErr == nil {
+		return coll.UpdateId(uid, bson.M{"$unset": bson.M{"syncfailures." + site: ""}})
+	}
+	return coll.UpdateId(uid, bson.M{"$set": bson.M{"syncfailures." + site: syncFailureOf(syncErr)}})
+}
+
+// Returns the failure of the sync to be stored for the error
+func syncFailureOf(syncErr error) types.SyncFailure {
+	return types.SyncFailure{
+		Kind:    ScrapeErrorKind(syncErr),
+		Message: syncErr.Error(),
+		At:      time.Now(),
+	}
+}
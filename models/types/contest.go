@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"strings"
 	"time"
-
-	"github.com/mdg-iitr/Codephile/conf"
 )
 
 type Contest struct {
@@ -75,12 +73,13 @@ func (c *ContestTime) UnmarshalJSON(b []byte) error {
 	return err
 }
 
-func (clistRes CListResult) ToResult() (Result, error) {
+// Converts the contests listed by clist, siteOfHost returns the site hosting a contest
+func (clistRes CListResult) ToResult(siteOfHost func(host string) (string, error)) (Result, error) {
 	var result Result
 	currTime := time.Now()
 	result.Timestamp = currTime.Format(time.RFC3339)
 	for _, c := range clistRes.Contests {
-		site, err := conf.GetSiteFromURL(c.Host)
+		site, err := siteOfHost(c.Host)
 		if err != nil {
 			return Result{}, err
 		}
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"encoding/json"
 	// "errors"
 	"github.com/globalsign/mgo/bson"
 	// "github.com/mdg-iitr/Codephile/models/db"
@@ -19,13 +20,17 @@ type FollowingUser struct {
 }
 
 type WorldRankComparison struct {
-	WorldRank1 string `bson:"rank1" json:"rank1"`
-	WorldRank2 string `bson:"rank2" json:"rank2"`
+	WorldRank1 int `bson:"rank1" json:"rank1"`
+	WorldRank2 int `bson:"rank2" json:"rank2"`
 }
 
-type AllWorldRanks struct {
-	CodechefWorldRanks   WorldRankComparison `bson:"codechef_ranks" json:"codechef_ranks"`
-	CodeforcesWorldRanks WorldRankComparison `bson:"codeforces_ranks" json:"codeforces_ranks"`
-	HackerrankWorldRanks WorldRankComparison `bson:"hackerrank_ranks" json:"hackerrank_ranks"`
-	SpojWorldRanks       WorldRankComparison `bson:"spoj_ranks" json:"spoj_ranks"`
+// World ranks of two users on each site, served under <site>_ranks
+type AllWorldRanks map[string]WorldRankComparison
+
+func (r AllWorldRanks) MarshalJSON() ([]byte, error) {
+	ranks := make(map[string]WorldRankComparison, len(r))
+	for site, comparison := range r {
+		ranks[site+"_ranks"] = comparison
+	}
+	return json.Marshal(ranks)
 }
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type ActivityGraph []SubmissionCount
 type SubmissionCount struct {
 	Correct   int    `json:"correct"`
@@ -8,11 +10,81 @@ type SubmissionCount struct {
 }
 
 type StatusCounts struct {
-	StatusCorrect             int `bson:"ac_count" json:"ac"`
-	StatusWrongAnswer         int `bson:"wa_count" json:"wa"`
-	StatusCompilationError    int `bson:"ce_count" json:"ce"`
-	StatusRuntimeError        int `bson:"re_count" json:"re"`
-	StatusTimeLimitExceeded   int `bson:"tle_count" json:"tle"`
-	StatusMemoryLimitExceeded int `bson:"mle_count" json:"mle"`
-	StatusPartial             int `bson:"ptl_count" json:"ptl"`
+	StatusCorrect               int `bson:"ac_count" json:"ac"`
+	StatusWrongAnswer           int `bson:"wa_count" json:"wa"`
+	StatusCompilationError      int `bson:"ce_count" json:"ce"`
+	StatusRuntimeError          int `bson:"re_count" json:"re"`
+	StatusTimeLimitExceeded     int `bson:"tle_count" json:"tle"`
+	StatusMemoryLimitExceeded   int `bson:"mle_count" json:"mle"`
+	StatusPartial               int `bson:"ptl_count" json:"ptl"`
+	StatusPresentationError     int `bson:"pe_count" json:"pe"`
+	StatusIdlenessLimitExceeded int `bson:"ile_count" json:"ile"`
+	StatusOutputLimitExceeded   int `bson:"ole_count" json:"ole"`
+	StatusInternalError         int `bson:"ie_count" json:"ie"`
+	StatusChallenged            int `bson:"hck_count" json:"hck"`
+	StatusSkipped               int `bson:"skp_count" json:"skp"`
+	StatusRejected              int `bson:"rej_count" json:"rej"`
+	StatusPending               int `bson:"pnd_count" json:"pnd"`
+}
+
+type RatingGraph []RatingChange
+type RatingChange struct {
+	ContestName  string    `json:"contest_name" bson:"contest_name"`
+	ContestURL   string    `json:"contest_url" bson:"contest_url"`
+	Rank         int       `json:"rank" bson:"rank"`
+	OldRating    int       `json:"old_rating" bson:"old_rating"`
+	NewRating    int       `json:"new_rating" bson:"new_rating"`
+	CreationDate time.Time `json:"created_at" bson:"created_at"`
+}
+
+// AllRatings stores the contest rating history of a user on each platform
+type AllRatings map[string]RatingGraph
+
+type CodeforcesRatings struct {
+	Status string                   `json:"status"`
+	Result []CodeforcesRatingChange `json:"result"`
+}
+
+type CodeforcesRatingChange struct {
+	ContestID               int    `json:"contestId"`
+	ContestName             string `json:"contestName"`
+	Rank                    int    `json:"rank"`
+	RatingUpdateTimeSeconds int64  `json:"ratingUpdateTimeSeconds"`
+	OldRating               int    `json:"oldRating"`
+	NewRating               int    `json:"newRating"`
+}
+
+type CodechefRating struct {
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Rating  string `json:"rating"`
+	Rank    string `json:"rank"`
+	EndDate string `json:"end_date"`
+}
+
+type AtcoderContestResult struct {
+	IsRated           bool      `json:"IsRated"`
+	Place             int       `json:"Place"`
+	OldRating         int       `json:"OldRating"`
+	NewRating         int       `json:"NewRating"`
+	ContestScreenName string    `json:"ContestScreenName"`
+	ContestName       string    `json:"ContestName"`
+	EndTime           time.Time `json:"EndTime"`
+}
+
+type LeetcodeContestHistory struct {
+	Data struct {
+		UserContestRankingHistory []LeetcodeContestRanking `json:"userContestRankingHistory"`
+	} `json:"data"`
+}
+
+type LeetcodeContestRanking struct {
+	Attended bool    `json:"attended"`
+	Rating   float64 `json:"rating"`
+	Ranking  int     `json:"ranking"`
+	Contest  struct {
+		Title     string `json:"title"`
+		TitleSlug string `json:"titleSlug"`
+		StartTime int64  `json:"startTime"`
+	} `json:"contest"`
 }
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"time"
+)
+
+// Health of the scrapper of a site, as found by the canary runs against its reference handle
+type PlatformHealth struct {
+	Site    string    `bson:"_id" json:"site"`
+	Handle  string    `bson:"handle" json:"handle"`
+	Healthy bool      `bson:"healthy" json:"healthy"`
+	LastRun time.Time `bson:"last_run" json:"last_run"`
+	// Zero if the canary has never passed
+	LastSuccess time.Time `bson:"last_success" json:"last_success"`
+	// Kind of the failure of the last run, empty if it passed
+	ErrorKind string `bson:"error_kind" json:"error_kind"`
+	Error     string `bson:"error" json:"error"`
+	// Invariants broken by the results of the last run
+	Violations          []string `bson:"violations" json:"violations"`
+	ConsecutiveFailures int      `bson:"consecutive_failures" json:"consecutive_failures"`
+	// Runs which failed on a parse failure, ever
+	ParseFailures int `bson:"parse_failures" json:"parse_failures"`
+	Runs          int `bson:"runs" json:"runs"`
+	// Seconds taken by the last run
+	Duration float64 `bson:"duration" json:"duration"`
+}
@@ -0,0 +1,46 @@
+package types
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+// States of the fetch jobs
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	// Failed attempt is followed by another after a backoff
+	JobRetrying  = "retrying"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Status of a job queued on the request of a user, eg. to fetch the submissions on a site
+type JobStatus struct {
+	ID     string        `bson:"_id" json:"id"`
+	UserID bson.ObjectId `bson:"uid" json:"-"`
+	Site   string        `bson:"site" json:"site"`
+	// Kind of the job, eg. submissions
+	Kind       string     `bson:"kind" json:"kind"`
+	State      string     `bson:"state" json:"state"`
+	EnqueuedAt time.Time  `bson:"enqueued_at" json:"enqueued_at"`
+	StartedAt  *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	// Failed attempts made before the current one
+	Attempts      int        `bson:"attempts,omitempty" json:"attempts,omitempty"`
+	NextAttemptAt *time.Time `bson:"next_attempt_at,omitempty" json:"next_attempt_at,omitempty"`
+	// Results of the job, eg. number of submissions added
+	Counts map[string]int `bson:"counts,omitempty" json:"counts,omitempty"`
+	// Kind of the scrapper failure, if the job failed on one
+	ErrorKind string `bson:"error_kind,omitempty" json:"error_kind,omitempty"`
+	Error     string `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// Time the handle of a user on a site is refreshed at next by the scheduler
+type RefreshSchedule struct {
+	ID     string        `bson:"_id"`
+	UserID bson.ObjectId `bson:"uid"`
+	Site   string        `bson:"site"`
+	NextAt time.Time     `bson:"next_at"`
+}
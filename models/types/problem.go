@@ -0,0 +1,51 @@
+package types
+
+// Problem of the catalog shared by all the platforms. The ID is made of the platform,
+// the contest and the index of the problem, so that it stays the same across syncs
+type Problem struct {
+	ID         string   `json:"id" bson:"_id"`
+	Platform   string   `json:"platform" bson:"platform"`
+	Contest    string   `json:"contest,omitempty" bson:"contest,omitempty"`
+	Index      string   `json:"index" bson:"index"`
+	Name       string   `json:"name" bson:"name"`
+	URL        string   `json:"url" bson:"url"`
+	Tags       []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	Rating     int      `json:"rating,omitempty" bson:"rating,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty" bson:"difficulty,omitempty"`
+}
+
+// Returns the ID of a problem in the catalog. Problems of the platforms
+// which identify them without a contest, like spoj, have an empty contest
+func ProblemID(platform string, contest string, index string) string {
+	if contest == "" {
+		return platform + "/" + index
+	}
+	return platform + "/" + contest + "/" + index
+}
+
+type CodeforcesProblems struct {
+	Status string `json:"status"`
+	Result struct {
+		Problems []struct {
+			ContestID int      `json:"contestId"`
+			Index     string   `json:"index"`
+			Name      string   `json:"name"`
+			Rating    int      `json:"rating"`
+			Tags      []string `json:"tags"`
+		} `json:"problems"`
+	} `json:"result"`
+}
+
+type CodechefProblems struct {
+	Status string `json:"status"`
+	Result struct {
+		Data struct {
+			Content []struct {
+				ProblemCode string `json:"problemCode"`
+				ProblemName string `json:"problemName"`
+			} `json:"content"`
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"data"`
+	} `json:"result"`
+}
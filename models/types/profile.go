@@ -2,52 +2,156 @@ package types
 
 import (
 	"encoding/json"
-	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
 )
 
+// Profile of the user on a site. Badges, certificates, tracks and contests are of the sites having them
 type ProfileInfo struct {
-	Name      string `bson:"name" json:"name" schema:"name"`
-	UserName  string `bson:"userName" json:"userName" schema:"userName"`
-	School    string `bson:"school" json:"school" schema:"school"`
-	WorldRank string `bson:"rank" json:"rank" schema:"rank"`
-	Accuracy  string `bson:"accuracy" json:"accuracy" schema:"accuracy"`
-}
-
-//create an allProfilesStruct
-type AllProfiles struct {
-	CodechefProfile   ProfileInfo `bson:"codechefProfile" json:"codechefProfile"`
-	CodeforcesProfile ProfileInfo `bson:"codeforcesProfile" json:"codeforcesProfile"`
-	HackerrankProfile ProfileInfo `bson:"hackerrankProfile" json:"hackerrankProfile"`
-	SpojProfile       ProfileInfo `bson:"spojProfile" json:"spojProfile"`
-	LeetcodeProfile   ProfileInfo `bson:"leetcodeProfile" json:"leetcodeProfile"`
-}
-
-//UnmarshalJSON implements the unmarshaler interface for CodeforcesProfileInfo
-func (data *ProfileInfo) UnmarshalJSON(b []byte) error {
-	var profile map[string]interface{}
-	err := json.Unmarshal(b, &profile)
-	if profile["status"] != "OK" {
-		return errors.New("Bad Request")
+	Name     string `bson:"name" json:"name" schema:"name"`
+	UserName string `bson:"userName" json:"userName" schema:"userName"`
+	School   string `bson:"school" json:"school" schema:"school"`
+	// Set while serving, from the verified handles of the user
+	Verified     bool `bson:"-" json:"verified" schema:"-"`
+	ProfileStats `bson:",inline" schema:"-"`
+	Badges       []Badge                `bson:"badges,omitempty" json:"badges,omitempty" schema:"-"`
+	Certificates []Certificate          `bson:"certificates,omitempty" json:"certificates,omitempty" schema:"-"`
+	Tracks       []TrackScore           `bson:"tracks,omitempty" json:"tracks,omitempty" schema:"-"`
+	Contests     []ContestParticipation `bson:"contests,omitempty" json:"contests,omitempty" schema:"-"`
+}
+
+// Fields of the profiles stored before the stats were typed, which are read if the typed ones are not stored
+type legacyProfile struct {
+	WorldRank interface{} `bson:"rank"`
+	Accuracy  interface{} `bson:"accuracy"`
+}
+
+func (p *ProfileInfo) SetBSON(raw bson.Raw) error {
+	// Alias does not have this method, so that the fields are decoded as usual
+	type profileInfo ProfileInfo
+	var profile profileInfo
+	if err := raw.Unmarshal(&profile); err != nil {
+		return err
+	}
+	var legacy legacyProfile
+	if err := raw.Unmarshal(&legacy); err != nil {
+		return err
+	}
+	// Rank was stored as scrapped, eg. " 1234"
+	if rank, ok := legacy.WorldRank.(string); ok && profile.GlobalRank == 0 {
+		profile.GlobalRank, _ = strconv.Atoi(strings.Trim(rank, " #"))
+	}
+	if accuracy, ok := legacy.Accuracy.(string); ok {
+		profile.Accuracy, _ = strconv.ParseFloat(strings.TrimSpace(accuracy), 64)
+	}
+	*p = ProfileInfo(profile)
+	return nil
+}
+
+// Standing of the user on a site. Stats not reported by a site are left zero
+type ProfileStats struct {
+	Rating           int     `bson:"rating" json:"rating"`
+	MaxRating        int     `bson:"max_rating" json:"max_rating"`
+	RankTitle        string  `bson:"rank_title" json:"rank_title"`
+	Stars            int     `bson:"stars" json:"stars"`
+	GlobalRank       int     `bson:"global_rank" json:"global_rank"`
+	CountryRank      int     `bson:"country_rank" json:"country_rank"`
+	ContestsAttended int     `bson:"contests_attended" json:"contests_attended"`
+	Accuracy         float64 `bson:"accuracy" json:"accuracy"`
+}
+
+// Stats of the user on a site, as they were when the profile was fetched
+type ProfileSnapshot struct {
+	ID      bson.ObjectId `bson:"_id,omitempty" json:"-"`
+	UserID  bson.ObjectId `bson:"uid" json:"-"`
+	Site    string        `bson:"site" json:"site"`
+	TakenAt time.Time     `bson:"taken_at" json:"taken_at"`
+	// Latest fetch of the profile which found the same stats, old snapshots expire by it
+	SeenAt       time.Time `bson:"seen_at" json:"-"`
+	ProfileStats `bson:",inline"`
+}
+
+// Badge earned on a track, eg. Problem Solving with 3 stars
+type Badge struct {
+	Name   string  `bson:"name" json:"name"`
+	Stars  int     `bson:"stars" json:"stars"`
+	Solved int     `bson:"solved" json:"solved"`
+	Points float64 `bson:"points" json:"points"`
+}
+
+// Certificate of a passed skill test
+type Certificate struct {
+	Name        string    `bson:"name" json:"name"`
+	Level       string    `bson:"level" json:"level"`
+	URL         string    `bson:"url" json:"url"`
+	CompletedAt time.Time `bson:"completed_at" json:"completed_at"`
+}
+
+// Practice score of the user on a track, eg. Algorithms
+type TrackScore struct {
+	Name  string  `bson:"name" json:"name"`
+	Score float64 `bson:"score" json:"score"`
+	Rank  int     `bson:"rank" json:"rank"`
+}
+
+// Standing of the user in a contest which is not rated
+type ContestParticipation struct {
+	Name  string  `bson:"name" json:"name"`
+	URL   string  `bson:"url" json:"url"`
+	Rank  int     `bson:"rank" json:"rank"`
+	Score float64 `bson:"score" json:"score"`
+}
+
+// Profiles of the user keyed by site. They are stored and served under <site>Profile
+type AllProfiles map[string]ProfileInfo
+
+const profileSuffix = "Profile"
+
+func (p AllProfiles) MarshalJSON() ([]byte, error) {
+	profiles := make(map[string]ProfileInfo, len(p))
+	for site, profile := range p {
+		profiles[site+profileSuffix] = profile
 	}
-	result := profile["result"].([]interface{})[0].(map[string]interface{})
-	if result["firstName"] != nil && result["lastName"] != nil {
-		data.Name = result["firstName"].(string) + result["lastName"].(string)
+	return json.Marshal(profiles)
+}
+
+func (p AllProfiles) GetBSON() (interface{}, error) {
+	// Profiles are set one by one in the stored document, so it is never null
+	profiles := make(bson.M, len(p))
+	for site, profile := range p {
+		profiles[site+profileSuffix] = profile
 	}
-	data.UserName = result["handle"].(string)
-	// data.JoinDate = time.Unix(int64(result["registrationTimeSeconds"].(float64)), 0)
-	if result["organization"] != nil {
-		data.School = result["organization"].(string)
+	return profiles, nil
+}
+
+func (p *AllProfiles) SetBSON(raw bson.Raw) error {
+	var profiles map[string]ProfileInfo
+	if err := raw.Unmarshal(&profiles); err != nil {
+		return err
+	}
+	*p = make(AllProfiles, len(profiles))
+	for key, profile := range profiles {
+		(*p)[strings.TrimSuffix(key, profileSuffix)] = profile
 	}
-	data.WorldRank = ""
-	return err
+	return nil
 }
 
-type SolvedProblemsCount struct {
-	Codechef   int `json:"codechef"`
-	Codeforces int `json:"codeforces"`
-	Hackerrank int `json:"hackerrank"`
-	Spoj       int `json:"spoj"`
-	Leetcode   int `json:"leetcode"`
+// Count of the problems solved on each site
+type SolvedProblemsCount map[string]int
+
+type CodeforcesProfileInfo struct {
+	Result []struct {
+		Handle       string `json:"handle"`
+		FirstName    string `json:"firstName"`
+		LastName     string `json:"lastName"`
+		Organization string `json:"organization"`
+		Rating       int    `json:"rating"`
+		MaxRating    int    `json:"maxRating"`
+		Rank         string `json:"rank"`
+	} `json:"result"`
 }
 
 type CodechefProfileInfo struct {
@@ -65,6 +169,8 @@ type ProfileInfoContent struct {
 	Fullname     string                 `json:"fullname"`
 	Rankings     map[string]interface{} `json:"rankings"`
 	Organization string                 `json:"organization"`
+	Ratings      map[string]interface{} `json:"ratings"`
+	Band         string                 `json:"band"`
 }
 
 type GraphQLResponse struct {
@@ -72,7 +178,8 @@ type GraphQLResponse struct {
 }
 
 type LeetcodeData struct {
-	MatchedUser LeetcodeMatchedUser
+	MatchedUser        *LeetcodeMatchedUser
+	UserContestRanking *LeetcodeUserContestRanking
 }
 
 type LeetcodeMatchedUser struct {
@@ -85,6 +192,17 @@ type LeetcodeProfile struct {
 	RealName string
 	School   string
 	Ranking  float64
+	AboutMe  string
+	Company  string
+}
+
+type LeetcodeUserContestRanking struct {
+	AttendedContestsCount int
+	Rating                float64
+	GlobalRanking         int
+	Badge                 *struct {
+		Name string
+	}
 }
 
 type LeetcodeSubmitStats struct {
@@ -95,3 +213,44 @@ type LeetcodeSubmitStats struct {
 type LeetcodeSubmission struct {
 	Submissions float64
 }
+
+type HackerrankBadges struct {
+	Models []struct {
+		BadgeName     string  `json:"badge_name"`
+		Stars         int     `json:"stars"`
+		Solved        int     `json:"solved"`
+		CurrentPoints float64 `json:"current_points"`
+	} `json:"models"`
+}
+
+type HackerrankCertificates struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Status      string    `json:"status"`
+			CompletedAt time.Time `json:"completed_at"`
+			Certificate struct {
+				Label string `json:"label"`
+				Level string `json:"level"`
+			} `json:"certificate"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+type HackerrankTrackScore struct {
+	Name     string `json:"name"`
+	Practice struct {
+		Score float64 `json:"score"`
+		Rank  int     `json:"rank"`
+	} `json:"practice"`
+}
+
+type HackerrankContests struct {
+	Models []struct {
+		ContestName string  `json:"contest_name"`
+		ContestSlug string  `json:"contest_slug"`
+		Rank        int     `json:"rank"`
+		Score       float64 `json:"score"`
+	} `json:"models"`
+	Total int `json:"total"`
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProfileBSON(t *testing.T) {
+	Convey("Subject: Stored profiles\n", t, func() {
+		Convey("Profiles stored before the stats were typed should be read", func() {
+			raw, _ := bson.Marshal(bson.M{"profiles": bson.M{
+				"spojProfile": bson.M{"name": "Alice", "rank": " 1234", "accuracy": "0.500000"},
+			}})
+			var user struct {
+				Profiles AllProfiles `bson:"profiles"`
+			}
+			So(bson.Unmarshal(raw, &user), ShouldBeNil)
+			So(user.Profiles["spoj"].Name, ShouldEqual, "Alice")
+			So(user.Profiles["spoj"].GlobalRank, ShouldEqual, 1234)
+			So(user.Profiles["spoj"].Accuracy, ShouldEqual, 0.5)
+		})
+		Convey("Typed stats should be read as stored", func() {
+			profile := ProfileInfo{Name: "Alice", ProfileStats: ProfileStats{GlobalRank: 42, Accuracy: 0.75}}
+			raw, _ := bson.Marshal(profile)
+			var read ProfileInfo
+			So(bson.Unmarshal(raw, &read), ShouldBeNil)
+			So(read.GlobalRank, ShouldEqual, 42)
+			So(read.Accuracy, ShouldEqual, 0.75)
+		})
+	})
+}
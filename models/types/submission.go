@@ -1,22 +1,48 @@
 package types
 
 import (
+	"encoding/json"
+	"strconv"
 	"time"
 )
 
+// Submission made on a platform. URL is the permalink of the problem and SubmissionURL
+// of the submitted code. ID is empty for the platforms not exposing it. ProblemID links
+// the submission to the problem in the catalog.
 type Submission struct {
-	Name         string    `json:"name" bson:"name"`
-	URL          string    `json:"url" bson:"url"`
-	CreationDate time.Time `json:"created_at" bson:"created_at"`
-	Status       string    `json:"status" bson:"status"`
-	Language     string    `json:"language" bson:"language"`
-	Points       int       `json:"points" bson:"points"`
-	Tags         []string  `json:"tags" bson:"tags"`
-	Rating       int       `json:"rating" bson:"rating"`
+	ID            string    `json:"submission_id,omitempty" bson:"submission_id,omitempty"`
+	ProblemID     string    `json:"problem_id,omitempty" bson:"problem_id,omitempty"`
+	Name          string    `json:"name" bson:"name"`
+	URL           string    `json:"url" bson:"url"`
+	SubmissionURL string    `json:"submission_url,omitempty" bson:"submission_url,omitempty"`
+	CreationDate  time.Time `json:"created_at" bson:"created_at"`
+	Status        string    `json:"status" bson:"status"`
+	Language      string    `json:"language" bson:"language"`
+	Points        int       `json:"points" bson:"points"`
+	Tags          []string  `json:"tags" bson:"tags"`
+	Rating        int       `json:"rating" bson:"rating"`
+	Difficulty    string    `json:"difficulty,omitempty" bson:"difficulty,omitempty"`
+}
+
+// Key identifies the submission across platforms. Submissions of the platforms
+// not exposing IDs are identified by the problem and the time of submission
+func (s Submission) Key() string {
+	if s.SubmissionURL != "" {
+		return s.SubmissionURL
+	}
+	return s.TimeKey()
+}
+
+// TimeKey identifies the submission by the problem and the time of submission. Submissions
+// stored before their permalinks were scrapped are identified by it alone
+func (s Submission) TimeKey() string {
+	return s.URL + "@" + strconv.FormatInt(s.CreationDate.Unix(), 10)
 }
 
 type HackerrankSubmisson struct {
-	Models []Submission `json:"models"`
+	Models   []Submission `json:"models"`
+	Cursor   string       `json:"cursor"`
+	LastPage bool         `json:"last_page"`
 }
 type CodeforcesSubmissions struct {
 	Status string                   `json:"status"`
@@ -43,8 +69,59 @@ type Data struct {
 type Result2 struct {
 	Data Data `json:"data"`
 }
+type AtcoderSubmission struct {
+	ID          int     `json:"id"`
+	EpochSecond int64   `json:"epoch_second"`
+	ProblemID   string  `json:"problem_id"`
+	ContestID   string  `json:"contest_id"`
+	Language    string  `json:"language"`
+	Point       float64 `json:"point"`
+	Result      string  `json:"result"`
+}
 type LeetcodeSubmissions struct {
-	Title     string `json:"name" bson:"name"`
-	URL       string `json:"url" bson:"url"`
-	TimeSTamp string `json:"timestamp" bson:"timestamp"`
+	Data struct {
+		RecentSubmissionList []LeetcodeRecentSubmission `json:"recentSubmissionList"`
+	} `json:"data"`
+}
+type LeetcodeRecentSubmission struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	TitleSlug     string `json:"titleSlug"`
+	Timestamp     string `json:"timestamp"`
+	StatusDisplay string `json:"statusDisplay"`
+	Lang          string `json:"lang"`
+}
+
+// Page of the submissions API of leetcode, the exports of the history are made of these
+type LeetcodeSubmissionDump struct {
+	SubmissionsDump []LeetcodeDumpedSubmission `json:"submissions_dump"`
+}
+type LeetcodeDumpedSubmission struct {
+	ID            json.Number `json:"id"`
+	Title         string      `json:"title"`
+	TitleSlug     string      `json:"title_slug"`
+	Timestamp     int64       `json:"timestamp"`
+	StatusDisplay string      `json:"status_display"`
+	Lang          string      `json:"lang"`
+}
+type LeetcodeQuestionResponse struct {
+	Data struct {
+		Question *LeetcodeQuestion `json:"question"`
+	} `json:"data"`
+}
+type LeetcodeQuestion struct {
+	Difficulty string `json:"difficulty"`
+	TopicTags  []struct {
+		Name string `json:"name"`
+	} `json:"topicTags"`
+}
+
+// Metadata of a problem shared by the submissions of every user, keyed by the problem URL
+type ProblemMetadata struct {
+	URL        string    `bson:"_id"`
+	Name       string    `bson:"name"`
+	Tags       []string  `bson:"tags"`
+	Rating     int       `bson:"rating,omitempty"`
+	Difficulty string    `bson:"difficulty,omitempty"`
+	FetchedAt  time.Time `bson:"fetched_at"`
 }
@@ -9,36 +9,51 @@ import (
 )
 
 type User struct {
-	ID                  bson.ObjectId         `bson:"_id" json:"id" schema:"-"`
-	Username            string                `bson:"username" json:"username" schema:"username"`
-	Email               string                `bson:"email" json:"email" schema:"email"`
-	FullName            string                `bson:"fullname" json:"fullname" schema:"fullname"`
-	Institute           string                `bson:"institute" json:"institute" schema:"institute"`
-	Password            string                `bson:"password" json:"-" schema:"password"`
-	Picture             string                `bson:"picture" json:"picture"`
-	Verified            bool                  `bson:"verified" schema:"-" json:"-"`
-	Handle              Handle                `bson:"handle" json:"handle" schema:"handle"`
-	Submissions         []Submission          `bson:"submissions" json:"recent_submissions" schema:"-"`
-	Profiles            AllProfiles           `json:"profiles" bson:"profiles" schema:"-"`
-	Last                LastFetchedSubmission `bson:"lastfetched" json:"-"`
-	FollowingUsers      []Following           `bson:"followingUsers" json:"-"`
-	NoOfFollowing       int                   `bson:"-" json:"no_of_following"`
-	SolvedProblemsCount SolvedProblemsCount   `json:"solved_problems_count"`
+	ID                  bson.ObjectId          `bson:"_id" json:"id" schema:"-"`
+	Username            string                 `bson:"username" json:"username" schema:"username"`
+	Email               string                 `bson:"email" json:"email" schema:"email"`
+	FullName            string                 `bson:"fullname" json:"fullname" schema:"fullname"`
+	Institute           string                 `bson:"institute" json:"institute" schema:"institute"`
+	Password            string                 `bson:"password" json:"-" schema:"password"`
+	Picture             string                 `bson:"picture" json:"picture"`
+	Verified            bool                   `bson:"verified" schema:"-" json:"-"`
+	Handle              Handle                 `bson:"handle" json:"handle" schema:"-"`
+	Submissions         []Submission           `bson:"submissions" json:"recent_submissions" schema:"-"`
+	Profiles            AllProfiles            `json:"profiles" bson:"profiles" schema:"-"`
+	Ratings             AllRatings             `json:"-" bson:"ratings" schema:"-"`
+	Last                LastFetchedSubmission  `bson:"lastfetched" json:"-"`
+	FollowingUsers      []Following            `bson:"followingUsers" json:"-"`
+	NoOfFollowing       int                    `bson:"-" json:"no_of_following"`
+	SolvedProblemsCount SolvedProblemsCount    `json:"solved_problems_count"`
+	SyncFailures        map[string]SyncFailure `bson:"syncfailures,omitempty" json:"sync_failures,omitempty" schema:"-"`
+	VerifiedHandles     VerifiedHandles        `bson:"verifiedhandles,omitempty" json:"verified_handles" schema:"-"`
+	LastSeen            time.Time              `bson:"lastseen,omitempty" json:"-" schema:"-"`
 }
-type LastFetchedSubmission struct {
-	Codechef   time.Time `bson:"codechef"`
-	Codeforces time.Time `bson:"codeforces"`
-	Hackerrank time.Time `bson:"hackerrank"`
-	Spoj       time.Time `bson:"spoj"`
-	Leetcode   time.Time `bson:"leetcode"`
+
+// Reason of the last failed sync with a site, cleared once a sync succeeds
+type SyncFailure struct {
+	Kind    string    `bson:"kind" json:"kind"`
+	Message string    `bson:"message" json:"message"`
+	At      time.Time `bson:"at" json:"at"`
 }
-type Handle struct {
-	Codeforces  string `bson:"codeforces" json:"codeforces" schema:"codeforces"`
-	Codechef    string `bson:"codechef" json:"codechef" schema:"codechef"`
-	Spoj        string `bson:"spoj" json:"spoj" schema:"spoj"`
-	Hackerrank  string `bson:"hackerrank" json:"hackerrank" schema:"hackerrank"`
-	Hackerearth string `bson:"hackerearth" json:"hackerearth" schema:"hackerearth"`
-	Leetcode    string `bson:"leetcode" json:"leetcode" schema:"leetcode"`
+
+// Time of the latest stored submission on each site
+type LastFetchedSubmission map[string]time.Time
+
+// Handles of the user keyed by site
+type Handle map[string]string
+
+// Sites on which the user has proven the ownership of their handle.
+// A site is removed once its handle is changed
+type VerifiedHandles map[string]bool
+
+// Token issued to the user to prove the ownership of their handle on a site,
+// by putting it on their profile on the site
+type HandleVerification struct {
+	Handle    string    `bson:"handle" json:"handle"`
+	Token     string    `bson:"token" json:"token"`
+	IssuedAt  time.Time `bson:"issued_at" json:"issued_at"`
+	ExpiresAt time.Time `bson:"expires_at" json:"expires_at"`
 }
 
 func (u *User) UnmarshalJSON(b []byte) error {
@@ -79,6 +94,8 @@ type SearchDoc struct {
 	Institute string        `json:"institute"`
 	Picture   string        `json:"picture"`
 	Handle    Handle        `json:"handle"`
+	// Sites on which the ownership of the handles is proven
+	VerifiedHandles VerifiedHandles `json:"verified_handles" bson:"verifiedhandles"`
 }
 
 type UpdatePassword struct {
@@ -21,30 +21,20 @@ import (
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/db"
 	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
 	"github.com/mdg-iitr/Codephile/services/redis"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	getCodechefSolvesQuery = bson.M{
-		"$size": bson.M{
-			"$filter": bson.M{
-				"input": "$submissions",
-				"as":    "sub",
-				"cond": bson.M{
-					"$and": []bson.M{
-						{
-							"$regexMatch": bson.M{
-								"input": "$$sub.url",
-								"regex": bson.RegEx{Pattern: "^" + "https://www.codechef.com"},
-							},
-						},
-						{"$eq": []string{"$$sub.status", StatusCorrect}}},
-				},
-			},
-		},
+	getFollowingCountQuery = bson.M{
+		"$size": "$followingUsers",
 	}
-	getCodeforcesSolvesQuery = bson.M{
+)
+
+// Counts the accepted submissions of the user on the site
+func getSolvesQuery(site string) bson.M {
+	return bson.M{
 		"$size": bson.M{
 			"$filter": bson.M{
 				"input": "$submissions",
@@ -54,7 +44,7 @@ var (
 						{
 							"$regexMatch": bson.M{
 								"input": "$$sub.url",
-								"regex": bson.RegEx{Pattern: "^" + "http://codeforces.com"},
+								"regex": sitePattern(site),
 							},
 						},
 						{"$eq": []string{"$$sub.status", StatusCorrect}}},
@@ -62,50 +52,41 @@ var (
 			},
 		},
 	}
-	getHackerrankSolvesQuery = bson.M{
-		"$size": bson.M{
-			"$filter": bson.M{
-				"input": "$submissions",
-				"as":    "sub",
-				"cond": bson.M{
-					"$and": []bson.M{
-						{
-							"$regexMatch": bson.M{
-								"input": "$$sub.url",
-								"regex": bson.RegEx{Pattern: "^" + "https://www.hackerrank.com"},
-							},
-						},
-						{"$eq": []string{"$$sub.status", StatusCorrect}}},
-				},
-			},
-		},
+}
+
+// Returns the count of following users and of the problems solved on each site
+func getCountsProjection() bson.M {
+	projection := bson.M{
+		"_id":       0,
+		"following": getFollowingCountQuery,
 	}
-	getSpojSolvesQuery = bson.M{
-		"$size": bson.M{
-			"$filter": bson.M{
-				"input": "$submissions",
-				"as":    "sub",
-				"cond": bson.M{
-					"$and": []bson.M{
-						{
-							"$regexMatch": bson.M{
-								"input": "$$sub.url",
-								"regex": bson.RegEx{Pattern: "^" + "https://www.spoj.com"},
-							},
-						},
-						{"$eq": []string{"$$sub.status", StatusCorrect}}},
-				},
-			},
-		},
+	for _, site := range scrappers.Sites() {
+		projection[site+"Solves"] = getSolvesQuery(site)
 	}
-	getFollowingCountQuery = bson.M{
-		"$size": "$followingUsers",
+	return projection
+}
+
+func solvedProblemsCount(counts map[string]int) types.SolvedProblemsCount {
+	solved := make(types.SolvedProblemsCount)
+	for _, site := range scrappers.Sites() {
+		solved[site] = counts[site+"Solves"]
 	}
-)
+	return solved
+}
 
 func AddUser(u types.User) (string, error) {
 	u.ID = bson.NewObjectId()
 	u.Verified = false
+	// Fields of every site are stored, so that the handles of unknown sites are dropped
+	handles := make(types.Handle)
+	u.Profiles = make(types.AllProfiles)
+	u.Last = make(types.LastFetchedSubmission)
+	for _, site := range scrappers.Sites() {
+		handles[site] = u.Handle[site]
+		u.Profiles[site] = types.ProfileInfo{}
+		u.Last[site] = time.Time{}
+	}
+	u.Handle = handles
 	defaultPic := beego.AppConfig.Strings("DEFAULT_PICS")
 	if len(defaultPic) > 0 {
 		u.Picture = firebase.URLFromName(defaultPic[rand.Intn(len(defaultPic))])
@@ -131,7 +112,7 @@ func GetUser(uid bson.ObjectId) (*types.User, error) {
 	collection := db.NewUserCollectionSession()
 	defer collection.Close()
 	err := collection.Collection.FindId(uid).Select(bson.M{"_id": 1, "username": 1, "email": 1,
-		"handle": 1, "lastfetched": 1, "profiles": 1,
+		"handle": 1, "lastfetched": 1, "profiles": 1, "syncfailures": 1, "verifiedhandles": 1,
 		"picture": 1, "fullname": 1, "institute": 1, "submissions": bson.M{"$slice": 5}}).One(&user)
 	//fmt.Println(err.Error())
 	if err != nil {
@@ -144,14 +125,8 @@ func GetUser(uid bson.ObjectId) (*types.User, error) {
 			},
 		},
 		{
-			"$project": bson.M{
-				"_id":              0,
-				"following":        getFollowingCountQuery,
-				"codechefSolves":   getCodechefSolvesQuery,
-				"codeforcesSolves": getCodeforcesSolvesQuery,
-				"hackerrankSolves": getHackerrankSolvesQuery,
-				"spojSolves":       getSpojSolvesQuery,
-			}},
+			"$project": getCountsProjection(),
+		},
 	})
 	var res map[string]int
 	err = pipe.One(&res)
@@ -159,12 +134,8 @@ func GetUser(uid bson.ObjectId) (*types.User, error) {
 		return nil, err
 	}
 	user.NoOfFollowing = res["following"]
-	user.SolvedProblemsCount = types.SolvedProblemsCount{
-		Codechef:   res["codechefSolves"],
-		Codeforces: res["codeforcesSolves"],
-		Hackerrank: res["hackerrankSolves"],
-		Spoj:       res["spojSolves"],
-	}
+	user.SolvedProblemsCount = solvedProblemsCount(res)
+	markVerifiedProfiles(&user)
 	return &user, nil
 }
 
@@ -173,21 +144,15 @@ func GetAllUsers() ([]types.User, error) {
 	collection := db.NewUserCollectionSession()
 	defer collection.Close()
 	err := collection.Collection.Find(nil).Select(bson.M{"_id": 1, "username": 1, "email": 1,
-		"handle": 1, "lastfetched": 1, "profiles": 1,
+		"handle": 1, "lastfetched": 1, "profiles": 1, "verifiedhandles": 1,
 		"picture": 1, "fullname": 1, "institute": 1, "submissions": bson.M{"$slice": 5}}).All(&users)
 	if err != nil {
 		return nil, err
 	}
 	pipe := collection.Collection.Pipe([]bson.M{
 		{
-			"$project": bson.M{
-				"_id":              0,
-				"following":        getFollowingCountQuery,
-				"codechefSolves":   getCodechefSolvesQuery,
-				"codeforcesSolves": getCodeforcesSolvesQuery,
-				"hackerrankSolves": getHackerrankSolvesQuery,
-				"spojSolves":       getSpojSolvesQuery,
-			}},
+			"$project": getCountsProjection(),
+		},
 	})
 	var res []map[string]int
 	err = pipe.All(&res)
@@ -195,13 +160,9 @@ func GetAllUsers() ([]types.User, error) {
 		return nil, err
 	}
 	for i := range users {
-		users[i].SolvedProblemsCount = types.SolvedProblemsCount{
-			Codechef:   res[i]["codechefSolves"],
-			Codeforces: res[i]["codeforcesSolves"],
-			Hackerrank: res[i]["hackerrankSolves"],
-			Spoj:       res[i]["spojSolves"],
-		}
+		users[i].SolvedProblemsCount = solvedProblemsCount(res[i])
 		users[i].NoOfFollowing = res[i]["following"]
+		markVerifiedProfiles(&users[i])
 	}
 	return users, nil
 }
@@ -234,35 +195,26 @@ func UpdateUser(uid bson.ObjectId, uu *types.User, ctx context.Context) (a *type
 	if uu.FullName != "" {
 		updateDoc["fullname"] = uu.FullName
 	}
-	if uu.Handle.Codechef != "" && uu.Handle.Codechef != newHandle.Codechef {
-		updateDoc["handle.codechef"] = uu.Handle.Codechef
-		newHandle.Codechef = uu.Handle.Codechef
-		UpdatedSites = append(UpdatedSites, CODECHEF)
-	}
-	if uu.Handle.Codeforces != "" && uu.Handle.Codeforces != newHandle.Codeforces {
-		updateDoc["handle.codeforces"] = uu.Handle.Codeforces
-		newHandle.Codeforces = uu.Handle.Codeforces
-		UpdatedSites = append(UpdatedSites, CODEFORCES)
-	}
-	if uu.Handle.Hackerearth != "" {
-		updateDoc["handle.hackerearth"] = uu.Handle.Hackerearth
-		newHandle.Hackerearth = uu.Handle.Hackerearth
-		// UpdatedSites = append(UpdatedSites, HACKEREARTH)
-	}
-	if uu.Handle.Hackerrank != "" && uu.Handle.Hackerrank != newHandle.Hackerrank {
-		updateDoc["handle.hackerrank"] = uu.Handle.Hackerrank
-		newHandle.Hackerrank = uu.Handle.Hackerrank
-		UpdatedSites = append(UpdatedSites, HACKERRANK)
-	}
-	if uu.Handle.Spoj != "" && uu.Handle.Spoj != newHandle.Spoj {
-		updateDoc["handle.spoj"] = uu.Handle.Spoj
-		newHandle.Spoj = uu.Handle.Spoj
-		UpdatedSites = append(UpdatedSites, SPOJ)
+	for _, site := range scrappers.Sites() {
+		if uu.Handle[site] != "" && uu.Handle[site] != newHandle[site] {
+			updateDoc["handle."+site] = uu.Handle[site]
+			UpdatedSites = append(UpdatedSites, site)
+		}
 	}
 	if len(updateDoc) != 0 {
 		collection := db.NewUserCollectionSession()
 		defer collection.Close()
-		err = collection.Collection.UpdateId(uid, bson.M{"$set": updateDoc})
+		change := bson.M{"$set": updateDoc}
+		// Ownership of the previous handles does not carry over to the new ones
+		if len(UpdatedSites) != 0 {
+			unsetDoc := bson.M{}
+			for _, site := range UpdatedSites {
+				unsetDoc["verifiedhandles."+site] = ""
+				unsetDoc["handleverification."+site] = ""
+			}
+			change["$unset"] = unsetDoc
+		}
+		err = collection.Collection.UpdateId(uid, change)
 		if err == mgo.ErrNotFound {
 			return nil, UserNotFoundError
 		} else if err != nil {
@@ -271,27 +223,20 @@ func UpdateUser(uid bson.ObjectId, uu *types.User, ctx context.Context) (a *type
 		}
 	}
 
-	go func() {
-		for _, value := range UpdatedSites {
-			hub := sentry.GetHubFromContext(ctx)
-			err = DeleteSubmissions(uid, value)
-			if err != nil {
-				hub.CaptureException(err)
-			}
-			err = ResetProfile(uid, value)
-			if err != nil {
-				hub.CaptureException(err)
-			}
-			err = AddSubmissions(uid, value, ctx)
-			if err != nil {
-				hub.CaptureException(err)
-			}
-			err = AddOrUpdateProfile(uid, value, ctx)
-			if err != nil {
-				hub.CaptureException(err)
-			}
+	// Data of the previous handles is dropped, that of the new ones is fetched by the workers
+	hub := sentry.GetHubFromContext(ctx)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	for _, value := range UpdatedSites {
+		if err := DeleteSubmissions(uid, value); err != nil {
+			hub.CaptureException(err)
+		}
+		if err := ResetProfile(uid, value); err != nil {
+			hub.CaptureException(err)
 		}
-	}()
+	}
+	queueFetches(uid, UpdatedSites, ctx)
 
 	u, err := GetUser(uid)
 	if err != nil {
@@ -338,12 +283,7 @@ func VerifyEmail(uid bson.ObjectId, ctx context.Context) error {
 	} else if err != nil {
 		return err
 	}
-	go func() {
-		for _, value := range ValidSites {
-			_ = AddSubmissions(uid, value, ctx)
-			_ = AddOrUpdateProfile(uid, value, ctx)
-		}
-	}()
+	queueFetches(uid, scrappers.Sites(), ctx)
 	return nil
 }
 
@@ -473,12 +413,13 @@ func SearchUser(query string, c int) ([]types.SearchDoc, error) {
 	limit := bson.M{"$limit": c}
 	project := bson.M{
 		"$project": bson.M{
-			"_id":       1,
-			"username":  1,
-			"fullname":  1,
-			"institute": 1,
-			"picture":   1,
-			"handle":    1,
+			"_id":             1,
+			"username":        1,
+			"fullname":        1,
+			"institute":       1,
+			"picture":         1,
+			"handle":          1,
+			"verifiedhandles": 1,
 		},
 	}
 	pipe := sess.Collection.Pipe([]bson.M{
@@ -533,6 +474,6 @@ func FilterUsers(instituteName string) ([]types.SearchDoc, error) {
 	coll := sess.Collection
 	var result []types.SearchDoc
 	err := coll.Find(bson.M{"institute": instituteName}).Select(bson.M{"_id": 1, "username": 1, "email": 1,
-		"handle": 1, "picture": 1, "fullname": 1, "institute": 1}).All(&result)
+		"handle": 1, "verifiedhandles": 1, "picture": 1, "fullname": 1, "institute": 1}).All(&result)
 	return result, err
 }
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/google/uuid"
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/db"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers"
+	"github.com/mdg-iitr/Codephile/services/worker"
+)
+
+// Prefix of the tokens, so that the users could tell what they are put on their profiles for
+const verificationTokenPrefix = "codephile-"
+
+// Returns the token the user has to put on their profile on the site, to prove the ownership
+// of their handle. The token issued earlier is returned till it expires, if the handle is unchanged
+//Returns HandleNotFoundError/UserNotFoundError/error
+func IssueVerificationToken(uid bson.ObjectId, site string) (types.HandleVerification, error) {
+	if !scrappers.IsSiteValid(site) {
+		return types.HandleVerification{}, errors.New("site invalid")
+	}
+	sess := db.NewUserCollectionSession()
+	defer sess.Close()
+	coll := sess.Collection
+	handle, issued, err := getHandleVerification(coll, uid, site)
+	if err != nil {
+		return types.HandleVerification{}, err
+	}
+	if handle == "" {
+		return types.HandleVerification{}, HandleNotFoundError
+	}
+	if issued.Handle == handle && time.Now().Before(issued.ExpiresAt) {
+		return issued, nil
+	}
+	now := time.Now()
+	verification := types.HandleVerification{
+		Handle:    handle,
+		Token:     verificationTokenPrefix + strings.Replace(uuid.New().String(), "-", "", -1)[:12],
+		IssuedAt:  now,
+		ExpiresAt: now.Add(HandleVerificationTokenTTL),
+	}
+	err = coll.UpdateId(uid, bson.M{"$set": bson.M{"handleverification." + site: verification}})
+	if err != nil {
+		return types.HandleVerification{}, err
+	}
+	return verification, nil
+}
+
+// Looks up the token issued to the user on the site, and marks the handle as verified if the
+// token is found on its profile. Runs as a job, as the sites may take a while to respond
+//Returns VerificationTokenNotFoundError/HandleOwnershipUnprovenError/UserNotFoundError/ScrapeError/error
+func VerifyHandle(uid bson.ObjectId, site string, ctx context.Context) error {
+	sess := db.NewUserCollectionSession()
+	defer sess.Close()
+	coll := sess.Collection
+	handle, issued, err := getHandleVerification(coll, uid, site)
+	if err != nil {
+		return err
+	}
+	// Tokens of the previous handles do not prove anything
+	if issued.Token == "" || issued.Handle != handle || time.Now().After(issued.ExpiresAt) {
+		return VerificationTokenNotFoundError
+	}
+	found, err := scrappers.HasOwnershipToken(site, handle, issued.Token, issued.IssuedAt, ctx)
+	if err != nil {
+		log.Println(err.Error())
+		return err
+	}
+	if !found {
+		return HandleOwnershipUnprovenError
+	}
+	// Handle could have been changed while the site was looked up
+	err = coll.Update(bson.M{"_id": uid, "handle." + site: handle}, bson.M{
+		"$set":   bson.M{"verifiedhandles." + site: true},
+		"$unset": bson.M{"handleverification." + site: ""},
+	})
+	if err == mgo.ErrNotFound {
+		return VerificationTokenNotFoundError
+	} else if err != nil {
+		return err
+	}
+	worker.Count(ctx, "verified", 1)
+	return nil
+}
+
+// Returns the handle of the user on the site, along with the token issued for it
+func getHandleVerification(coll *mgo.Collection, uid bson.ObjectId, site string) (string, types.HandleVerification, error) {
+	var result struct {
+		Handle             types.Handle                        `bson:"handle"`
+		HandleVerification map[string]types.HandleVerification `bson:"handleverification"`
+	}
+	err := coll.FindId(uid).Select(bson.M{"handle": 1, "handleverification": 1}).One(&result)
+	if err == mgo.ErrNotFound {
+		return "", types.HandleVerification{}, UserNotFoundError
+	} else if err != nil {
+		return "", types.HandleVerification{}, err
+	}
+	return result.Handle[site], result.HandleVerification[site], nil
+}
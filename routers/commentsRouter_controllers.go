@@ -7,6 +7,60 @@ import (
 
 func init() {
 
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "GetHealth",
+            Router: `/health`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "GetDeadJobs",
+            Router: `/jobs/dead`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "PurgeDeadJobs",
+            Router: `/jobs/dead`,
+            AllowHTTPMethods: []string{"delete"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "PurgeDeadJob",
+            Router: `/jobs/dead/:id`,
+            AllowHTTPMethods: []string{"delete"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "RetryDeadJob",
+            Router: `/jobs/dead/:id/retry`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:AdminController"],
+        beego.ControllerComments{
+            Method: "GetMetrics",
+            Router: `/metrics`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:ContestController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:ContestController"],
         beego.ControllerComments{
             Method: "GetContests",
@@ -124,6 +178,60 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"],
+        beego.ControllerComments{
+            Method: "GetRatingGraph",
+            Router: `/rating/:site`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"],
+        beego.ControllerComments{
+            Method: "GetRatingGraph",
+            Router: `/rating/:site/:uid`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"],
+        beego.ControllerComments{
+            Method: "GetProfileSnapshots",
+            Router: `/snapshots/:site`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:GraphController"],
+        beego.ControllerComments{
+            Method: "GetProfileSnapshots",
+            Router: `/snapshots/:site/:uid`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:JobController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:JobController"],
+        beego.ControllerComments{
+            Method: "GetJobs",
+            Router: `/`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:JobController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:JobController"],
+        beego.ControllerComments{
+            Method: "GetJob",
+            Router: `/:id`,
+            AllowHTTPMethods: []string{"get"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:SubmissionController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:SubmissionController"],
         beego.ControllerComments{
             Method: "PaginatedSubmissions",
@@ -187,6 +295,15 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:SubmissionController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:SubmissionController"],
+        beego.ControllerComments{
+            Method: "ImportSubmissions",
+            Router: `/import/:site`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
     beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:UserController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:UserController"],
         beego.ControllerComments{
             Method: "Get",
@@ -376,4 +493,22 @@ func init() {
             Filters: nil,
             Params: nil})
 
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:UserController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:UserController"],
+        beego.ControllerComments{
+            Method: "ConfirmHandle",
+            Router: `/verify/:site/confirm`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
+    beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:UserController"] = append(beego.GlobalControllerRouter["github.com/mdg-iitr/Codephile/controllers:UserController"],
+        beego.ControllerComments{
+            Method: "IssueVerificationToken",
+            Router: `/verify/:site/token`,
+            AllowHTTPMethods: []string{"post"},
+            MethodParams: param.Make(),
+            Filters: nil,
+            Params: nil})
+
 }
@@ -20,6 +20,7 @@ import (
 
 func init() {
 	beego.InsertFilter("/v1/*", beego.BeforeRouter, middleware.Authenticate)
+	beego.InsertFilter("/admin/*", beego.BeforeRouter, middleware.AuthenticateAdmin)
 	ns := beego.NewNamespace("/v1",
 		beego.NSNamespace("/user",
 			beego.NSInclude(
@@ -51,6 +52,11 @@ func init() {
 				&controllers.GraphController{},
 			),
 		),
+		beego.NSNamespace("/jobs",
+			beego.NSInclude(
+				&controllers.JobController{},
+			),
+		),
 	)
 	beego.SetStaticPath("/static", "static")
 	beego.Router("/", &controllers.HomePageController{})
@@ -58,5 +64,10 @@ func init() {
 		dir, _ := os.Getwd()
 		http.ServeFile(context.ResponseWriter, context.Request, path.Join(dir, "conf/institute_list.json"))
 	}))
-	beego.AddNamespace(ns, ns2)
+	admin := beego.NewNamespace("/admin",
+		beego.NSInclude(
+			&controllers.AdminController{},
+		),
+	)
+	beego.AddNamespace(ns, ns2, admin)
 }
@@ -0,0 +1,238 @@
+package atcoder
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gocolly/colly"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// AtCoder does not expose a submission API, the submissions are taken from
+// AtCoder Problems which mirrors them. It serves at most 500 submissions per request.
+const submissionPageSize = 500
+
+type Scrapper struct {
+	Handle  string
+	Context context.Context
+	Fetcher common.Fetcher
+}
+
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site:          ATCODER,
+		Name:          "AtCoder",
+		URL:           "https://atcoder.jp",
+		RatedContests: true,
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
+	})
+}
+
+func (s Scrapper) CheckHandle() (bool, error) {
+	_, err := common.Get(s.Fetcher, ATCODER, "https://atcoder.jp/users/"+url.PathEscape(s.Handle), s.Context)
+	if ScrapeErrorKind(err) == HandleNotFound {
+		return false, nil
+	}
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
+	c := common.NewCollector(s.Fetcher, s.Context)
+	profile := types.ProfileInfo{UserName: s.Handle}
+
+	c.OnHTML("a.username", func(e *colly.HTMLElement) {
+		profile.Name = e.Text
+	})
+	// Both the affiliation and the rank are laid out as rows of definition tables
+	c.OnHTML("table.dl-table tr", func(e *colly.HTMLElement) {
+		value := strings.TrimSpace(e.ChildText("td"))
+		switch strings.TrimSpace(e.ChildText("th")) {
+		case "Affiliation":
+			profile.School = value
+		case "Rank":
+			// Rank is displayed as an ordinal, eg. 1234th
+			profile.GlobalRank, _ = strconv.Atoi(strings.TrimRight(value, "stndrh"))
+		case "Rating":
+			profile.Rating = leadingNumber(value)
+		case "Highest Rating":
+			// Highest rating is followed by its kyu, eg. 1105 ― 6 Kyu
+			profile.MaxRating = leadingNumber(value)
+		case "Rated Matches":
+			profile.ContestsAttended = leadingNumber(value)
+		}
+	})
+
+	err := common.Visit(c, ATCODER, "https://atcoder.jp/users/"+url.PathEscape(s.Handle))
+	if err != nil {
+		log.Println(err.Error())
+		return types.ProfileInfo{}, err
+	}
+	return profile, nil
+}
+
+// Returns the number the text starts with, or 0 if it does not start with one
+func leadingNumber(text string) int {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return 0
+	}
+	number, _ := strconv.Atoi(fields[0])
+	return number
+}
+
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	data, err := common.Get(s.Fetcher, ATCODER, "https://atcoder.jp/users/"+url.PathEscape(s.Handle)+"/history/json", s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	var history []types.AtcoderContestResult
+	err = json.Unmarshal(data, &history)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(data),
+		}, nil)
+		hub.CaptureException(err)
+		log.Println(err.Error())
+		return nil, common.ParseError(ATCODER, err)
+	}
+	var graph types.RatingGraph
+	for _, result := range history {
+		if !result.IsRated {
+			continue
+		}
+		// Screen name is of the form abc100.contest.atcoder.jp
+		contest := strings.Split(result.ContestScreenName, ".")[0]
+		graph = append(graph, types.RatingChange{
+			ContestName:  result.ContestName,
+			ContestURL:   "https://atcoder.jp/contests/" + contest,
+			Rank:         result.Place,
+			OldRating:    result.OldRating,
+			NewRating:    result.NewRating,
+			CreationDate: result.EndTime,
+		})
+	}
+	return graph, nil
+}
+
+func getSubmissionParts(f common.Fetcher, handle string, fromSecond int64, hub *sentry.Hub, ctx context.Context) ([]types.AtcoderSubmission, error) {
+	path := "https://kenkoooo.com/atcoder/atcoder-api/v3/user/submissions?user=" + url.QueryEscape(handle) +
+		"&from_second=" + strconv.FormatInt(fromSecond, 10)
+	data, err := common.Get(f, ATCODER, path, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var submissions []types.AtcoderSubmission
+	err = json.Unmarshal(data, &submissions)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(data),
+		}, nil)
+		hub.CaptureException(err)
+		return nil, common.ParseError(ATCODER, err)
+	}
+	return submissions, nil
+}
+
+// Returns the submissions made after the given time, latest first.
+// On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	var fromSecond int64
+	if !after.IsZero() {
+		fromSecond = after.Unix() + 1
+	}
+	var atcoderSubs []types.AtcoderSubmission
+	seen := map[int]bool{}
+	var err error
+	// Submissions are returned in chronological order starting from fromSecond
+	for {
+		var part []types.AtcoderSubmission
+		part, err = getSubmissionParts(s.Fetcher, s.Handle, fromSecond, hub, s.Context)
+		if err != nil {
+			log.Println(err.Error())
+			break
+		}
+		for _, sub := range part {
+			// Page boundaries are inclusive of the last second, skip repeated ones
+			if seen[sub.ID] {
+				continue
+			}
+			seen[sub.ID] = true
+			atcoderSubs = append(atcoderSubs, sub)
+		}
+		if len(part) < submissionPageSize {
+			break
+		}
+		fromSecond = part[len(part)-1].EpochSecond
+		// AtCoder Problems asks clients to wait between consecutive requests
+		if err = common.Sleep(s.Context, time.Second); err != nil {
+			break
+		}
+	}
+	// Latest submission should come first
+	submissions := make([]types.Submission, len(atcoderSubs))
+	for i, result := range atcoderSubs {
+		var status string
+		switch result.Result {
+		case "AC":
+			status = StatusCorrect
+		case "WA":
+			status = StatusWrongAnswer
+		case "CE":
+			status = StatusCompilationError
+		case "RE":
+			status = StatusRuntimeError
+		case "TLE":
+			status = StatusTimeLimitExceeded
+		case "MLE":
+			status = StatusMemoryLimitExceeded
+		case "OLE":
+			status = StatusOutputLimitExceeded
+		case "IE":
+			status = StatusInternalError
+		// Waiting for judge or rejudge
+		case "WJ", "WR":
+			status = StatusPending
+		default:
+			status = StatusWrongAnswer
+		}
+		sub := &submissions[len(atcoderSubs)-1-i]
+		sub.Name = result.ProblemID
+		sub.URL = "https://atcoder.jp/contests/" + result.ContestID + "/tasks/" + result.ProblemID
+		// Problems shared by simultaneous contests have the same id in all of them
+		sub.ProblemID = types.ProblemID(ATCODER, "", result.ProblemID)
+		sub.ID = strconv.Itoa(result.ID)
+		sub.SubmissionURL = "https://atcoder.jp/contests/" + result.ContestID + "/submissions/" + sub.ID
+		sub.CreationDate = time.Unix(result.EpochSecond, 0)
+		sub.Status = status
+		sub.Language = result.Language
+		sub.Points = int(result.Point)
+	}
+	return submissions, err
+}
@@ -0,0 +1,86 @@
+package atcoder
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	Convey("Subject: AtCoder handle validation\n", t, func() {
+		valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeTrue)
+		valid, err = newScrapper(t, "nobody", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: AtCoder profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "alice")
+		So(profile.School, ShouldEqual, "IIT Roorkee")
+		So(profile.GlobalRank, ShouldEqual, 2345)
+		So(profile.Rating, ShouldEqual, 1023)
+		So(profile.MaxRating, ShouldEqual, 1105)
+		So(profile.ContestsAttended, ShouldEqual, 12)
+
+		Convey("Unknown handles should be reported", func() {
+			_, err := newScrapper(t, "nobody", "profile").GetProfileInfo()
+			So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+		})
+	})
+}
+
+func TestGetRatingHistory(t *testing.T) {
+	Convey("Subject: AtCoder rating history\n", t, func() {
+		graph, err := newScrapper(t, "alice", "profile").GetRatingHistory()
+		So(err, ShouldBeNil)
+		So(len(graph), ShouldEqual, 2)
+		So(graph[0].ContestName, ShouldEqual, "AtCoder Beginner Contest 150")
+		So(graph[0].ContestURL, ShouldEqual, "https://atcoder.jp/contests/abc150")
+		So(graph[0].Rank, ShouldEqual, 1200)
+		So(graph[1].OldRating, ShouldEqual, 400)
+		So(graph[1].NewRating, ShouldEqual, 650)
+		So(graph[1].CreationDate.Unix(), ShouldEqual, 1579441200)
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: AtCoder submissions\n", t, func() {
+		subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		So(len(subs), ShouldEqual, 3)
+		So(subs[0].Name, ShouldEqual, "abc150_b")
+		So(subs[0].URL, ShouldEqual, "https://atcoder.jp/contests/abc150/tasks/abc150_b")
+		So(subs[0].ProblemID, ShouldEqual, "atcoder/abc150_b")
+		So(subs[0].ID, ShouldEqual, "9000003")
+		So(subs[0].SubmissionURL, ShouldEqual, "https://atcoder.jp/contests/abc150/submissions/9000003")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[0].Points, ShouldEqual, 200)
+		So(subs[0].Language, ShouldEqual, "Python (3.8.2)")
+		So(subs[1].Status, ShouldEqual, StatusTimeLimitExceeded)
+		So(subs[2].CreationDate.Unix(), ShouldEqual, 1578660000)
+	})
+}
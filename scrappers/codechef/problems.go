@@ -0,0 +1,71 @@
+package codechef
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/getsentry/sentry-go"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Categories of the practice section, every problem of codechef is listed in one of them
+var problemCategories = []string{"school", "easy", "medium", "hard", "challenge", "extcontest"}
+
+// Problems listed by the API in a call
+const problemPageSize = 20
+
+// Returns the problems of the practice section, their category is used as the difficulty
+func (s Scrapper) GetProblems() ([]types.Problem, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	var problems []types.Problem
+	for _, category := range problemCategories {
+		for offset := 0; ; offset += problemPageSize {
+			problemsURL := fmt.Sprintf("https://api.codechef.com/problems/%s?fields=problemCode%%2CproblemName&limit=%d&offset=%d",
+				category, problemPageSize, offset)
+			data, err := callAPI(s.Fetcher, problemsURL, hub, s.Context)
+			if err != nil {
+				log.Println(err.Error())
+				return problems, err
+			}
+			var page types.CodechefProblems
+			err = json.Unmarshal(data, &page)
+			if err != nil {
+				hub.AddBreadcrumb(&sentry.Breadcrumb{
+					Category: "JSON parse error",
+					Message:  string(data),
+				}, nil)
+				hub.CaptureException(err)
+				log.Println(err.Error())
+				return problems, common.ParseError(CODECHEF, err)
+			}
+			result := page.Result.Data
+			// 9002 implies rate limit exceeded
+			if result.Code == 9002 {
+				return problems, NewScrapeError(RateLimited, CODECHEF, errors.New(result.Message))
+			}
+			for _, p := range result.Content {
+				problems = append(problems, types.Problem{
+					ID:         types.ProblemID(CODECHEF, "", p.ProblemCode),
+					Platform:   CODECHEF,
+					Index:      p.ProblemCode,
+					Name:       p.ProblemName,
+					URL:        "https://www.codechef.com/problems/" + p.ProblemCode,
+					Difficulty: category,
+				})
+			}
+			if len(result.Content) < problemPageSize {
+				break
+			}
+		}
+	}
+	return problems, nil
+}
@@ -7,83 +7,105 @@ import (
 	"fmt"
 	"github.com/getsentry/sentry-go"
 	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/types"
-	// "github.com/mdg-iitr/Codephile/scrappers/common"
-	"io/ioutil"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
 	"log"
 	"net/http"
 	"net/url"
-	"os"
-	// "regexp"
-	// "strconv"
-	// "strings"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Scrapper struct {
 	Handle string
 	Context context.Context
+	Fetcher common.Fetcher
 }
 
-var token string
-
-func GetBearerToken(hub *sentry.Hub) string {
-	tokenURL := "https://api.codechef.com/oauth/token"
-	resp, err := http.PostForm(tokenURL, map[string][]string{
-		"client_id":     {os.Getenv("CLIENT_ID")},
-		"client_secret": {os.Getenv("CLIENT_SECRET")},
-		"grant_type":    {"client_credentials"},
-		"scope":         {"public"},
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site:          CODECHEF,
+		Name:          "CodeChef",
+		URL:           "https://www.codechef.com",
+		RatedContests: true,
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
 	})
+}
+
+// Makes an authorized request to the codechef API, the token is refreshed once if it was rejected
+func callAPI(f common.Fetcher, path string, hub *sentry.Hub, ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
 	if err != nil {
-		log.Println(err.Error())
-		hub.CaptureException(err)
-		return ""
+		return nil, err
 	}
-	byteValue, err := ioutil.ReadAll(resp.Body)
+	req = common.WithContext(req, ctx)
+	token, err := tokens.Token(f, hub, ctx)
 	if err != nil {
-		log.Println(err.Error())
-		hub.CaptureException(err)
-		return ""
+		return nil, err
 	}
-	var respStruct map[string]interface{}
-	err = json.Unmarshal(byteValue, &respStruct)
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	data, status, err := common.HitRequest(f, req)
 	if err != nil {
-		hub.AddBreadcrumb(&sentry.Breadcrumb{
-			Category:  "JSON parse error",
-			Message:   string(byteValue),
-		}, nil)
-		hub.CaptureException(err)
+		return nil, common.RequestError(CODECHEF, err)
 	}
-	result := respStruct["result"].(map[string]interface{})
-	accessToken := result["data"].(map[string]interface{})["access_token"].(string)
-	return accessToken
+	if status == http.StatusUnauthorized {
+		tokens.Invalidate(token)
+		token, err = tokens.Token(f, hub, ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		data, status, err = common.HitRequest(f, req)
+		if err != nil {
+			return nil, common.RequestError(CODECHEF, err)
+		}
+	}
+	if err := common.StatusError(CODECHEF, status); err != nil {
+		return nil, err
+	}
+	return data, nil
 }
 
-func fetchAndParseProfileData(handle string, fields string, hub *sentry.Hub) (types.CodechefProfileInfo, int) {
+func fetchAndParseProfileData(f common.Fetcher, handle string, fields string, hub *sentry.Hub, ctx context.Context) (types.CodechefProfileInfo, error) {
 	profileURL := fmt.Sprintf("https://api.codechef.com/users/%s?fields=%s",
 		handle, url.QueryEscape(fields))
-	client := &http.Client{}
-	req, _ := http.NewRequest(http.MethodGet, profileURL, nil)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	resp, _ := client.Do(req)
-	if resp.StatusCode != http.StatusOK {
-		return types.CodechefProfileInfo{}, resp.StatusCode
-	}
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		hub.CaptureException(err)
-	}
-	var profileInfo types.CodechefProfileInfo
-	err = json.Unmarshal(data, &profileInfo)
-	if err != nil {
-		hub.AddBreadcrumb(&sentry.Breadcrumb{
-			Category:  "JSON parse error",
-			Message:   string(data),
-		}, nil)
-		hub.CaptureException(err)
+	var err error
+	for attempt := 0; attempt < 5; attempt++ {
+		if err := common.Sleep(ctx, time.Second*time.Duration(attempt)); err != nil {
+			return types.CodechefProfileInfo{}, err
+		}
+		var data []byte
+		data, err = callAPI(f, profileURL, hub, ctx)
+		if err != nil {
+			return types.CodechefProfileInfo{}, err
+		}
+		var profileInfo types.CodechefProfileInfo
+		err = json.Unmarshal(data, &profileInfo)
+		if err != nil {
+			hub.AddBreadcrumb(&sentry.Breadcrumb{
+				Category:  "JSON parse error",
+				Message:   string(data),
+			}, nil)
+			hub.CaptureException(err)
+			return types.CodechefProfileInfo{}, common.ParseError(CODECHEF, err)
+		}
+		result := profileInfo.Result["data"]
+		switch result.Code {
+		case 9001:
+			return profileInfo, nil
+		// 9002 implies rate limit exceeded
+		case 9002:
+			err = NewScrapeError(RateLimited, CODECHEF, errors.New(result.Message))
+		default:
+			return types.CodechefProfileInfo{}, NewScrapeError(HandleNotFound, CODECHEF, errors.New(result.Message))
+		}
 	}
-	return profileInfo, resp.StatusCode
+	return types.CodechefProfileInfo{}, err
 }
 
 func (s Scrapper) CheckHandle() (bool, error) {
@@ -91,77 +113,59 @@ func (s Scrapper) CheckHandle() (bool, error) {
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	fields := "username"
-	var (
-		profileInfo types.CodechefProfileInfo
-		status      int
-	)
-	for attempt := 0; attempt < 5; attempt++ {
-		time.Sleep(time.Second * time.Duration(attempt))
-		profileInfo, status = fetchAndParseProfileData(s.Handle, fields, hub)
-		if status == http.StatusUnauthorized {
-			token = GetBearerToken(hub)
-			profileInfo, _ = fetchAndParseProfileData(s.Handle, fields, hub) // nolint: ineffassign
-		}
-		// 9002 implies rate limit exceeded
-		if profileInfo.Result["data"].Code != 9002 {
-			break
-		}
+	_, err := fetchAndParseProfileData(s.Fetcher, s.Handle, "username", hub, s.Context)
+	if ScrapeErrorKind(err) == HandleNotFound {
+		return false, nil
+	}
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
 	}
-	code := profileInfo.Result["data"].Code
-	return code == 9001, nil
+	return true, nil
 }
 
-func (s Scrapper) GetProfileInfo() types.ProfileInfo {
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	fields := "username,fullname,organization,rankings"
-	var (
-		profileInfo types.CodechefProfileInfo
-		status      int
-	)
-	for attempt := 0; attempt < 5; attempt++ {
-		time.Sleep(time.Second * time.Duration(attempt))
-		profileInfo, status = fetchAndParseProfileData(s.Handle, fields, hub)
-		if status == http.StatusUnauthorized {
-			token = GetBearerToken(hub)
-			profileInfo, _ = fetchAndParseProfileData(s.Handle, fields, hub) // nolint: ineffassign
+	fields := "username,fullname,organization,rankings,ratings,band"
+	profileInfo, err := fetchAndParseProfileData(s.Fetcher, s.Handle, fields, hub, s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return types.ProfileInfo{}, err
+	}
+	resultData := profileInfo.Result["data"].Content
+	profile := types.ProfileInfo{
+		Name:     resultData.Fullname,
+		UserName: resultData.Username,
+		School:   resultData.Organization,
+	}
+	// Users who have not taken part in any contest are not ranked
+	if ranking, ok := resultData.Rankings["allContestRanking"].(map[string]interface{}); ok {
+		if global, ok := ranking["global"].(float64); ok {
+			profile.GlobalRank = int(global)
 		}
-		// 9002 implies rate limit exceeded
-		if profileInfo.Result["data"].Code != 9002 {
-			break
+		if country, ok := ranking["country"].(float64); ok {
+			profile.CountryRank = int(country)
 		}
 	}
-	resultData := profileInfo.Result["data"].Content
-	return types.ProfileInfo{
-		Name:      resultData.Fullname,
-		UserName:  resultData.Username,
-		School:    resultData.Organization,
-		WorldRank: fmt.Sprint(resultData.Rankings["allContestRanking"].(map[string]interface{})["global"].(float64)),
+	if rating, ok := resultData.Ratings["allContest"].(float64); ok {
+		profile.Rating = int(rating)
 	}
+	// Band is of the form 4★
+	profile.RankTitle = resultData.Band
+	profile.Stars, _ = strconv.Atoi(strings.TrimSuffix(resultData.Band, "★"))
+	return profile, nil
 }
 
-func callCodechefAPI(handle string, afterIndex int, hub *sentry.Hub) (types.CodechefSubmissions, error) {
+func callCodechefAPI(f common.Fetcher, handle string, afterIndex int, hub *sentry.Hub, ctx context.Context) (types.CodechefSubmissions, error) {
 	fields := "id, date, username, problemCode, language, result"
 	submissionURL := fmt.Sprintf("https://api.codechef.com/submissions/?&username=%s&after=%d&limit=20&fields=%s",
 		handle, afterIndex, url.QueryEscape(fields))
-	client := &http.Client{}
-	req, _ := http.NewRequest(http.MethodGet, submissionURL, nil)
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
-	resp, _ := client.Do(req)
-	if resp.StatusCode == http.StatusUnauthorized {
-		token = GetBearerToken(hub)
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-		resp, _ = client.Do(req)
-	}
-	data, err := ioutil.ReadAll(resp.Body)
+	data, err := callAPI(f, submissionURL, hub, ctx)
 	if err != nil {
-		hub.CaptureException(err)
-	}
-	if data == nil {
-		return types.CodechefSubmissions{}, errors.New("GetRequest failed. Please check connection status")
+		return types.CodechefSubmissions{}, err
 	}
 	var codechefSubmissions types.CodechefSubmissions
 	err = json.Unmarshal(data, &codechefSubmissions)
@@ -172,33 +176,35 @@ func callCodechefAPI(handle string, afterIndex int, hub *sentry.Hub) (types.Code
 		}, nil)
 		hub.CaptureException(err)
 		log.Println(err.Error())
-		return types.CodechefSubmissions{}, err
+		return types.CodechefSubmissions{}, common.ParseError(CODECHEF, err)
 	}
 	return codechefSubmissions, nil
 }
 
-func getCodechefSubmissionParts(handle string, afterIndex int, hub *sentry.Hub) ([]types.Submission, error, int) {
-	codechefSubmission, err := callCodechefAPI(handle, afterIndex, hub)
+func getCodechefSubmissionParts(f common.Fetcher, handle string, afterIndex int, hub *sentry.Hub, ctx context.Context) ([]types.Submission, error, int) {
+	codechefSubmission, err := callCodechefAPI(f, handle, afterIndex, hub, ctx)
 	if err != nil {
 		return nil, err, afterIndex
 	}
 	if codechefSubmission.Status != "OK" {
 		log.Println("Codechef submission could not be retrieved. Retrying...")
-		var newCodechefSub types.CodechefSubmissions
-		for attempt := 1; attempt < 5; attempt++ {
-			time.Sleep(time.Second * time.Duration(attempt))
-			newCodechefSub, err = callCodechefAPI(handle, afterIndex, hub)
-			if err != nil {
+		for attempt := 1; attempt < 5 && codechefSubmission.Status != "OK"; attempt++ {
+			if err := common.Sleep(ctx, time.Second*time.Duration(attempt)); err != nil {
 				return nil, err, afterIndex
 			}
-			if newCodechefSub.Status == "OK" {
-				codechefSubmission = newCodechefSub
-				break
+			codechefSubmission, err = callCodechefAPI(f, handle, afterIndex, hub, ctx)
+			if err != nil {
+				return nil, err, afterIndex
 			}
 		}
-		if newCodechefSub.Status == "FAILED" {
+		if codechefSubmission.Status != "OK" {
+			data := codechefSubmission.Result.Data
+			// 9002 implies rate limit exceeded
+			if data.Code == 9002 {
+				return nil, NewScrapeError(RateLimited, CODECHEF, errors.New(data.Message)), afterIndex
+			}
 			hub.CaptureException(errors.New("codechef API repeatedly returned FAILED"))
-			return nil, errors.New("codechef API repeatedly returned FAILED"), 0
+			return nil, NewScrapeError(PlatformUnavailable, CODECHEF, errors.New("codechef API repeatedly returned FAILED")), afterIndex
 		}
 	}
 	submissions := make([]types.Submission, len(codechefSubmission.Result.Data.Content))
@@ -214,6 +220,14 @@ func getCodechefSubmissionParts(handle string, afterIndex int, hub *sentry.Hub)
 			status = StatusRuntimeError
 		case "WA":
 			status = StatusWrongAnswer
+		case "TLE":
+			status = StatusTimeLimitExceeded
+		case "MLE":
+			status = StatusMemoryLimitExceeded
+		case "PAC":
+			status = StatusPartial
+		case "IE":
+			status = StatusInternalError
 		default:
 			status = StatusWrongAnswer
 		}
@@ -221,6 +235,10 @@ func getCodechefSubmissionParts(handle string, afterIndex int, hub *sentry.Hub)
 		submissions[i].Status = status
 		submissions[i].Language = result.Language
 		submissions[i].URL = "https://www.codechef.com/problems/" + result.ProblemCode
+		// Problem codes are unique across the contests of codechef
+		submissions[i].ProblemID = types.ProblemID(CODECHEF, "", result.ProblemCode)
+		submissions[i].ID = strconv.Itoa(result.ID)
+		submissions[i].SubmissionURL = "https://www.codechef.com/viewsolution/" + submissions[i].ID
 		t, err := time.Parse("2006-01-02 15:04:05", result.Date)
 		if err != nil {
 			hub.CaptureException(err)
@@ -231,12 +249,13 @@ func getCodechefSubmissionParts(handle string, afterIndex int, hub *sentry.Hub)
 	return submissions, nil, lastID
 }
 
-func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
+// Returns the submissions made after the given time, latest first.
+// On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	oldestSubIndex := 0
 	var lastID int
 	var oldestSubFound = false
 	var subs []types.Submission
@@ -244,25 +263,74 @@ func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
 	var err error
 	//Fetch submission until oldest submission not found
 	for !oldestSubFound {
-		newSub, err, lastID = getCodechefSubmissionParts(s.Handle, lastID, hub)
+		newSub, err, lastID = getCodechefSubmissionParts(s.Fetcher, s.Handle, lastID, hub, s.Context)
 		if err != nil {
 			log.Println(err.Error())
-			return nil
+			return subs, err
 		}
-		//Check for repetition of previous fetched submission
-		if len(newSub) != 0 {
-			for _, sub := range newSub {
-				subs = append(subs, sub)
-				oldestSubIndex += 1
-				if sub.CreationDate.Equal(after) || sub.CreationDate.Before(after) {
-					oldestSubFound = true
-					break
-				}
-			}
-		} else {
+		if len(newSub) == 0 {
 			break
 		}
+		for _, sub := range newSub {
+			// Submissions up to the last fetched one are already stored
+			if !sub.CreationDate.After(after) {
+				oldestSubFound = true
+				break
+			}
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// The rating graph on the profile page is rendered from this inline script variable
+var ratingRegex = regexp.MustCompile(`var all_rating = (\[.*?\]);`)
+
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	data, err := common.Get(s.Fetcher, CODECHEF, "https://www.codechef.com/users/"+url.PathEscape(s.Handle), s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	match := ratingRegex.FindSubmatch(data)
+	if match == nil {
+		// User has not participated in any rated contest
+		return nil, nil
+	}
+	var ratings []types.CodechefRating
+	err = json.Unmarshal(match[1], &ratings)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(match[1]),
+		}, nil)
+		hub.CaptureException(err)
+		log.Println(err.Error())
+		return nil, common.ParseError(CODECHEF, err)
+	}
+	graph := make(types.RatingGraph, len(ratings))
+	// Every user starts with a rating of 1500
+	oldRating := 1500
+	for i, r := range ratings {
+		newRating, _ := strconv.Atoi(r.Rating)
+		rank, _ := strconv.Atoi(r.Rank)
+		t, err := time.Parse("2006-01-02 15:04:05", r.EndDate)
+		if err != nil {
+			hub.CaptureException(err)
+		}
+		graph[i] = types.RatingChange{
+			ContestName:  r.Name,
+			ContestURL:   "https://www.codechef.com/" + r.Code,
+			Rank:         rank,
+			OldRating:    oldRating,
+			NewRating:    newRating,
+			CreationDate: t,
+		}
+		oldRating = newRating
 	}
-	subs = subs[0:oldestSubIndex]
-	return subs
+	return graph, nil
 }
@@ -0,0 +1,113 @@
+package codechef
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	// The token request of the fixtures was made with these credentials
+	os.Setenv("CLIENT_ID", "client")     // nolint: errcheck
+	os.Setenv("CLIENT_SECRET", "secret") // nolint: errcheck
+	Convey("Subject: Codechef handle validation\n", t, func() {
+		Convey("Expired token should be refreshed", func() {
+			tokens = &tokenManager{token: "expired", expiry: time.Now().Add(time.Hour)}
+			valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+			So(err, ShouldBeNil)
+			So(valid, ShouldBeTrue)
+			So(tokens.token, ShouldEqual, "token")
+		})
+		Convey("Unknown handle should be invalid", func() {
+			valid, err := newScrapper(t, "nobody", "profile").CheckHandle()
+			So(err, ShouldBeNil)
+			So(valid, ShouldBeFalse)
+		})
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: Codechef profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "Alice Liddell")
+		So(profile.School, ShouldEqual, "IIT Roorkee")
+		So(profile.GlobalRank, ShouldEqual, 1234)
+		So(profile.CountryRank, ShouldEqual, 56)
+		So(profile.Rating, ShouldEqual, 1854)
+		So(profile.RankTitle, ShouldEqual, "4★")
+		So(profile.Stars, ShouldEqual, 4)
+	})
+}
+
+func TestGetRatingHistory(t *testing.T) {
+	Convey("Subject: Codechef rating history\n", t, func() {
+		graph, err := newScrapper(t, "alice", "profile").GetRatingHistory()
+		So(err, ShouldBeNil)
+		So(len(graph), ShouldEqual, 2)
+		So(graph[0].ContestName, ShouldEqual, "January Challenge 2020 Division 2")
+		So(graph[0].ContestURL, ShouldEqual, "https://www.codechef.com/JAN20B")
+		So(graph[0].OldRating, ShouldEqual, 1500)
+		So(graph[0].NewRating, ShouldEqual, 1620)
+		So(graph[1].OldRating, ShouldEqual, 1620)
+		So(graph[1].NewRating, ShouldEqual, 1587)
+		So(graph[1].Rank, ShouldEqual, 2100)
+		So(graph[1].CreationDate, ShouldEqual, time.Date(2020, 1, 19, 0, 0, 0, 0, time.UTC))
+
+		Convey("Users without rated contests should have no history", func() {
+			graph, err := newScrapper(t, "nobody", "profile").GetRatingHistory()
+			So(err, ShouldBeNil)
+			So(graph, ShouldBeNil)
+		})
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: Codechef submissions\n", t, func() {
+		subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		So(len(subs), ShouldEqual, 3)
+		So(subs[0].Name, ShouldEqual, "CHEFSTR1")
+		So(subs[0].URL, ShouldEqual, "https://www.codechef.com/problems/CHEFSTR1")
+		So(subs[0].ProblemID, ShouldEqual, "codechef/CHEFSTR1")
+		So(subs[0].ID, ShouldEqual, "30000003")
+		So(subs[0].SubmissionURL, ShouldEqual, "https://www.codechef.com/viewsolution/30000003")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[0].Language, ShouldEqual, "C++14")
+		So(subs[1].Status, ShouldEqual, StatusCompilationError)
+		So(subs[2].Status, ShouldEqual, StatusRuntimeError)
+		So(subs[2].CreationDate, ShouldEqual, time.Date(2020, 2, 9, 11, 0, 0, 0, time.UTC))
+	})
+}
+
+func TestGetProblems(t *testing.T) {
+	Convey("Subject: Codechef problems\n", t, func() {
+		problems, err := newScrapper(t, "", "problems").GetProblems()
+		So(err, ShouldBeNil)
+		So(len(problems), ShouldEqual, 22)
+		So(problems[0].ID, ShouldEqual, "codechef/FLOW001")
+		So(problems[0].Name, ShouldEqual, "Flow Problem 1")
+		So(problems[0].URL, ShouldEqual, "https://www.codechef.com/problems/FLOW001")
+		So(problems[0].Difficulty, ShouldEqual, "school")
+		So(problems[20].ID, ShouldEqual, "codechef/FLOW021")
+		So(problems[21].ID, ShouldEqual, "codechef/CHEFSTR1")
+		So(problems[21].Difficulty, ShouldEqual, "easy")
+	})
+}
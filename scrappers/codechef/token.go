@@ -0,0 +1,296 @@
+package codechef
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Tokens are refreshed this long before they expire, so that requests in flight do not fail
+const tokenExpiryMargin = time.Minute
+
+// Lifetime assumed for the tokens granted without an expiry
+const defaultTokenLifetime = time.Hour
+
+// Lock of a refresh is held at most this long, in case its instance dies while refreshing
+const refreshLockTTL = 15 * time.Second
+
+// Instances waiting for the refresh of another one look up the stored token this often
+const refreshPollInterval = 200 * time.Millisecond
+
+// TokenStore keeps the bearer token of the codechef API outside the process
+type TokenStore interface {
+	// Token returns the stored token and its expiry, the token is empty if none is stored
+	Token() (string, time.Time, error)
+	SaveToken(token string, expiry time.Time) error
+}
+
+// RefreshLocker is a TokenStore letting a single instance refresh the token at a time,
+// the others wait for the token it stores
+type RefreshLocker interface {
+	// LockRefresh takes the lock for ttl at most. Returns false if another instance holds it
+	LockRefresh(ttl time.Duration) (bool, error)
+	UnlockRefresh() error
+}
+
+const (
+	tokenKey     = "codechef_token"
+	tokenLockKey = "codechef_token_lock"
+)
+
+// redisTokenStore shares the token between the instances and keeps it across restarts
+type redisTokenStore struct {
+	client *redis.Client
+	// Holder of the refresh lock, unique to the process
+	owner string
+}
+
+func (s redisTokenStore) Token() (string, time.Time, error) {
+	token, err := s.client.Get(tokenKey).Result()
+	if err == redis.Nil {
+		return "", time.Time{}, nil
+	} else if err != nil {
+		return "", time.Time{}, err
+	}
+	ttl, err := s.client.TTL(tokenKey).Result()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Now().Add(ttl), nil
+}
+
+func (s redisTokenStore) SaveToken(token string, expiry time.Time) error {
+	return s.client.Set(tokenKey, token, time.Until(expiry)).Err()
+}
+
+func (s redisTokenStore) LockRefresh(ttl time.Duration) (bool, error) {
+	return s.client.SetNX(tokenLockKey, s.owner, ttl).Result()
+}
+
+// Lock is released only by its holder, it may have expired and been taken by another instance
+var unlockScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0
+`)
+
+func (s redisTokenStore) UnlockRefresh() error {
+	return unlockScript.Run(s.client, []string{tokenLockKey}, s.owner).Err()
+}
+
+var (
+	sharedTokenStore TokenStore
+	tokenStoreMutex  sync.RWMutex
+)
+
+// UseRedisTokenStore makes the instances share the token through redis, which is refreshed
+// by one of them at a time. Without it, each process requests a token of its own after starting
+func UseRedisTokenStore(client *redis.Client) {
+	tokenStoreMutex.Lock()
+	defer tokenStoreMutex.Unlock()
+	sharedTokenStore = redisTokenStore{client: client, owner: uuid.New().String()}
+}
+
+func currentTokenStore() TokenStore {
+	tokenStoreMutex.RLock()
+	defer tokenStoreMutex.RUnlock()
+	return sharedTokenStore
+}
+
+// tokenRefresh is a refresh in progress, shared by the callers needing a token meanwhile
+type tokenRefresh struct {
+	done  chan struct{}
+	token string
+	err   error
+	// Refresh was given up along with the context of the caller making it
+	canceled bool
+}
+
+// tokenManager caches the bearer token till it expires. Concurrent callers share a
+// single refresh, so that bulk syncs do not stampede the OAuth endpoint
+type tokenManager struct {
+	mutex   sync.Mutex
+	token   string
+	expiry  time.Time
+	refresh *tokenRefresh
+	// Token rejected by the API, which is not to be picked up from the store again
+	rejected string
+}
+
+var tokens = &tokenManager{}
+
+// Returns a token valid for a while, refreshing it if needed. Waiting for the refresh of
+// another caller is given up once the context is done
+func (m *tokenManager) Token(f common.Fetcher, hub *sentry.Hub, ctx context.Context) (string, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for {
+		m.mutex.Lock()
+		if m.token != "" && time.Now().Add(tokenExpiryMargin).Before(m.expiry) {
+			token := m.token
+			m.mutex.Unlock()
+			return token, nil
+		}
+		refresh := m.refresh
+		if refresh == nil {
+			break
+		}
+		m.mutex.Unlock()
+		select {
+		case <-refresh.done:
+		case <-ctx.Done():
+			return "", common.RequestError(CODECHEF, ctx.Err())
+		}
+		// Refresh canceled along with the caller making it is made again by the ones still waiting
+		if !refresh.canceled {
+			return refresh.token, refresh.err
+		}
+	}
+	refresh := &tokenRefresh{done: make(chan struct{})}
+	m.refresh = refresh
+	rejected := m.rejected
+	m.mutex.Unlock()
+
+	token, expiry, err := fetchToken(f, rejected, hub, ctx)
+
+	m.mutex.Lock()
+	if err == nil {
+		m.token, m.expiry = token, expiry
+	}
+	m.refresh = nil
+	m.mutex.Unlock()
+	refresh.token, refresh.err = token, err
+	refresh.canceled = err != nil && ctx.Err() != nil
+	close(refresh.done)
+	return token, err
+}
+
+// Drops the token rejected by the API. Tokens refreshed meanwhile by another caller are kept
+func (m *tokenManager) Invalidate(token string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.rejected = token
+	if m.token == token {
+		m.token = ""
+	}
+}
+
+// Returns the token of the store if it is valid, otherwise requests a new one and stores it.
+// If the store is shared, the instances take turns to refresh the token, so that it is
+// requested once for all of them
+func fetchToken(f common.Fetcher, rejected string, hub *sentry.Hub, ctx context.Context) (string, time.Time, error) {
+	store := currentTokenStore()
+	if token, expiry, ok := storedToken(store, rejected); ok {
+		return token, expiry, nil
+	}
+	if locker, ok := store.(RefreshLocker); ok {
+		for {
+			locked, err := locker.LockRefresh(refreshLockTTL)
+			if err != nil {
+				// Token is requested from codechef while the store is unreachable
+				log.Println(err.Error())
+				break
+			}
+			if locked {
+				defer func() {
+					if err := locker.UnlockRefresh(); err != nil {
+						log.Println(err.Error())
+					}
+				}()
+				// Token may have been stored by the previous holder after it was looked up
+				if token, expiry, ok := storedToken(store, rejected); ok {
+					return token, expiry, nil
+				}
+				break
+			}
+			if err := common.Sleep(ctx, refreshPollInterval); err != nil {
+				return "", time.Time{}, common.RequestError(CODECHEF, err)
+			}
+			if token, expiry, ok := storedToken(store, rejected); ok {
+				return token, expiry, nil
+			}
+		}
+	}
+	token, lifetime, err := requestToken(f, hub, ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiry := time.Now().Add(lifetime)
+	if store != nil {
+		if err := store.SaveToken(token, expiry); err != nil {
+			log.Println(err.Error())
+		}
+	}
+	return token, expiry, nil
+}
+
+// Returns the token of the store, if there is one valid for a while other than the rejected one
+func storedToken(store TokenStore, rejected string) (string, time.Time, bool) {
+	if store == nil {
+		return "", time.Time{}, false
+	}
+	token, expiry, err := store.Token()
+	if err != nil {
+		log.Println(err.Error())
+		return "", time.Time{}, false
+	}
+	if token == "" || token == rejected || !time.Now().Add(tokenExpiryMargin).Before(expiry) {
+		return "", time.Time{}, false
+	}
+	return token, expiry, true
+}
+
+// Requests a token of the client credentials from the OAuth endpoint
+func requestToken(f common.Fetcher, hub *sentry.Hub, ctx context.Context) (string, time.Duration, error) {
+	tokenURL := "https://api.codechef.com/oauth/token"
+	form := url.Values{
+		"client_id":     {os.Getenv("CLIENT_ID")},
+		"client_secret": {os.Getenv("CLIENT_SECRET")},
+		"grant_type":    {"client_credentials"},
+		"scope":         {"public"},
+	}
+	byteValue, err := common.Post(f, CODECHEF, tokenURL, "application/x-www-form-urlencoded", []byte(form.Encode()), ctx)
+	if err != nil {
+		log.Println(err.Error())
+		return "", 0, err
+	}
+	var respStruct struct {
+		Result struct {
+			Data struct {
+				AccessToken string `json:"access_token"`
+				ExpiresIn   int64  `json:"expires_in"`
+			} `json:"data"`
+		} `json:"result"`
+	}
+	err = json.Unmarshal(byteValue, &respStruct)
+	if err == nil && respStruct.Result.Data.AccessToken == "" {
+		err = errors.New("codechef did not grant an access token")
+	}
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(byteValue),
+		}, nil)
+		hub.CaptureException(err)
+		return "", 0, common.ParseError(CODECHEF, err)
+	}
+	lifetime := time.Duration(respStruct.Result.Data.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	return respStruct.Result.Data.AccessToken, lifetime, nil
+}
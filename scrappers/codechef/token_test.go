@@ -0,0 +1,190 @@
+package codechef
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Grants the tokens token1, token2... counting the requests made for them
+type oauthServer struct {
+	requests int32
+}
+
+func (s *oauthServer) Do(req *http.Request) (*http.Response, error) {
+	n := atomic.AddInt32(&s.requests, 1)
+	// Slow enough for the concurrent callers to overlap
+	select {
+	case <-time.After(20 * time.Millisecond):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	body := `{"status":"OK","result":{"data":{"access_token":"token` + strconv.Itoa(int(n)) + `","expires_in":3600}}}`
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+type memoryTokenStore struct {
+	token  string
+	expiry time.Time
+}
+
+func (s *memoryTokenStore) Token() (string, time.Time, error) {
+	return s.token, s.expiry, nil
+}
+
+func (s *memoryTokenStore) SaveToken(token string, expiry time.Time) error {
+	s.token, s.expiry = token, expiry
+	return nil
+}
+
+// Store shared by the instances, which refresh the token one at a time
+type lockingTokenStore struct {
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+	locked bool
+}
+
+func (s *lockingTokenStore) Token() (string, time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.token, s.expiry, nil
+}
+
+func (s *lockingTokenStore) SaveToken(token string, expiry time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.token, s.expiry = token, expiry
+	return nil
+}
+
+func (s *lockingTokenStore) LockRefresh(time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.locked {
+		return false, nil
+	}
+	s.locked = true
+	return true, nil
+}
+
+func (s *lockingTokenStore) UnlockRefresh() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.locked = false
+	return nil
+}
+
+func TestTokenManager(t *testing.T) {
+	hub := sentry.CurrentHub()
+	Convey("Subject: Codechef token manager\n", t, func() {
+		server := &oauthServer{}
+		store := &memoryTokenStore{}
+		sharedTokenStore = store
+		manager := &tokenManager{}
+		Reset(func() {
+			sharedTokenStore = nil
+		})
+
+		Convey("Concurrent callers should share a refresh", func() {
+			var wg sync.WaitGroup
+			granted := make([]string, 10)
+			for i := range granted {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					granted[i], _ = manager.Token(server, hub, context.Background())
+				}(i)
+			}
+			wg.Wait()
+			So(server.requests, ShouldEqual, 1)
+			for _, token := range granted {
+				So(token, ShouldEqual, "token1")
+			}
+			So(store.token, ShouldEqual, "token1")
+			So(store.expiry, ShouldHappenAfter, time.Now().Add(59*time.Minute))
+		})
+		Convey("Refresh given up by its caller should be made again by the ones waiting", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			canceled := make(chan error, 1)
+			go func() {
+				_, err := manager.Token(server, hub, ctx)
+				canceled <- err
+			}()
+			time.Sleep(5 * time.Millisecond)
+			waiting := make(chan string, 1)
+			go func() {
+				token, _ := manager.Token(server, hub, context.Background())
+				waiting <- token
+			}()
+			time.Sleep(5 * time.Millisecond)
+			cancel()
+			So(<-canceled, ShouldNotBeNil)
+			So(<-waiting, ShouldEqual, "token2")
+		})
+		Convey("Waiting callers should give up once their context is done", func() {
+			refreshed := make(chan string, 1)
+			go func() {
+				token, _ := manager.Token(server, hub, context.Background())
+				refreshed <- token
+			}()
+			time.Sleep(5 * time.Millisecond)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := manager.Token(server, hub, ctx)
+			So(err, ShouldNotBeNil)
+			So(<-refreshed, ShouldEqual, "token1")
+		})
+		Convey("Instances sharing the store should refresh the token once", func() {
+			sharedTokenStore = &lockingTokenStore{}
+			var wg sync.WaitGroup
+			granted := make([]string, 4)
+			for i := range granted {
+				wg.Add(1)
+				// Every caller is of an instance of its own
+				go func(i int) {
+					defer wg.Done()
+					granted[i], _ = (&tokenManager{}).Token(server, hub, context.Background())
+				}(i)
+			}
+			wg.Wait()
+			So(server.requests, ShouldEqual, 1)
+			for _, token := range granted {
+				So(token, ShouldEqual, "token1")
+			}
+		})
+		Convey("Stored token should be used after a restart", func() {
+			store.token, store.expiry = "stored", time.Now().Add(time.Hour)
+			token, err := manager.Token(server, hub, context.Background())
+			So(err, ShouldBeNil)
+			So(token, ShouldEqual, "stored")
+			So(server.requests, ShouldEqual, 0)
+		})
+		Convey("Token about to expire should be refreshed", func() {
+			store.token, store.expiry = "stored", time.Now().Add(time.Second)
+			token, _ := manager.Token(server, hub, context.Background())
+			So(token, ShouldEqual, "token1")
+		})
+		Convey("Rejected token should not be picked up again", func() {
+			store.token, store.expiry = "stored", time.Now().Add(time.Hour)
+			token, _ := manager.Token(server, hub, context.Background())
+			manager.Invalidate(token)
+			token, _ = manager.Token(server, hub, context.Background())
+			So(token, ShouldEqual, "token1")
+			So(server.requests, ShouldEqual, 1)
+		})
+	})
+}
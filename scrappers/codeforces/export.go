@@ -0,0 +1,62 @@
+package codeforces
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Parses a dump of the user.status API, either the whole response or only its result,
+// into the submissions of the handle, latest first
+func parseExport(handle string, r io.Reader) (subs []types.Submission, err error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, NewImportError(CODEFORCES, err)
+	}
+	var dump types.CodeforcesSubmissions
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &dump.Result)
+	} else {
+		err = json.Unmarshal(data, &dump)
+		if err == nil && dump.Status != "OK" {
+			err = errors.New("dump is of a failed call")
+		}
+	}
+	if err != nil {
+		return nil, NewImportError(CODEFORCES, err)
+	}
+	for _, result := range dump.Result {
+		if !madeBy(result, handle) {
+			return nil, NewImportError(CODEFORCES, fmt.Errorf("submission %v is not made by %s", result["id"], handle))
+		}
+	}
+	// Uploaded problems are not trusted for the metadata shared by all the users
+	subs, err = convertSubmissions(dump.Result, false)
+	if err != nil {
+		return nil, NewImportError(CODEFORCES, err)
+	}
+	common.SortSubmissions(subs)
+	return subs, nil
+}
+
+// Reports whether the handle is among the authors of the submission, who are many for the teams
+func madeBy(result map[string]interface{}, handle string) bool {
+	author, _ := result["author"].(map[string]interface{})
+	members, _ := author["members"].([]interface{})
+	for _, m := range members {
+		member, _ := m.(map[string]interface{})
+		if h, _ := member["handle"].(string); strings.EqualFold(h, handle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package codeforces
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Problem store recording the problems saved to it
+type savingStore struct {
+	saved []types.ProblemMetadata
+}
+
+func (s *savingStore) Problem(string) (types.ProblemMetadata, bool, error) {
+	return types.ProblemMetadata{}, false, nil
+}
+
+func (s *savingStore) SaveProblem(problem types.ProblemMetadata) error {
+	s.saved = append(s.saved, problem)
+	return nil
+}
+
+// Dump of user.status with a submission in a contest and a team one in the gym
+const statusDump = `{"status":"OK","result":[
+	{"id":60000001,"contestId":4,"creationTimeSeconds":1580000000,"author":{"members":[{"handle":"Alice"}]},
+	 "problem":{"contestId":4,"index":"A","name":"Watermelon","points":500,"rating":800,"tags":["math"]},
+	 "programmingLanguage":"GNU C++17","verdict":"OK"},
+	{"id":60000002,"contestId":100001,"creationTimeSeconds":1580001000,"author":{"members":[{"handle":"bob"},{"handle":"alice"}]},
+	 "problem":{"contestId":100001,"index":"B","name":"Team Problem","tags":[]},
+	 "programmingLanguage":"Python 3","verdict":"WRONG_ANSWER"}
+]}`
+
+func TestParseExport(t *testing.T) {
+	Convey("Subject: Codeforces user.status dumps\n", t, func() {
+		Convey("Submissions should be converted latest first", func() {
+			subs, err := parseExport("alice", strings.NewReader(statusDump))
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 2)
+			So(subs[0].Status, ShouldEqual, StatusWrongAnswer)
+			So(subs[0].SubmissionURL, ShouldEqual, "http://codeforces.com/gym/100001/submission/60000002")
+			So(subs[1].Status, ShouldEqual, StatusCorrect)
+			So(subs[1].URL, ShouldEqual, "http://codeforces.com/problemset/problem/4/A")
+			So(subs[1].Points, ShouldEqual, 500)
+			So(subs[1].Tags, ShouldResemble, []string{"math"})
+		})
+		Convey("Uploaded problems should not be shared with the other users", func() {
+			store := &savingStore{}
+			common.UseProblemStore(store)
+			_, err := parseExport("alice", strings.NewReader(statusDump))
+			So(err, ShouldBeNil)
+			So(store.saved, ShouldBeEmpty)
+		})
+		Convey("Result of the dump should be accepted on its own", func() {
+			result := statusDump[strings.Index(statusDump, "[") : len(statusDump)-1]
+			subs, err := parseExport("alice", strings.NewReader(result))
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 2)
+		})
+		Convey("Submissions of other handles should be rejected", func() {
+			_, err := parseExport("bob", strings.NewReader(statusDump))
+			So(IsImportError(err), ShouldBeTrue)
+		})
+		Convey("Malformed dumps should be rejected", func() {
+			_, err := parseExport("alice", strings.NewReader(`{"status":"FAILED","comment":"handle: not found"}`))
+			So(IsImportError(err), ShouldBeTrue)
+			_, err = parseExport("alice", strings.NewReader(`[{"id":1,"author":{"members":[{"handle":"alice"}]},"problem":{"name":"Watermelon"}}]`))
+			So(IsImportError(err), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package codeforces
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gocolly/colly"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Reports whether the token is in the name or organization of the user, or in the source
+// of a submission made after the given time which failed to compile
+func (s Scrapper) HasToken(token string, since time.Time) (bool, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	profile, err := s.GetProfileInfo()
+	if err != nil {
+		return false, err
+	}
+	if common.ProfileHasToken(profile, token) {
+		return true, nil
+	}
+	// Proof is expected among the latest submissions of the user
+	subs, err := getCodeforcesSubmissionParts(s.Fetcher, s.Handle, 1, hub, s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+	for _, sub := range subs {
+		if !sub.CreationDate.After(since) {
+			break
+		}
+		if sub.Status != StatusCompilationError || sub.SubmissionURL == "" {
+			continue
+		}
+		found, err := s.sourceHasToken(sub.SubmissionURL, token)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+func (s Scrapper) sourceHasToken(submissionURL string, token string) (bool, error) {
+	c := common.NewCollector(s.Fetcher, s.Context)
+	var found bool
+	c.OnHTML("#program-source-text", func(e *colly.HTMLElement) {
+		found = strings.Contains(e.Text, token)
+	})
+	err := common.Visit(c, CODEFORCES, submissionURL)
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+	return found, nil
+}
@@ -0,0 +1,52 @@
+package codeforces
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/getsentry/sentry-go"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns the problems of the codeforces problemset, gym problems are not part of it
+func (s Scrapper) GetProblems() ([]types.Problem, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	data, err := callAPI(s.Fetcher, "http://codeforces.com/api/problemset.problems", hub, s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	var problemset types.CodeforcesProblems
+	err = json.Unmarshal(data, &problemset)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(data),
+		}, nil)
+		hub.CaptureException(err)
+		log.Println(err.Error())
+		return nil, common.ParseError(CODEFORCES, err)
+	}
+	problems := make([]types.Problem, len(problemset.Result.Problems))
+	for i, p := range problemset.Result.Problems {
+		contest := strconv.Itoa(p.ContestID)
+		problems[i] = types.Problem{
+			ID:       types.ProblemID(CODEFORCES, contest, p.Index),
+			Platform: CODEFORCES,
+			Contest:  contest,
+			Index:    p.Index,
+			Name:     p.Name,
+			URL:      "http://codeforces.com/problemset/problem/" + contest + "/" + p.Index,
+			Tags:     p.Tags,
+			Rating:   p.Rating,
+		}
+	}
+	return problems, nil
+}
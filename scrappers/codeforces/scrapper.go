@@ -8,176 +8,313 @@ import (
 	"log"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/getsentry/sentry-go"
 
 	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/types"
 	"github.com/mdg-iitr/Codephile/scrappers/common"
 )
 
+// Attempts made for a call when codeforces reports that the call limit is exceeded
+const maxAttempts = 3
+
+// Gym contests have IDs from 100000 onwards, their problems are not part of the problemset
+const gymContestStart = 100000
+
 type Scrapper struct {
 	Handle  string
 	Context context.Context
+	Fetcher common.Fetcher
 }
 
-func (s Scrapper) GetProfileInfo() types.ProfileInfo {
-	hub := sentry.GetHubFromContext(s.Context)
-	if hub == nil {
-		hub = sentry.CurrentHub()
-	}
-	var profile types.ProfileInfo
-	requestUrl := "http://codeforces.com/api/user.info?handles=" + s.Handle
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site:          CODEFORCES,
+		Name:          "Codeforces",
+		URL:           "http://codeforces.com",
+		RatedContests: true,
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
+		ParseExport: parseExport,
+	})
+}
+
+// Envelope of every codeforces API response
+type apiResponse struct {
+	Status  string `json:"status"`
+	Comment string `json:"comment"`
+}
+
+// Calls the codeforces API and returns the response once it reports success.
+// Failures reported by the API are converted into typed errors.
+// Retries are given up once the context is done
+func callAPI(f common.Fetcher, path string, hub *sentry.Hub, ctx context.Context) ([]byte, error) {
 	var err error
-	for attempt := 1; attempt < 10; attempt++ {
-		time.Sleep(time.Second * time.Duration(attempt))
-		data, _ := common.HitGetRequest(requestUrl)
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		// Requests are paced by the fetcher, so only the retries back off
+		if sleepErr := common.Sleep(ctx, time.Second*time.Duration(attempt)); sleepErr != nil {
+			return nil, sleepErr
+		}
+		var data []byte
+		data, err = common.Get(f, CODEFORCES, path, ctx)
 		if data == nil {
-			log.Println(errors.New("GetRequest failed. Please check connection status"))
-			hub.CaptureException(errors.New("GetRequest failed. Please check connection status"))
+			return nil, err
 		}
-		err = json.Unmarshal(data, &profile)
-		if err == nil {
-			break
+		var resp apiResponse
+		if jsonErr := json.Unmarshal(data, &resp); jsonErr != nil {
+			// Error pages of codeforces are not JSON
+			if err != nil {
+				return nil, err
+			}
+			hub.AddBreadcrumb(&sentry.Breadcrumb{
+				Category: "JSON parse error",
+				Message:  string(data),
+			}, nil)
+			hub.CaptureException(jsonErr)
+			return nil, common.ParseError(CODEFORCES, jsonErr)
+		}
+		switch {
+		case resp.Status == "OK":
+			return data, nil
+		case strings.Contains(resp.Comment, "not found"):
+			return nil, NewScrapeError(HandleNotFound, CODEFORCES, errors.New(resp.Comment))
+		case strings.Contains(resp.Comment, "limit exceeded"):
+			log.Println("Codeforces call limit exceeded. Retrying...")
+			err = NewScrapeError(RateLimited, CODEFORCES, errors.New(resp.Comment))
+		default:
+			return nil, NewScrapeError(PlatformUnavailable, CODEFORCES, errors.New(resp.Comment))
 		}
+	}
+	return nil, err
+}
+
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	data, err := callAPI(s.Fetcher, "http://codeforces.com/api/user.info?handles="+url.QueryEscape(s.Handle), hub, s.Context)
+	if err != nil {
 		log.Println(err.Error())
+		return types.ProfileInfo{}, err
+	}
+	var info types.CodeforcesProfileInfo
+	err = json.Unmarshal(data, &info)
+	if err == nil && len(info.Result) == 0 {
+		err = errors.New("user missing in the response")
+	}
+	if err != nil {
 		hub.AddBreadcrumb(&sentry.Breadcrumb{
 			Category: "JSON parse error",
 			Message:  string(data),
 		}, nil)
-	}
-	if err != nil {
 		hub.CaptureException(err)
+		log.Println(err.Error())
+		return types.ProfileInfo{}, common.ParseError(CODEFORCES, err)
 	}
-	return profile
+	user := info.Result[0]
+	profile := types.ProfileInfo{
+		Name:     user.FirstName + user.LastName,
+		UserName: user.Handle,
+		School:   user.Organization,
+	}
+	profile.Rating = user.Rating
+	profile.MaxRating = user.MaxRating
+	// Rank is the title of the rating, eg. expert
+	profile.RankTitle = user.Rank
+	return profile, nil
 }
 
 // Calls the codeforces submission API and return the response in same format
-func callCodeforcesAPI(handle string, afterIndex int, hub *sentry.Hub) (types.CodeforcesSubmissions, error) {
-	url := "http://codeforces.com/api/user.status?handle=" + handle + "&from=" + strconv.Itoa(afterIndex) + "&count=50"
-	fmt.Println(url)
-	data, statusCode := common.HitGetRequest(url)
-	if data == nil {
-		return types.CodeforcesSubmissions{}, errors.New("GetRequest failed. Please check connection status")
+func callCodeforcesAPI(f common.Fetcher, handle string, afterIndex int, hub *sentry.Hub, ctx context.Context) (types.CodeforcesSubmissions, error) {
+	path := "http://codeforces.com/api/user.status?handle=" + url.QueryEscape(handle) + "&from=" + strconv.Itoa(afterIndex) + "&count=50"
+	fmt.Println(path)
+	data, err := callAPI(f, path, hub, ctx)
+	if err != nil {
+		return types.CodeforcesSubmissions{}, err
 	}
 	var codeforcesSubmission types.CodeforcesSubmissions
-	err := json.Unmarshal(data, &codeforcesSubmission)
+	err = json.Unmarshal(data, &codeforcesSubmission)
 	if err != nil {
 		hub.AddBreadcrumb(&sentry.Breadcrumb{
 			Category: "JSON parse error",
 			Message:  string(data),
 		}, nil)
-		// Dont unnecessarily report error when API limit exceeds
-		if statusCode != 503 {
-			hub.CaptureException(err)
-		}
+		hub.CaptureException(err)
 		log.Println(err.Error())
-		return types.CodeforcesSubmissions{}, err
+		return types.CodeforcesSubmissions{}, common.ParseError(CODEFORCES, err)
 	}
 	return codeforcesSubmission, nil
 }
 
 //Get submissions of a user after an index.
 //Returns an error if unsuccessful
-//On receiving the error caller should return empty submission list
-func getCodeforcesSubmissionParts(handle string, afterIndex int, hub *sentry.Hub) ([]types.Submission, error) {
-	codeforcesSubmission, _ := callCodeforcesAPI(handle, afterIndex, hub)
-	if codeforcesSubmission.Status != "OK" {
-		log.Println("Codeforces submission could not be retrieved. Retrying...")
-		var newCodeforcesSub types.CodeforcesSubmissions
-		for attempt := 1; attempt < 10; attempt++ {
-			time.Sleep(time.Second * time.Duration(attempt))
-			newCodeforcesSub, _ = callCodeforcesAPI(handle, afterIndex, hub)
-			if newCodeforcesSub.Status == "OK" {
-				codeforcesSubmission = newCodeforcesSub
-				break
-			}
-		}
-		if newCodeforcesSub.Status == "" || newCodeforcesSub.Status == "FAILED" {
-			hub.CaptureException(errors.New("codeforces API repeatedly returned FAILED"))
-			return nil, errors.New("codeforces API repeatedly returned FAILED")
-		}
+func getCodeforcesSubmissionParts(f common.Fetcher, handle string, afterIndex int, hub *sentry.Hub, ctx context.Context) ([]types.Submission, error) {
+	codeforcesSubmission, err := callCodeforcesAPI(f, handle, afterIndex, hub, ctx)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := convertSubmissions(codeforcesSubmission.Result, true)
+	if err != nil {
+		hub.CaptureException(err)
+		return nil, common.ParseError(CODEFORCES, err)
 	}
-	submissions := make([]types.Submission, len(codeforcesSubmission.Result))
-	for i, result := range codeforcesSubmission.Result {
-		problem := result["problem"].(map[string]interface{})
+	return subs, nil
+}
+
+// Converts the submissions returned by user.status into the ones of codephile. Their problems
+// are shared with the other scrappers if remember is set, ie. when codeforces returned them.
+// Returns an error naming the field if a submission lacks one or has it of another type
+func convertSubmissions(results []map[string]interface{}, remember bool) ([]types.Submission, error) {
+	submissions := make([]types.Submission, len(results))
+	// Problems are shared with the other scrappers, once for a page
+	known := map[string]bool{}
+	for i, result := range results {
+		problem, ok := result["problem"].(map[string]interface{})
+		if !ok {
+			return nil, malformedField(i, "problem")
+		}
 		var status string
-		switch result["verdict"].(string) {
-		case "FAILED":
-			status = StatusWrongAnswer
+		// Verdict is absent for the submissions in queue
+		verdict, _ := result["verdict"].(string)
+		switch verdict {
+		case "", "TESTING":
+			status = StatusPending
 		case "OK":
 			status = StatusCorrect
 		case "PARTIAL":
 			status = StatusPartial
 		case "COMPILATION_ERROR":
 			status = StatusCompilationError
-		case "RUNTIME_ERROR":
+		case "RUNTIME_ERROR", "SECURITY_VIOLATED":
 			status = StatusRuntimeError
 		case "WRONG_ANSWER":
 			status = StatusWrongAnswer
+		case "PRESENTATION_ERROR":
+			status = StatusPresentationError
 		case "TIME_LIMIT_EXCEEDED":
 			status = StatusTimeLimitExceeded
 		case "MEMORY_LIMIT_EXCEEDED":
 			status = StatusMemoryLimitExceeded
+		case "IDLENESS_LIMIT_EXCEEDED":
+			status = StatusIdlenessLimitExceeded
+		case "FAILED", "CRASHED", "INPUT_PREPARATION_CRASHED":
+			status = StatusInternalError
+		case "CHALLENGED":
+			status = StatusChallenged
+		case "SKIPPED":
+			status = StatusSkipped
+		case "REJECTED":
+			status = StatusRejected
 		default:
 			status = StatusWrongAnswer
 		}
 		submissions[i].Status = status
-		submissions[i].Language = result["programmingLanguage"].(string)
-		submissions[i].Name = problem["name"].(string)
+		if submissions[i].Language, ok = result["programmingLanguage"].(string); !ok {
+			return nil, malformedField(i, "programmingLanguage")
+		}
+		if submissions[i].Name, ok = problem["name"].(string); !ok {
+			return nil, malformedField(i, "problem.name")
+		}
+		id, ok := result["id"].(float64)
+		if !ok {
+			return nil, malformedField(i, "id")
+		}
+		submissions[i].ID = strconv.FormatInt(int64(id), 10)
 		if problem["contestId"] != nil {
-			submissions[i].URL = "http://codeforces.com/problemset/problem/" + strconv.Itoa(int(problem["contestId"].(float64))) + "/" + problem["index"].(string)
+			contestID, ok := problem["contestId"].(float64)
+			if !ok {
+				return nil, malformedField(i, "problem.contestId")
+			}
+			index, ok := problem["index"].(string)
+			if !ok {
+				return nil, malformedField(i, "problem.index")
+			}
+			contest := strconv.Itoa(int(contestID))
+			submissions[i].ProblemID = types.ProblemID(CODEFORCES, contest, index)
+			if int(contestID) >= gymContestStart {
+				submissions[i].URL = "http://codeforces.com/gym/" + contest + "/problem/" + index
+				submissions[i].SubmissionURL = "http://codeforces.com/gym/" + contest + "/submission/" + submissions[i].ID
+			} else {
+				submissions[i].URL = "http://codeforces.com/problemset/problem/" + contest + "/" + index
+				submissions[i].SubmissionURL = "http://codeforces.com/contest/" + contest + "/submission/" + submissions[i].ID
+			}
 		} else {
 			submissions[i].URL = ""
 		}
-		submissions[i].CreationDate = time.Unix(int64(result["creationTimeSeconds"].(float64)), 0)
-		if problem["points"] != nil {
-			submissions[i].Points = int(problem["points"].(float64))
+		created, ok := result["creationTimeSeconds"].(float64)
+		if !ok {
+			return nil, malformedField(i, "creationTimeSeconds")
 		}
-		if problem["rating"] != nil {
-			submissions[i].Rating = int(problem["rating"].(float64))
+		submissions[i].CreationDate = time.Unix(int64(created), 0)
+		// Points and rating are optional, they are left out if of another type
+		if points, ok := problem["points"].(float64); ok {
+			submissions[i].Points = int(points)
+		}
+		if rating, ok := problem["rating"].(float64); ok {
+			submissions[i].Rating = int(rating)
+		}
+		tags, _ := problem["tags"].([]interface{})
+		for _, x := range tags {
+			if tag, ok := x.(string); ok {
+				submissions[i].Tags = append(submissions[i].Tags, tag)
+			}
 		}
-		for _, x := range problem["tags"].([]interface{}) {
-			submissions[i].Tags = append(submissions[i].Tags, x.(string))
+		if remember && submissions[i].URL != "" && !known[submissions[i].URL] {
+			known[submissions[i].URL] = true
+			common.RememberProblem(types.ProblemMetadata{
+				URL:    submissions[i].URL,
+				Name:   submissions[i].Name,
+				Tags:   submissions[i].Tags,
+				Rating: submissions[i].Rating,
+			})
 		}
 	}
 	return submissions, nil
 }
 
-func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
+// Error for the submission at index i lacking the field or having it of another type
+func malformedField(i int, field string) error {
+	return fmt.Errorf("submission %d has no valid %s", i, field)
+}
+
+// Returns the submissions made after the given time, latest first.
+// On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	var oldestSubIndex, current int
+	var current int
 	var oldestSubFound = false
 	var subs []types.Submission
 	//Fetch submission until oldest submission not found
 	for !oldestSubFound {
-		newSub, err := getCodeforcesSubmissionParts(s.Handle, current+1, hub)
+		newSub, err := getCodeforcesSubmissionParts(s.Fetcher, s.Handle, current+1, hub, s.Context)
 		if err != nil {
 			log.Println(err.Error())
-			return nil
-		}
-		//Check for repetition of previous fetched submission
-		if len(newSub) != 0 {
-			for i, sub := range newSub {
-				subs = append(subs, sub)
-				oldestSubIndex = current + i + 1
-				if sub.CreationDate.Equal(after) || sub.CreationDate.Before(after) {
-					oldestSubFound = true
-					break
-				}
-			}
-			//50 submissions per page
-			current += 50
-		} else {
+			return subs, err
+		}
+		if len(newSub) == 0 {
 			break
 		}
+		for _, sub := range newSub {
+			// Submissions up to the last fetched one are already stored
+			if !sub.CreationDate.After(after) {
+				oldestSubFound = true
+				break
+			}
+			subs = append(subs, sub)
+		}
+		//50 submissions per page
+		current += 50
 	}
-	subs = subs[0:oldestSubIndex]
-	return subs
+	return subs, nil
 }
 
 func (s Scrapper) CheckHandle() (bool, error) {
@@ -185,28 +322,48 @@ func (s Scrapper) CheckHandle() (bool, error) {
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	data, _ := common.HitGetRequest("http://codeforces.com/api/user.info?handles=" + url.PathEscape(s.Handle))
-	var i interface{}
-	err := json.Unmarshal(data, &i)
+	_, err := callAPI(s.Fetcher, "http://codeforces.com/api/user.info?handles="+url.QueryEscape(s.Handle), hub, s.Context)
+	if ScrapeErrorKind(err) == HandleNotFound {
+		return false, nil
+	}
 	if err != nil {
-		for attempt := 1; attempt < 5; attempt++ {
-			time.Sleep(time.Millisecond * time.Duration(attempt) * 100)
-			data, _ := common.HitGetRequest("http://codeforces.com/api/user.info?handles=" + s.Handle)
-			var i interface{}
-			err = json.Unmarshal(data, &i)
-			if err == nil {
-				break
-			}
-			hub.AddBreadcrumb(&sentry.Breadcrumb{
-				Category: "JSON parse error",
-				Message:  string(data),
-			}, nil)
-			log.Println(err.Error())
-		}
+		log.Println(err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	data, err := callAPI(s.Fetcher, "http://codeforces.com/api/user.rating?handle="+url.QueryEscape(s.Handle), hub, s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
 	}
+	var ratings types.CodeforcesRatings
+	err = json.Unmarshal(data, &ratings)
 	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(data),
+		}, nil)
 		hub.CaptureException(err)
-		return false, err
+		log.Println(err.Error())
+		return nil, common.ParseError(CODEFORCES, err)
+	}
+	graph := make(types.RatingGraph, len(ratings.Result))
+	for i, change := range ratings.Result {
+		graph[i] = types.RatingChange{
+			ContestName:  change.ContestName,
+			ContestURL:   "http://codeforces.com/contest/" + strconv.Itoa(change.ContestID),
+			Rank:         change.Rank,
+			OldRating:    change.OldRating,
+			NewRating:    change.NewRating,
+			CreationDate: time.Unix(change.RatingUpdateTimeSeconds, 0),
+		}
 	}
-	return i.(map[string]interface{})["status"] != "FAILED", nil
+	return graph, nil
 }
@@ -0,0 +1,182 @@
+package codeforces
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	Convey("Subject: Codeforces handle validation\n", t, func() {
+		valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeTrue)
+		valid, err = newScrapper(t, "nobody", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: Codeforces profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "AliceLiddell")
+		So(profile.School, ShouldEqual, "IIT Roorkee")
+		So(profile.Rating, ShouldEqual, 1900)
+		So(profile.MaxRating, ShouldEqual, 1950)
+		So(profile.RankTitle, ShouldEqual, "candidate master")
+
+		Convey("Unknown handles should be reported", func() {
+			_, err := newScrapper(t, "nobody", "profile").GetProfileInfo()
+			So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+		})
+	})
+}
+
+func TestGetRatingHistory(t *testing.T) {
+	Convey("Subject: Codeforces rating history\n", t, func() {
+		graph, err := newScrapper(t, "alice", "profile").GetRatingHistory()
+		So(err, ShouldBeNil)
+		So(len(graph), ShouldEqual, 2)
+		So(graph[0].ContestName, ShouldEqual, "Codeforces Round #617 (Div. 3)")
+		So(graph[0].ContestURL, ShouldEqual, "http://codeforces.com/contest/1300")
+		So(graph[0].Rank, ShouldEqual, 250)
+		So(graph[0].OldRating, ShouldEqual, 1500)
+		So(graph[0].NewRating, ShouldEqual, 1650)
+		So(graph[1].CreationDate.Unix(), ShouldEqual, 1581528000)
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: Codeforces submissions\n", t, func() {
+		subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		So(len(subs), ShouldEqual, 4)
+		So(subs[0].Name, ShouldEqual, "Array Sharpening")
+		So(subs[0].ID, ShouldEqual, "70000002")
+		So(subs[0].URL, ShouldEqual, "http://codeforces.com/problemset/problem/1291/B")
+		So(subs[0].ProblemID, ShouldEqual, "codeforces/1291/B")
+		So(subs[0].SubmissionURL, ShouldEqual, "http://codeforces.com/contest/1291/submission/70000002")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[0].Points, ShouldEqual, 1000)
+		So(subs[0].Rating, ShouldEqual, 1300)
+		So(subs[0].Tags, ShouldResemble, []string{"greedy", "implementation"})
+		So(subs[1].Status, ShouldEqual, StatusWrongAnswer)
+		So(subs[2].Status, ShouldEqual, StatusTimeLimitExceeded)
+		So(subs[2].Language, ShouldEqual, "Python 3")
+		So(subs[2].CreationDate.Unix(), ShouldEqual, 1581000000)
+
+		Convey("Gym submissions should link to the gym", func() {
+			So(subs[3].URL, ShouldEqual, "http://codeforces.com/gym/102500/problem/C")
+			So(subs[3].SubmissionURL, ShouldEqual, "http://codeforces.com/gym/102500/submission/69999999")
+			So(subs[3].ProblemID, ShouldEqual, "codeforces/102500/C")
+		})
+
+		Convey("Submissions after the last fetched one should be returned", func() {
+			subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Unix(1581000100, 0))
+			So(err, ShouldBeNil)
+			So(len(subs), ShouldEqual, 1)
+			So(subs[0].CreationDate.Unix(), ShouldEqual, 1581000200)
+		})
+	})
+}
+
+func TestVerdicts(t *testing.T) {
+	Convey("Subject: Codeforces verdicts\n", t, func() {
+		subs, err := newScrapper(t, "bob", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		var statuses []string
+		for _, sub := range subs {
+			statuses = append(statuses, sub.Status)
+		}
+		So(statuses, ShouldResemble, []string{StatusPending, StatusPending, StatusChallenged, StatusSkipped,
+			StatusIdlenessLimitExceeded, StatusPresentationError, StatusInternalError, StatusRejected})
+	})
+}
+
+func TestConvertSubmissions(t *testing.T) {
+	Convey("Subject: Codeforces submissions of unexpected shape\n", t, func() {
+		result := map[string]interface{}{
+			"id":                  float64(1),
+			"creationTimeSeconds": float64(1581000000),
+			"programmingLanguage": "GNU C++17",
+			"problem":             map[string]interface{}{"contestId": float64(4), "index": "A", "name": "Watermelon"},
+		}
+		subs, err := convertSubmissions([]map[string]interface{}{result}, false)
+		So(err, ShouldBeNil)
+		So(subs[0].URL, ShouldEqual, "http://codeforces.com/problemset/problem/4/A")
+		So(subs[0].Tags, ShouldBeEmpty)
+		Convey("Submissions lacking a field should be an error rather than a panic", func() {
+			delete(result, "programmingLanguage")
+			_, err := convertSubmissions([]map[string]interface{}{result}, false)
+			So(err, ShouldNotBeNil)
+			result["programmingLanguage"] = "GNU C++17"
+			result["problem"] = map[string]interface{}{"contestId": "4", "index": "A", "name": "Watermelon"}
+			_, err = convertSubmissions([]map[string]interface{}{result}, false)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestGetProblems(t *testing.T) {
+	Convey("Subject: Codeforces problemset\n", t, func() {
+		problems, err := newScrapper(t, "", "problems").GetProblems()
+		So(err, ShouldBeNil)
+		So(len(problems), ShouldEqual, 3)
+		So(problems[0].ID, ShouldEqual, "codeforces/1291/B")
+		So(problems[0].Contest, ShouldEqual, "1291")
+		So(problems[0].Index, ShouldEqual, "B")
+		So(problems[0].Name, ShouldEqual, "Array Sharpening")
+		So(problems[0].URL, ShouldEqual, "http://codeforces.com/problemset/problem/1291/B")
+		So(problems[0].Rating, ShouldEqual, 1300)
+		So(problems[0].Tags, ShouldResemble, []string{"greedy", "implementation"})
+		So(problems[2].Rating, ShouldEqual, 0)
+	})
+}
+
+func TestHasToken(t *testing.T) {
+	Convey("Subject: Codeforces handle ownership\n", t, func() {
+		token := "codephile-1a2b3c4d5e6f"
+		issued := time.Unix(1581000000, 0)
+
+		Convey("Token in the organization should prove the ownership", func() {
+			ok, err := newScrapper(t, "carol", "ownership").HasToken(token, issued)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+		})
+		Convey("Token in a submission which failed to compile should prove the ownership", func() {
+			ok, err := newScrapper(t, "alice", "ownership").HasToken(token, issued)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+		})
+		Convey("Submissions made before the token was issued should not prove the ownership", func() {
+			ok, err := newScrapper(t, "alice", "ownership").HasToken(token, time.Unix(1581000250, 0))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("Token in the other submissions should not prove the ownership", func() {
+			ok, err := newScrapper(t, "bob", "ownership").HasToken(token, issued)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
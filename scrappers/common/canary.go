@@ -0,0 +1,175 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// Statuses the scrappers convert the verdicts of the sites to
+var knownStatuses = map[string]bool{
+	StatusCorrect: true, StatusWrongAnswer: true, StatusCompilationError: true, StatusRuntimeError: true,
+	StatusTimeLimitExceeded: true, StatusMemoryLimitExceeded: true, StatusPartial: true,
+	StatusPresentationError: true, StatusIdlenessLimitExceeded: true, StatusOutputLimitExceeded: true,
+	StatusInternalError: true, StatusChallenged: true, StatusSkipped: true, StatusRejected: true,
+	StatusPending: true,
+}
+
+// Kind of the failures which are not scrapper errors
+const unknownFailure = "unknown"
+
+// CanaryReport is the outcome of running a scrapper against a reference handle, whose
+// profile and submissions are known to exist. The sites changing their responses show up
+// as parse failures or as results breaking the invariants, eg. submissions without a status
+type CanaryReport struct {
+	Site   string
+	Handle string
+	At     time.Time
+	// Time taken by the run
+	Duration time.Duration
+	// Error returned by the scrapper, the run is stopped at it
+	Err error
+	// Invariants broken by the results of the scrapper
+	Violations []string
+}
+
+func (r CanaryReport) Healthy() bool {
+	return r.Err == nil && len(r.Violations) == 0
+}
+
+// Kind of the failure of the run, empty if it is healthy. Broken invariants are parse failures,
+// as the scrapper could not make sense of the response
+func (r CanaryReport) Kind() string {
+	if r.Err != nil {
+		if kind := ScrapeErrorKind(r.Err); kind != "" {
+			return kind
+		}
+		return unknownFailure
+	}
+	if len(r.Violations) != 0 {
+		return ParseFailure
+	}
+	return ""
+}
+
+func (r *CanaryReport) violate(format string, args ...interface{}) {
+	r.Violations = append(r.Violations, fmt.Sprintf(format, args...))
+}
+
+// Runs the scrapper of the platform against the reference handle, and checks the results
+// against the invariants. Submissions made after the given time are expected
+func RunCanary(p Platform, handle string, s Scrapper, since time.Time) CanaryReport {
+	report := CanaryReport{Site: p.Site, Handle: handle, At: time.Now()}
+	defer func() {
+		report.Duration = time.Since(report.At)
+	}()
+	steps := []func(){
+		func() {
+			valid, err := s.CheckHandle()
+			report.Err = err
+			if err == nil && !valid {
+				report.violate("handle %s is reported missing", handle)
+			}
+		},
+		func() {
+			profile, err := s.GetProfileInfo()
+			report.Err = err
+			if err == nil {
+				checkProfile(&report, profile)
+			}
+		},
+		func() {
+			subs, err := s.GetSubmissions(since)
+			report.Err = err
+			if err == nil {
+				checkSubmissions(&report, p, subs, since)
+			}
+		},
+		func() {
+			ratings, err := s.GetRatingHistory()
+			report.Err = err
+			if err == nil {
+				checkRatings(&report, p, ratings)
+			}
+		},
+	}
+	for _, step := range steps {
+		runStep(&report, step)
+		// Rest of the calls would fail the same way
+		if report.Err != nil {
+			break
+		}
+	}
+	return report
+}
+
+// Runs a step of the canary. Unchecked assertions of the scrappers on the responses panic
+// once the sites change them, they are reported as parse failures
+func runStep(report *CanaryReport, step func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			report.Err = ParseError(report.Site, fmt.Errorf("scrapper panicked: %v", r))
+		}
+	}()
+	step()
+}
+
+func checkProfile(report *CanaryReport, profile types.ProfileInfo) {
+	if profile.UserName == "" {
+		report.violate("profile has no username")
+	}
+	stats := profile.ProfileStats
+	if stats.Rating < 0 || stats.MaxRating < 0 || stats.GlobalRank < 0 || stats.CountryRank < 0 || stats.ContestsAttended < 0 {
+		report.violate("profile has negative stats: %+v", stats)
+	}
+	if stats.MaxRating != 0 && stats.Rating > stats.MaxRating {
+		report.violate("profile rating %d is above the max rating %d", stats.Rating, stats.MaxRating)
+	}
+	if stats.Accuracy < 0 || stats.Accuracy > 1 {
+		report.violate("profile accuracy %f is not a fraction", stats.Accuracy)
+	}
+}
+
+func checkSubmissions(report *CanaryReport, p Platform, subs []types.Submission, since time.Time) {
+	// Reference handles are active, so no submissions means that they are not parsed
+	if len(subs) == 0 {
+		report.violate("no submissions are returned after %s", since.Format(time.RFC3339))
+		return
+	}
+	future := time.Now().Add(24 * time.Hour)
+	for i, sub := range subs {
+		switch {
+		case sub.Name == "":
+			report.violate("submission %d has no problem name", i)
+		case !strings.HasPrefix(sub.URL, p.URL):
+			// Submissions are matched to the site by the prefix of their URL
+			report.violate("submission %d has URL %q outside %s", i, sub.URL, p.URL)
+		case !knownStatuses[sub.Status]:
+			report.violate("submission %d has unknown status %q", i, sub.Status)
+		case !sub.CreationDate.After(since) || sub.CreationDate.After(future):
+			report.violate("submission %d is made at %s, outside the fetched period", i, sub.CreationDate)
+		case i > 0 && sub.CreationDate.After(subs[i-1].CreationDate):
+			report.violate("submission %d is newer than the one before it", i)
+		}
+	}
+}
+
+func checkRatings(report *CanaryReport, p Platform, ratings types.RatingGraph) {
+	if !p.RatedContests {
+		return
+	}
+	// Reference handles have taken part in rated contests
+	if len(ratings) == 0 {
+		report.violate("rating history is empty")
+		return
+	}
+	for i, change := range ratings {
+		if change.ContestName == "" || change.CreationDate.IsZero() {
+			report.violate("rating change %d has no contest name or date", i)
+		}
+	}
+}
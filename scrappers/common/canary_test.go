@@ -0,0 +1,97 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// Scrapper returning fixed results
+type fakeScrapper struct {
+	valid     bool
+	handleErr error
+	profile   types.ProfileInfo
+	subs      []types.Submission
+	ratings   types.RatingGraph
+	panicky   bool
+}
+
+func (s fakeScrapper) CheckHandle() (bool, error) {
+	return s.valid, s.handleErr
+}
+
+func (s fakeScrapper) GetProfileInfo() (types.ProfileInfo, error) {
+	return s.profile, nil
+}
+
+func (s fakeScrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
+	if s.panicky {
+		var result map[string]interface{}
+		_ = result["problem"].(map[string]interface{})
+	}
+	return s.subs, nil
+}
+
+func (s fakeScrapper) GetRatingHistory() (types.RatingGraph, error) {
+	return s.ratings, nil
+}
+
+func TestRunCanary(t *testing.T) {
+	Convey("Subject: Scrapper canary\n", t, func() {
+		platform := Platform{Site: CODEFORCES, URL: "http://codeforces.com", RatedContests: true}
+		since := time.Now().Add(-48 * time.Hour)
+		healthy := fakeScrapper{
+			valid:   true,
+			profile: types.ProfileInfo{UserName: "tourist"},
+			subs: []types.Submission{
+				{Name: "Array Sharpening", URL: "http://codeforces.com/problemset/problem/1291/B", Status: StatusCorrect, CreationDate: time.Now().Add(-time.Hour)},
+				{Name: "Even But Not Even", URL: "http://codeforces.com/problemset/problem/1291/A", Status: StatusWrongAnswer, CreationDate: time.Now().Add(-2 * time.Hour)},
+			},
+			ratings: types.RatingGraph{{ContestName: "Codeforces Round #617 (Div. 3)", CreationDate: time.Now()}},
+		}
+
+		Convey("Expected results should be healthy", func() {
+			report := RunCanary(platform, "tourist", healthy, since)
+			So(report.Healthy(), ShouldBeTrue)
+			So(report.Kind(), ShouldEqual, "")
+			So(report.Site, ShouldEqual, CODEFORCES)
+		})
+		Convey("Broken invariants should be reported as parse failures", func() {
+			broken := healthy
+			broken.subs = append([]types.Submission{}, healthy.subs...)
+			broken.subs[1].Status = "Wrong answer on test 2"
+			broken.ratings = nil
+			report := RunCanary(platform, "tourist", broken, since)
+			So(report.Healthy(), ShouldBeFalse)
+			So(report.Kind(), ShouldEqual, ParseFailure)
+			So(len(report.Violations), ShouldEqual, 2)
+		})
+		Convey("No submissions should be reported", func() {
+			broken := healthy
+			broken.subs = nil
+			report := RunCanary(platform, "tourist", broken, since)
+			So(report.Violations, ShouldHaveLength, 1)
+		})
+		Convey("Panics of the scrappers should be reported as parse failures", func() {
+			broken := healthy
+			broken.panicky = true
+			report := RunCanary(platform, "tourist", broken, since)
+			So(report.Kind(), ShouldEqual, ParseFailure)
+			So(report.Err.Error(), ShouldContainSubstring, "panicked")
+		})
+		Convey("Run should stop at the first error", func() {
+			broken := healthy
+			broken.handleErr = NewScrapeError(RateLimited, CODEFORCES, errors.New("call limit exceeded"))
+			broken.subs = nil
+			report := RunCanary(platform, "tourist", broken, since)
+			So(report.Kind(), ShouldEqual, RateLimited)
+			So(report.Violations, ShouldBeEmpty)
+		})
+	})
+}
@@ -1,24 +1,70 @@
 package common
 
 import (
+	"bytes"
+	"context"
 	"io/ioutil"
 	"log"
 	"net/http"
-	"time"
 )
 
-func HitGetRequest(path string) ([]byte, int) {
-	client := http.Client{Timeout: time.Second * 10}
-	resp, err := client.Get(path)
+// Makes the request using the fetcher and returns the body along with the status code.
+// A nil fetcher falls back to the default fetcher
+func HitRequest(f Fetcher, req *http.Request) ([]byte, int, error) {
+	if f == nil {
+		f = DefaultFetcher()
+	}
+	resp, err := f.Do(req)
 	if err != nil {
 		log.Println(err)
-		return nil, 0
+		return nil, 0, err
 	}
 	defer resp.Body.Close() // nolint: errcheck
 	byteValue, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Println(err.Error())
-		return nil, 0
+		return nil, 0, err
+	}
+	return byteValue, resp.StatusCode, nil
+}
+
+// Get fetches the path and returns the body of the successful response. The request, along with
+// its waits for the rate limits, is given up once the context is done.
+// Failures are returned as typed scrapper errors of the site
+func Get(f Fetcher, site string, path string, ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Do(f, site, WithContext(req, ctx))
+}
+
+// Post is Get for POST requests
+func Post(f Fetcher, site string, path string, contentType string, body []byte, ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return Do(f, site, WithContext(req, ctx))
+}
+
+// Do makes the request, which carries the context of the scrapper, like Get
+func Do(f Fetcher, site string, req *http.Request) ([]byte, error) {
+	data, status, err := HitRequest(f, req)
+	if err != nil {
+		return nil, RequestError(site, err)
+	}
+	if err := StatusError(site, status); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+// WithContext returns the request carrying the context, or the request itself if there is none
+func WithContext(req *http.Request, ctx context.Context) *http.Request {
+	if ctx == nil {
+		return req
 	}
-	return byteValue, resp.StatusCode
+	return req.WithContext(ctx)
 }
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Records the contexts of the requests it is asked to make
+type contextFetcher struct {
+	contexts []context.Context
+}
+
+func (f *contextFetcher) Do(req *http.Request) (*http.Response, error) {
+	f.contexts = append(f.contexts, req.Context())
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("<html></html>")), Request: req}, nil
+}
+
+type contextKey struct{}
+
+func TestRequestContext(t *testing.T) {
+	Convey("Subject: Contexts of the requests\n", t, func() {
+		fetcher := &contextFetcher{}
+		ctx := context.WithValue(context.Background(), contextKey{}, "scrapper")
+
+		Convey("Requests should carry the context of the scrapper", func() {
+			_, err := Get(fetcher, "test", "http://example.com/", ctx)
+			So(err, ShouldBeNil)
+			_, err = Post(fetcher, "test", "http://example.com/", "application/json", []byte("{}"), ctx)
+			So(err, ShouldBeNil)
+			So(Visit(NewCollector(fetcher, ctx), "test", "http://example.com/"), ShouldBeNil)
+			So(fetcher.contexts, ShouldHaveLength, 3)
+			for _, c := range fetcher.contexts {
+				So(c.Value(contextKey{}), ShouldEqual, "scrapper")
+			}
+		})
+		Convey("Requests should be given up once the context is done", func() {
+			canceled, cancel := context.WithCancel(ctx)
+			cancel()
+			_, err := Get(NewThrottle(fetcher), "test", "http://example.com/", canceled)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gocolly/colly"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+// RequestError converts the failure of a request to the site into a typed scrapper error
+func RequestError(site string, err error) error {
+	// Errors of the colly collectors are wrapped by the http client
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if _, ok := err.(*ScrapeError); ok {
+		return err
+	}
+	// Cancellation is left for the caller to handle
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return err
+	}
+	return NewScrapeError(PlatformUnavailable, site, err)
+}
+
+// StatusError returns the typed scrapper error for the status of a response of the site,
+// nil for the successful ones
+func StatusError(site string, status int) error {
+	switch {
+	case status >= 200 && status < 300:
+		return nil
+	case status == http.StatusTooManyRequests:
+		return NewScrapeError(RateLimited, site, fmt.Errorf("status %d", status))
+	case status == http.StatusNotFound:
+		return NewScrapeError(HandleNotFound, site, fmt.Errorf("status %d", status))
+	default:
+		return NewScrapeError(PlatformUnavailable, site, fmt.Errorf("status %d", status))
+	}
+}
+
+// ParseError wraps the failure of parsing a response of the site
+func ParseError(site string, err error) error {
+	return NewScrapeError(ParseFailure, site, err)
+}
+
+// CollyError converts the failure of a colly collector into a typed scrapper error of the site
+func CollyError(site string, r *colly.Response, err error) error {
+	// Status is not set when the request itself failed
+	if r != nil && r.StatusCode != 0 {
+		return StatusError(site, r.StatusCode)
+	}
+	return RequestError(site, err)
+}
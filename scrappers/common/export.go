@@ -0,0 +1,147 @@
+package common
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// Columns of the CSV exports, the rest of them are optional
+var requiredColumns = []string{"name", "url", "status", "created_at"}
+
+// Verdicts spelled out in the exports, besides the statuses themselves
+var statusNames = map[string]string{
+	"accepted":              StatusCorrect,
+	"ok":                    StatusCorrect,
+	"wrong answer":          StatusWrongAnswer,
+	"compilation error":     StatusCompilationError,
+	"runtime error":         StatusRuntimeError,
+	"time limit exceeded":   StatusTimeLimitExceeded,
+	"memory limit exceeded": StatusMemoryLimitExceeded,
+	"partial":               StatusPartial,
+}
+
+// Parses the submissions of the site out of a CSV with a header row, latest first.
+// The columns are name, url, status and created_at, along with the optional id,
+// submission_url, language, points and tags, which are separated by semicolons.
+// Statuses are either the ones of codephile, eg. AC, or spelled out, eg. Accepted.
+// Creation dates are in RFC 3339 or in seconds since the epoch
+func ParseCSVExport(p Platform, r io.Reader) ([]types.Submission, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, NewImportError(p.Site, errors.New("export is empty"))
+	} else if err != nil {
+		return nil, NewImportError(p.Site, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, NewImportError(p.Site, errors.New("column "+name+" is missing"))
+		}
+	}
+	var subs []types.Submission
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, NewImportError(p.Site, err)
+		}
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		sub, err := csvSubmission(p, field)
+		if err != nil {
+			return nil, NewImportError(p.Site, fmt.Errorf("line %d: %v", line, err))
+		}
+		subs = append(subs, sub)
+	}
+	SortSubmissions(subs)
+	return subs, nil
+}
+
+func csvSubmission(p Platform, field func(string) string) (types.Submission, error) {
+	sub := types.Submission{
+		ID:            field("id"),
+		Name:          field("name"),
+		URL:           field("url"),
+		SubmissionURL: field("submission_url"),
+		Language:      field("language"),
+	}
+	if sub.Name == "" {
+		return sub, errors.New("name is empty")
+	}
+	// Submissions are matched to the site by the prefix of their URL
+	if !strings.HasPrefix(sub.URL, p.URL) {
+		return sub, fmt.Errorf("url %q is not of %s", sub.URL, p.URL)
+	}
+	status, err := parseStatus(field("status"))
+	if err != nil {
+		return sub, err
+	}
+	sub.Status = status
+	sub.CreationDate, err = parseCreationDate(field("created_at"))
+	if err != nil {
+		return sub, err
+	}
+	if points := field("points"); points != "" {
+		sub.Points, err = strconv.Atoi(points)
+		if err != nil {
+			return sub, fmt.Errorf("points %q is not a number", points)
+		}
+	} else if sub.Status == StatusCorrect {
+		sub.Points = 100
+	}
+	for _, tag := range strings.Split(field("tags"), ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			sub.Tags = append(sub.Tags, tag)
+		}
+	}
+	return sub, nil
+}
+
+func parseStatus(status string) (string, error) {
+	if knownStatuses[strings.ToUpper(status)] {
+		return strings.ToUpper(status), nil
+	}
+	if s, ok := statusNames[strings.ToLower(status)]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("status %q is unknown", status)
+}
+
+func parseCreationDate(date string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(date, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("created_at %q is neither RFC 3339 nor a unix time", date)
+	}
+	return t, nil
+}
+
+// Sorts the submissions latest first, as they are returned by the scrappers
+func SortSubmissions(subs []types.Submission) {
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].CreationDate.After(subs[j].CreationDate)
+	})
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+func TestParseCSVExport(t *testing.T) {
+	Convey("Subject: CSV exports of submissions\n", t, func() {
+		p := Platform{Site: SPOJ, Name: "SPOJ", URL: "https://www.spoj.com"}
+
+		Convey("Rows should be parsed latest first", func() {
+			export := "Name,URL,Status,Created_At,Language,Tags\n" +
+				"Prime Generator,https://www.spoj.com/problems/PRIME1/,wa,1580000000,C++,\n" +
+				"Prime Generator,https://www.spoj.com/problems/PRIME1/,Accepted,2020-02-01T10:00:00Z,C++,math; sieve\n"
+			subs, err := ParseCSVExport(p, strings.NewReader(export))
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 2)
+			So(subs[0].Status, ShouldEqual, StatusCorrect)
+			So(subs[0].Points, ShouldEqual, 100)
+			So(subs[0].Tags, ShouldResemble, []string{"math", "sieve"})
+			So(subs[0].CreationDate.Equal(time.Date(2020, 2, 1, 10, 0, 0, 0, time.UTC)), ShouldBeTrue)
+			So(subs[1].Status, ShouldEqual, StatusWrongAnswer)
+			So(subs[1].Points, ShouldEqual, 0)
+			So(subs[1].CreationDate.Unix(), ShouldEqual, 1580000000)
+		})
+		Convey("Missing columns should be reported", func() {
+			_, err := ParseCSVExport(p, strings.NewReader("name,url,created_at\n"))
+			So(IsImportError(err), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "status")
+		})
+		Convey("Rows of other sites should be rejected", func() {
+			export := "name,url,status,created_at\nWatermelon,http://codeforces.com/problemset/problem/4/A,AC,1580000000\n"
+			_, err := ParseCSVExport(p, strings.NewReader(export))
+			So(IsImportError(err), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "line 2")
+		})
+		Convey("Unknown statuses and dates should be rejected", func() {
+			_, err := ParseCSVExport(p, strings.NewReader("name,url,status,created_at\nPrime Generator,https://www.spoj.com/problems/PRIME1/,great,1580000000\n"))
+			So(IsImportError(err), ShouldBeTrue)
+			_, err = ParseCSVExport(p, strings.NewReader("name,url,status,created_at\nPrime Generator,https://www.spoj.com/problems/PRIME1/,AC,yesterday\n"))
+			So(IsImportError(err), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,115 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+)
+
+// Fetcher makes the HTTP requests of a scrapper. *http.Client satisfies it,
+// tests swap it with a Recorder replaying saved responses or with a client
+// pointing to a local server.
+type Fetcher interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+var (
+	defaultFetcher Fetcher
+	defaultOnce    sync.Once
+)
+
+// DefaultFetcher returns the fetcher shared by all the scrappers, made by NewDefaultFetcher.
+// If the fixture settings are invalid, every request fails with the error, so that runs
+// meant to replay the fixtures never reach the platforms
+func DefaultFetcher() Fetcher {
+	defaultOnce.Do(func() {
+		f, err := NewDefaultFetcher()
+		if err != nil {
+			log.Println(err.Error())
+			f = failingFetcher{err}
+		}
+		defaultFetcher = f
+	})
+	return defaultFetcher
+}
+
+// NewDefaultFetcher returns a fetcher which is rate limited and stops requesting the
+// platforms which are down. When SCRAPPER_FIXTURE_MODE is set to record or replay, the
+// responses are saved to or served from the fixtures in SCRAPPER_FIXTURE_DIR.
+// The platforms are requested directly only if the mode is not set, any other mode is an error
+func NewDefaultFetcher() (Fetcher, error) {
+	throttle := NewThrottle(&http.Client{Timeout: time.Second * 10})
+	mode := os.Getenv("SCRAPPER_FIXTURE_MODE")
+	if mode == "" {
+		return throttle, nil
+	}
+	dir := os.Getenv("SCRAPPER_FIXTURE_DIR")
+	if dir == "" {
+		dir = "fixtures"
+	}
+	recorder, err := NewRecorder(mode, dir, throttle)
+	if err != nil {
+		return nil, fmt.Errorf("fixture recorder: %s", err)
+	}
+	return recorder, nil
+}
+
+// failingFetcher fails every request with the error it was made with
+type failingFetcher struct {
+	err error
+}
+
+func (f failingFetcher) Do(*http.Request) (*http.Response, error) {
+	return nil, f.err
+}
+
+// NewCollector returns a colly collector making its requests through the fetcher.
+// Its requests are given up once the context is done
+func NewCollector(f Fetcher, ctx context.Context) *colly.Collector {
+	if f == nil {
+		f = DefaultFetcher()
+	}
+	c := colly.NewCollector()
+	c.WithTransport(fetcherTransport{f, ctx})
+	return c
+}
+
+// fetcherTransport adapts a Fetcher to the http.RoundTripper used by colly, which does not
+// pass contexts along with its requests
+type fetcherTransport struct {
+	fetcher Fetcher
+	ctx     context.Context
+}
+
+func (t fetcherTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = WithContext(req, t.ctx)
+	resp, err := t.fetcher.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Request == nil {
+		resp.Request = req
+	}
+	return resp, nil
+}
+
+// Visit makes the collector visit the URL. Failures are returned as typed scrapper errors of the site
+func Visit(c *colly.Collector, site string, URL string) error {
+	var visitErr error
+	c.OnError(func(r *colly.Response, err error) {
+		visitErr = CollyError(site, r, err)
+	})
+	if err := c.Visit(URL); err != nil {
+		if visitErr == nil {
+			visitErr = RequestError(site, err)
+		}
+		return visitErr
+	}
+	return nil
+}
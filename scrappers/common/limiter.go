@@ -0,0 +1,164 @@
+package common
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Rate at which requests are allowed to a host. Requests are taken from a token
+// bucket holding at most Burst tokens and refilled at PerSecond tokens a second.
+type Rate struct {
+	PerSecond float64
+	Burst     int
+}
+
+// Rate used for the hosts not present in hostRates
+var defaultRate = Rate{PerSecond: 2, Burst: 4}
+
+var hostRates = map[string]Rate{
+	// Codeforces allows one API call every two seconds
+	"codeforces.com": {PerSecond: 0.5, Burst: 1},
+	// Codechef API replies with code 9002 when called too often
+	"api.codechef.com": {PerSecond: 1, Burst: 2},
+	// AtCoder Problems asks for at least a second between requests
+	"kenkoooo.com": {PerSecond: 1, Burst: 1},
+}
+
+var ratesMutex sync.RWMutex
+
+// SetRate changes the rate at which requests are made to the host
+func SetRate(host string, rate Rate) {
+	ratesMutex.Lock()
+	defer ratesMutex.Unlock()
+	hostRates[host] = rate
+}
+
+func rateOf(host string) Rate {
+	ratesMutex.RLock()
+	defer ratesMutex.RUnlock()
+	if rate, ok := hostRates[host]; ok {
+		return rate
+	}
+	return defaultRate
+}
+
+// Limiter hands out the tokens of the per host buckets
+type Limiter interface {
+	// Reserve takes a token from the bucket of the host and returns
+	// how long the caller has to wait before making the request
+	Reserve(host string, rate Rate) (time.Duration, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// localLimiter keeps the buckets in memory, limiting the requests of this process only
+type localLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func newLocalLimiter() *localLimiter {
+	return &localLimiter{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (l *localLimiter) Reserve(host string, rate Rate) (time.Duration, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), last: now}
+		l.buckets[host] = b
+	}
+	if now.After(b.last) {
+		b.tokens = math.Min(float64(rate.Burst), b.tokens+now.Sub(b.last).Seconds()*rate.PerSecond)
+		b.last = now
+	}
+	// Tokens go negative when reserved ahead, later callers wait for them to be refilled
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0, nil
+	}
+	return time.Duration(-b.tokens / rate.PerSecond * float64(time.Second)), nil
+}
+
+// Same algorithm as localLimiter, run atomically on redis so that
+// every instance of the server draws from the same bucket
+var reserveScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) * rate)
+	last = now
+end
+tokens = tokens - 1
+redis.call("HMSET", KEYS[1], "tokens", tokens, "last", last)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+if tokens >= 0 then
+	return 0
+end
+return math.ceil(-tokens / rate)
+`)
+
+// redisLimiter shares the buckets between the instances through redis
+type redisLimiter struct {
+	client *redis.Client
+}
+
+func (l redisLimiter) Reserve(host string, rate Rate) (time.Duration, error) {
+	nowMillis := time.Now().UnixNano() / int64(time.Millisecond)
+	wait, err := reserveScript.Run(l.client, []string{"ratelimit:" + host},
+		// Rate is passed in tokens per millisecond
+		strconv.FormatFloat(rate.PerSecond/1000, 'f', -1, 64), rate.Burst, nowMillis).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// fallbackLimiter uses the local buckets while redis is unreachable
+type fallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+}
+
+func (l fallbackLimiter) Reserve(host string, rate Rate) (time.Duration, error) {
+	wait, err := l.primary.Reserve(host, rate)
+	if err != nil {
+		log.Println("rate limiter:", err.Error())
+		return l.fallback.Reserve(host, rate)
+	}
+	return wait, nil
+}
+
+var (
+	sharedLimiter Limiter = newLocalLimiter()
+	limiterMutex  sync.RWMutex
+)
+
+// UseRedisLimiter makes the scrappers of all the instances share the
+// rate limits through redis. Without it, each process is limited on its own
+func UseRedisLimiter(client *redis.Client) {
+	limiterMutex.Lock()
+	defer limiterMutex.Unlock()
+	sharedLimiter = fallbackLimiter{primary: redisLimiter{client: client}, fallback: newLocalLimiter()}
+}
+
+func currentLimiter() Limiter {
+	limiterMutex.RLock()
+	defer limiterMutex.RUnlock()
+	return sharedLimiter
+}
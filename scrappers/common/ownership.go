@@ -0,0 +1,36 @@
+package common
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// Implemented by the scrappers of the sites where the ownership of a handle can be
+// proven by more than the name and organization on its profile, eg. its bio or a submission
+type OwnershipVerifier interface {
+	// Reports whether the token is on the profile of the handle, or in a submission made after the given time
+	HasToken(token string, since time.Time) (bool, error)
+}
+
+// Reports whether the token is in the name or organization of the profile
+func ProfileHasToken(profile types.ProfileInfo, token string) bool {
+	if token == "" {
+		return false
+	}
+	return strings.Contains(profile.Name, token) || strings.Contains(profile.School, token)
+}
+
+// Reports whether the owner of the handle has put the token on the site after the given time.
+// The profile is looked up for the sites which do not accept any other proof
+func HasOwnershipToken(s Scrapper, token string, since time.Time) (bool, error) {
+	if verifier, ok := s.(OwnershipVerifier); ok {
+		return verifier.HasToken(token, since)
+	}
+	profile, err := s.GetProfileInfo()
+	if err != nil {
+		return false, err
+	}
+	return ProfileHasToken(profile, token), nil
+}
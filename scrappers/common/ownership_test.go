@@ -0,0 +1,58 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// Scrapper returning a fixed profile
+type profileScrapper struct {
+	Scrapper
+	profile types.ProfileInfo
+}
+
+func (s profileScrapper) GetProfileInfo() (types.ProfileInfo, error) {
+	return s.profile, nil
+}
+
+// Scrapper accepting a proof other than the profile
+type verifyingScrapper struct {
+	profileScrapper
+	since time.Time
+}
+
+func (s *verifyingScrapper) HasToken(token string, since time.Time) (bool, error) {
+	s.since = since
+	return true, nil
+}
+
+func TestHasOwnershipToken(t *testing.T) {
+	Convey("Subject: Ownership of handles\n", t, func() {
+		token := "codephile-1a2b3c4d5e6f"
+
+		Convey("Token should be looked up in the name and organization", func() {
+			ok, err := HasOwnershipToken(profileScrapper{profile: types.ProfileInfo{Name: "Alice " + token}}, token, time.Time{})
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			ok, _ = HasOwnershipToken(profileScrapper{profile: types.ProfileInfo{School: "IIT Roorkee " + token}}, token, time.Time{})
+			So(ok, ShouldBeTrue)
+			ok, _ = HasOwnershipToken(profileScrapper{profile: types.ProfileInfo{Name: "Alice", School: "IIT Roorkee"}}, token, time.Time{})
+			So(ok, ShouldBeFalse)
+		})
+		Convey("Empty token should never be found", func() {
+			So(ProfileHasToken(types.ProfileInfo{Name: "Alice"}, ""), ShouldBeFalse)
+		})
+		Convey("Scrappers accepting other proofs should be asked", func() {
+			issued := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+			verifier := &verifyingScrapper{}
+			ok, err := HasOwnershipToken(verifier, token, issued)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(verifier.since, ShouldEqual, issued)
+		})
+	})
+}
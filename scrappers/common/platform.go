@@ -0,0 +1,83 @@
+package common
+
+import (
+	"context"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+type Scrapper interface {
+	CheckHandle() (bool, error)
+	// Submissions made after the given time, latest first. On failure, the ones
+	// fetched till then are returned along with the error
+	GetSubmissions(after time.Time) ([]types.Submission, error)
+	GetProfileInfo() (types.ProfileInfo, error)
+	// Rating history is nil for the sites without rated contests
+	GetRatingHistory() (types.RatingGraph, error)
+}
+
+// Implemented by the scrappers of the sites whose problems could be listed,
+// to populate the problem catalog
+type ProblemLister interface {
+	GetProblems() ([]types.Problem, error)
+}
+
+// Platform is a site supported by codephile. Every scrapper package registers
+// its platform on init, the rest of the app looks the platforms up by site
+type Platform struct {
+	// Key of the site in the routes and the database, eg. codeforces
+	Site string
+	// Name of the site shown to the users
+	Name string
+	// Prefix of the URLs of the problems of the site, submissions are matched to the site by it
+	URL string
+	// Returns the scrapper of the handle. Problems are listed by the scrapper of an empty handle
+	NewScrapper func(handle string, ctx context.Context, f Fetcher) Scrapper
+	// The site holds rated contests, whose history is returned by GetRatingHistory
+	RatedContests bool
+	// Only the accepted submissions are listed by the site, so the accuracy is not known
+	AcceptedOnly bool
+	// Parses the export of the submission history offered by the site, nil if there is none.
+	// Submissions made by handles other than the given one are rejected
+	ParseExport func(handle string, r io.Reader) ([]types.Submission, error)
+}
+
+var (
+	platformMutex sync.RWMutex
+	platforms     = make(map[string]Platform)
+)
+
+// Registers the platform of a scrapper package. Registering a site twice is a programming error
+func RegisterPlatform(p Platform) {
+	platformMutex.Lock()
+	defer platformMutex.Unlock()
+	if _, ok := platforms[p.Site]; ok {
+		panic("platform " + p.Site + " is registered twice")
+	}
+	platforms[p.Site] = p
+}
+
+func LookupPlatform(site string) (Platform, bool) {
+	platformMutex.RLock()
+	defer platformMutex.RUnlock()
+	p, ok := platforms[site]
+	return p, ok
+}
+
+// Returns the registered platforms ordered by site
+func Platforms() []Platform {
+	platformMutex.RLock()
+	defer platformMutex.RUnlock()
+	list := make([]Platform, 0, len(platforms))
+	for _, p := range platforms {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Site < list[j].Site
+	})
+	return list
+}
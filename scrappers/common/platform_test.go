@@ -0,0 +1,39 @@
+package common
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPlatforms(t *testing.T) {
+	newScrapper := func(handle string, ctx context.Context, f Fetcher) Scrapper {
+		return nil
+	}
+	// Registered once, as goconvey runs the setup for every leaf
+	RegisterPlatform(Platform{Site: "spoj", Name: "SPOJ", URL: "https://www.spoj.com", NewScrapper: newScrapper})
+	RegisterPlatform(Platform{Site: "codechef", Name: "CodeChef", URL: "https://www.codechef.com", NewScrapper: newScrapper, RatedContests: true})
+
+	Convey("Subject: Platform registry\n", t, func() {
+		Convey("Platforms should be looked up by site", func() {
+			platform, ok := LookupPlatform("codechef")
+			So(ok, ShouldBeTrue)
+			So(platform.Name, ShouldEqual, "CodeChef")
+			So(platform.RatedContests, ShouldBeTrue)
+			_, ok = LookupPlatform("topcoder")
+			So(ok, ShouldBeFalse)
+		})
+		Convey("Platforms should be listed in order of site", func() {
+			platforms := Platforms()
+			So(len(platforms), ShouldEqual, 2)
+			So(platforms[0].Site, ShouldEqual, "codechef")
+			So(platforms[1].Site, ShouldEqual, "spoj")
+		})
+		Convey("Registering a site twice should panic", func() {
+			So(func() {
+				RegisterPlatform(Platform{Site: "spoj", NewScrapper: newScrapper})
+			}, ShouldPanic)
+		})
+	})
+}
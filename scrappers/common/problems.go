@@ -0,0 +1,103 @@
+package common
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+// ProblemStore keeps the metadata of problems, keyed by their URL, so that
+// the scrappers fetch a problem once instead of once per submission
+type ProblemStore interface {
+	// Problem returns the stored metadata of the problem at the URL and whether it was found
+	Problem(url string) (types.ProblemMetadata, bool, error)
+	SaveProblem(problem types.ProblemMetadata) error
+}
+
+// memoryProblemStore keeps the metadata in memory, shared by the scrappers of this process only
+type memoryProblemStore struct {
+	mutex    sync.RWMutex
+	problems map[string]types.ProblemMetadata
+}
+
+func newMemoryProblemStore() *memoryProblemStore {
+	return &memoryProblemStore{problems: make(map[string]types.ProblemMetadata)}
+}
+
+func (s *memoryProblemStore) Problem(url string) (types.ProblemMetadata, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	problem, ok := s.problems[url]
+	return problem, ok, nil
+}
+
+func (s *memoryProblemStore) SaveProblem(problem types.ProblemMetadata) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.problems[problem.URL] = problem
+	return nil
+}
+
+var (
+	sharedProblemStore ProblemStore = newMemoryProblemStore()
+	problemStoreMutex  sync.RWMutex
+)
+
+// UseProblemStore makes the scrappers read and write the metadata of problems through the store
+func UseProblemStore(store ProblemStore) {
+	problemStoreMutex.Lock()
+	defer problemStoreMutex.Unlock()
+	sharedProblemStore = store
+}
+
+func currentProblemStore() ProblemStore {
+	problemStoreMutex.RLock()
+	defer problemStoreMutex.RUnlock()
+	return sharedProblemStore
+}
+
+// Returns the stored metadata of the problem if it is still fresh
+func freshProblem(store ProblemStore, url string) (types.ProblemMetadata, bool) {
+	problem, found, err := store.Problem(url)
+	if err != nil {
+		// Store being down should not stop the scrapping
+		log.Println(err.Error())
+		return problem, false
+	}
+	return problem, found && time.Since(problem.FetchedAt) < ProblemMetadataTTL
+}
+
+// Problem returns the metadata of the problem at the URL. It is fetched only
+// when it is not stored yet or has gone stale.
+func Problem(url string, fetch func() (types.ProblemMetadata, error)) (types.ProblemMetadata, error) {
+	store := currentProblemStore()
+	if problem, ok := freshProblem(store, url); ok {
+		return problem, nil
+	}
+	problem, err := fetch()
+	if err != nil {
+		return problem, err
+	}
+	problem.URL = url
+	problem.FetchedAt = time.Now()
+	if err := store.SaveProblem(problem); err != nil {
+		log.Println(err.Error())
+	}
+	return problem, nil
+}
+
+// RememberProblem stores the metadata of a problem learnt along with the submissions,
+// unless a fresh one is already stored
+func RememberProblem(problem types.ProblemMetadata) {
+	store := currentProblemStore()
+	if _, ok := freshProblem(store, problem.URL); ok {
+		return
+	}
+	problem.FetchedAt = time.Now()
+	if err := store.SaveProblem(problem); err != nil {
+		log.Println(err.Error())
+	}
+}
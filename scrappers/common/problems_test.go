@@ -0,0 +1,54 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models/types"
+)
+
+func TestProblem(t *testing.T) {
+	Convey("Subject: Shared metadata of problems\n", t, func() {
+		UseProblemStore(newMemoryProblemStore())
+		fetches := 0
+		fetch := func() (types.ProblemMetadata, error) {
+			fetches++
+			return types.ProblemMetadata{Name: "Prime Generator", Tags: []string{"#sieve"}}, nil
+		}
+		url := "https://www.spoj.com/problems/PRIME1/"
+
+		Convey("Problem should be fetched once", func() {
+			for i := 0; i < 3; i++ {
+				problem, err := Problem(url, fetch)
+				So(err, ShouldBeNil)
+				So(problem.URL, ShouldEqual, url)
+				So(problem.Tags, ShouldResemble, []string{"#sieve"})
+			}
+			So(fetches, ShouldEqual, 1)
+		})
+		Convey("Stale problem should be fetched again", func() {
+			_ = currentProblemStore().SaveProblem(types.ProblemMetadata{URL: url, FetchedAt: time.Now().Add(-ProblemMetadataTTL)})
+			problem, _ := Problem(url, fetch)
+			So(problem.Name, ShouldEqual, "Prime Generator")
+			So(fetches, ShouldEqual, 1)
+		})
+		Convey("Problems learnt from submissions should not be fetched", func() {
+			RememberProblem(types.ProblemMetadata{URL: url, Name: "PRIME1"})
+			problem, _ := Problem(url, fetch)
+			So(problem.Name, ShouldEqual, "PRIME1")
+			So(fetches, ShouldEqual, 0)
+		})
+		Convey("Failed fetches should not be stored", func() {
+			_, err := Problem(url, func() (types.ProblemMetadata, error) {
+				return types.ProblemMetadata{}, errors.New("connection refused")
+			})
+			So(err, ShouldNotBeNil)
+			_, _ = Problem(url, fetch)
+			So(fetches, ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,233 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// ModeRecord makes the real requests and saves their responses as fixtures
+	ModeRecord = "record"
+	// ModeReplay serves the responses from the fixtures without touching the network
+	ModeReplay = "replay"
+)
+
+// Interaction is a request along with the response it received
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Recorder is a Fetcher which records the responses of the platforms as fixtures
+// or replays them. The fixtures are kept in the directory, one file per host.
+type Recorder struct {
+	mode    string
+	dir     string
+	fetcher Fetcher
+
+	mutex     sync.Mutex
+	cassettes map[string][]Interaction
+	// Number of times each interaction has been replayed
+	replayed map[string][]int
+}
+
+// NewRecorder returns a recorder working in the given mode on the fixture directory.
+// The fetcher makes the real requests while recording and is unused on replay
+func NewRecorder(mode string, dir string, f Fetcher) (*Recorder, error) {
+	if mode != ModeRecord && mode != ModeReplay {
+		return nil, fmt.Errorf("invalid fixture mode %q", mode)
+	}
+	if mode == ModeRecord {
+		if f == nil {
+			return nil, errors.New("recording requires a fetcher")
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Recorder{
+		mode:      mode,
+		dir:       dir,
+		fetcher:   f,
+		cassettes: make(map[string][]Interaction),
+		replayed:  make(map[string][]int),
+	}, nil
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close() // nolint: errcheck
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	recorded := RecordedRequest{Method: req.Method, URL: req.URL.String(), Body: string(body)}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	cassette, err := r.load(req.URL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	resp, err := r.fetcher.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	header := cloneHeader(resp.Header)
+	// The saved body is already decoded and may differ in length
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	interaction := Interaction{
+		Request:  recorded,
+		Response: RecordedResponse{StatusCode: resp.StatusCode, Header: header, Body: string(respBody)},
+	}
+	r.cassettes[req.URL.Host] = append(cassette, interaction)
+	r.replayed[req.URL.Host] = append(r.replayed[req.URL.Host], 1)
+	if err := r.save(req.URL.Host); err != nil {
+		return nil, err
+	}
+	return interaction.Response.toResponse(req), nil
+}
+
+// Serves the first matching interaction not yet replayed. Once all of them
+// are used up, the last one keeps being served.
+func (r *Recorder) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	cassette := r.cassettes[req.URL.Host]
+	counts := r.replayed[req.URL.Host]
+	match := -1
+	for i, interaction := range cassette {
+		if !interaction.Request.matches(recorded) {
+			continue
+		}
+		match = i
+		if counts[i] == 0 {
+			break
+		}
+	}
+	if match == -1 {
+		return nil, fmt.Errorf("no fixture recorded for %s %s", recorded.Method, recorded.URL)
+	}
+	counts[match]++
+	return cassette[match].Response.toResponse(req), nil
+}
+
+func (r *Recorder) fixturePath(host string) string {
+	return filepath.Join(r.dir, strings.Replace(host, ":", "_", -1)+".json")
+}
+
+// Loads the fixtures of the host, once per recorder
+func (r *Recorder) load(host string) ([]Interaction, error) {
+	if cassette, ok := r.cassettes[host]; ok {
+		return cassette, nil
+	}
+	var cassette []Interaction
+	data, err := ioutil.ReadFile(r.fixturePath(host))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cassette); err != nil {
+			return nil, fmt.Errorf("invalid fixture %s: %s", r.fixturePath(host), err)
+		}
+	}
+	r.cassettes[host] = cassette
+	r.replayed[host] = make([]int, len(cassette))
+	return cassette, nil
+}
+
+func (r *Recorder) save(host string) error {
+	data, err := json.MarshalIndent(r.cassettes[host], "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.fixturePath(host), data, 0644)
+}
+
+// Bodies are compared ignoring the whitespace, so that queries could be
+// reformatted without recording the fixtures again
+func (req RecordedRequest) matches(other RecordedRequest) bool {
+	return req.Method == other.Method && req.URL == other.URL &&
+		normalizeBody(req.Body) == normalizeBody(other.Body)
+}
+
+func normalizeBody(body string) string {
+	var value interface{}
+	if json.Unmarshal([]byte(body), &value) == nil {
+		data, err := json.Marshal(collapseSpaces(value))
+		if err == nil {
+			return string(data)
+		}
+	}
+	return strings.Join(strings.Fields(body), " ")
+}
+
+func collapseSpaces(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.Join(strings.Fields(v), " ")
+	case map[string]interface{}:
+		for key, elem := range v {
+			v[key] = collapseSpaces(elem)
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = collapseSpaces(elem)
+		}
+	}
+	return value
+}
+
+func (resp RecordedResponse) toResponse(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cloneHeader(resp.Header),
+		Body:          ioutil.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for key, values := range header {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
+}
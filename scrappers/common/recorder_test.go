@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gocolly/colly"
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+func TestRecorder(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		fmt.Fprintf(w, `<html><body><p class="hit">%d %s %s</p></body></html>`, hits, r.Method, body)
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) // nolint: errcheck
+
+	recorder, err := NewRecorder(ModeRecord, dir, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, missingErr := Get(recorder, "test", server.URL+"/missing", context.Background())
+	query, _ := Post(recorder, "test", server.URL+"/query", "application/json", []byte(`{"query": "{\n\tuser\n}"}`), context.Background())
+	_, _ = Get(recorder, "test", server.URL+"/page", context.Background())
+	_, _ = Get(recorder, "test", server.URL+"/page", context.Background())
+	recordedHits := hits
+
+	Convey("Subject: Recording and replaying responses\n", t, func() {
+		Convey("Invalid modes should be rejected", func() {
+			_, err := NewRecorder("rewind", dir, nil)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("Recording should pass the responses through", func() {
+			So(ScrapeErrorKind(missingErr), ShouldEqual, HandleNotFound)
+			So(string(missing), ShouldContainSubstring, "1 GET")
+			So(string(query), ShouldContainSubstring, "2 POST")
+		})
+
+		replayer, err := NewRecorder(ModeReplay, dir, nil)
+		So(err, ShouldBeNil)
+		Convey("Replay should serve the recorded responses", func() {
+			body, err := Get(replayer, "test", server.URL+"/missing", context.Background())
+			So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+			So(string(body), ShouldEqual, string(missing))
+		})
+		Convey("Request bodies should match regardless of whitespace", func() {
+			body, _ := Post(replayer, "test", server.URL+"/query", "application/json", []byte(`{"query":"{ user }"}`), context.Background())
+			So(string(body), ShouldEqual, string(query))
+		})
+		Convey("Requests without a fixture should fail", func() {
+			body, err := Get(replayer, "test", server.URL+"/unknown", context.Background())
+			So(ScrapeErrorKind(err), ShouldEqual, PlatformUnavailable)
+			So(body, ShouldBeNil)
+		})
+		Convey("Repeated requests should be served in the recorded order", func() {
+			first, _ := Get(replayer, "test", server.URL+"/page", context.Background())
+			second, _ := Get(replayer, "test", server.URL+"/page", context.Background())
+			third, _ := Get(replayer, "test", server.URL+"/page", context.Background())
+			So(string(first), ShouldContainSubstring, "3 GET")
+			So(string(second), ShouldContainSubstring, "4 GET")
+			So(string(third), ShouldEqual, string(second))
+		})
+		Convey("Colly collectors should make requests through the fetcher", func() {
+			c := NewCollector(replayer, context.Background())
+			var text string
+			c.OnHTML(".hit", func(e *colly.HTMLElement) {
+				text = e.Text
+			})
+			err := c.Visit(server.URL + "/page")
+			So(err, ShouldBeNil)
+			So(text, ShouldEqual, "3 GET ")
+		})
+		So(hits, ShouldEqual, recordedHits)
+	})
+}
+
+func TestNewDefaultFetcher(t *testing.T) {
+	Convey("Subject: Fixture settings of the default fetcher\n", t, func() {
+		mode := os.Getenv("SCRAPPER_FIXTURE_MODE")
+		Reset(func() {
+			os.Setenv("SCRAPPER_FIXTURE_MODE", mode)
+		})
+
+		Convey("Platforms should be requested only if the mode is not set", func() {
+			os.Setenv("SCRAPPER_FIXTURE_MODE", "")
+			f, err := NewDefaultFetcher()
+			So(err, ShouldBeNil)
+			So(f, ShouldHaveSameTypeAs, &Throttle{})
+		})
+		Convey("Fixtures should be replayed in the replay mode", func() {
+			os.Setenv("SCRAPPER_FIXTURE_MODE", ModeReplay)
+			f, err := NewDefaultFetcher()
+			So(err, ShouldBeNil)
+			So(f, ShouldHaveSameTypeAs, &Recorder{})
+		})
+		Convey("Invalid modes should be an error rather than requesting the platforms", func() {
+			os.Setenv("SCRAPPER_FIXTURE_MODE", "replya")
+			_, err := NewDefaultFetcher()
+			So(err, ShouldNotBeNil)
+			_, err = failingFetcher{err}.Do(nil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+const (
+	// Consecutive failures after which the requests to a host are stopped
+	breakerThreshold = 5
+	// Time for which a failing host is left alone before it is tried again
+	breakerCooldown = time.Minute
+)
+
+// breaker is the circuit breaker of a host. It opens after breakerThreshold
+// consecutive failures and rejects requests for breakerCooldown. After that
+// a single probe request is let through, which closes it again on success.
+type breaker struct {
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if b.probing || now.Sub(b.openedAt) < breakerCooldown {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+// Records the outcome of a request, returns true if the breaker got opened
+func (b *breaker) record(failed bool, now time.Time) bool {
+	b.probing = false
+	if !failed {
+		b.failures = 0
+		return false
+	}
+	b.failures++
+	if b.failures >= breakerThreshold {
+		b.openedAt = now
+		return true
+	}
+	return false
+}
+
+// Throttle is a Fetcher which paces the requests to each host according
+// to its rate and stops requesting the hosts which keep failing
+type Throttle struct {
+	fetcher  Fetcher
+	limiter  func() Limiter
+	now      func() time.Time
+	sleep    func(ctx context.Context, d time.Duration) error
+	mutex    sync.Mutex
+	breakers map[string]*breaker
+}
+
+// NewThrottle wraps the fetcher with the shared rate limiter and per host circuit breakers
+func NewThrottle(f Fetcher) *Throttle {
+	return &Throttle{
+		fetcher:  f,
+		limiter:  currentLimiter,
+		now:      time.Now,
+		sleep:    Sleep,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+func (t *Throttle) Do(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	t.mutex.Lock()
+	b, ok := t.breakers[host]
+	if !ok {
+		b = &breaker{}
+		t.breakers[host] = b
+	}
+	allowed := b.allow(t.now())
+	t.mutex.Unlock()
+	if !allowed {
+		return nil, PlatformUnavailableError
+	}
+
+	wait, err := t.limiter().Reserve(host, rateOf(host))
+	if err != nil {
+		log.Println(err.Error())
+	}
+	if wait > 0 {
+		if err := t.sleep(req.Context(), wait); err != nil {
+			// The request was never made, let the next one probe the host
+			t.mutex.Lock()
+			b.probing = false
+			t.mutex.Unlock()
+			return nil, err
+		}
+	}
+	resp, err := t.fetcher.Do(req)
+	// Responses with status 4xx are given for bad handles or
+	// exceeded limits, the platform itself is up
+	failed := (err != nil && req.Context().Err() == nil) || (err == nil && resp.StatusCode >= http.StatusInternalServerError)
+	t.mutex.Lock()
+	if b.record(failed, t.now()) {
+		log.Println("circuit breaker opened for", host)
+	}
+	t.mutex.Unlock()
+	return resp, err
+}
+
+// Sleep waits for the duration, or returns the error of the context if it is done before
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+// Answers every request with the given status, or fails when it is 0
+type stubFetcher struct {
+	status   int
+	requests int
+}
+
+func (f *stubFetcher) Do(req *http.Request) (*http.Response, error) {
+	f.requests++
+	if f.status == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: f.status, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestLocalLimiter(t *testing.T) {
+	Convey("Subject: Token bucket of a host\n", t, func() {
+		now := time.Unix(1580000000, 0)
+		limiter := newLocalLimiter()
+		limiter.now = func() time.Time { return now }
+		rate := Rate{PerSecond: 0.5, Burst: 2}
+
+		Convey("Burst should be served without waiting", func() {
+			for i := 0; i < 2; i++ {
+				wait, _ := limiter.Reserve("codeforces.com", rate)
+				So(wait, ShouldEqual, 0)
+			}
+			Convey("Later requests should be spaced by the rate", func() {
+				wait, _ := limiter.Reserve("codeforces.com", rate)
+				So(wait, ShouldEqual, 2*time.Second)
+				wait, _ = limiter.Reserve("codeforces.com", rate)
+				So(wait, ShouldEqual, 4*time.Second)
+				now = now.Add(5 * time.Second)
+				wait, _ = limiter.Reserve("codeforces.com", rate)
+				So(wait, ShouldEqual, time.Second)
+			})
+			Convey("Other hosts should have their own bucket", func() {
+				wait, _ := limiter.Reserve("www.spoj.com", rate)
+				So(wait, ShouldEqual, 0)
+			})
+		})
+	})
+}
+
+func TestThrottle(t *testing.T) {
+	Convey("Subject: Circuit breaker of a host\n", t, func() {
+		now := time.Unix(1580000000, 0)
+		stub := &stubFetcher{status: http.StatusServiceUnavailable}
+		throttle := NewThrottle(stub)
+		throttle.now = func() time.Time { return now }
+		throttle.sleep = func(context.Context, time.Duration) error { return nil }
+		throttle.limiter = func() Limiter { return newLocalLimiter() }
+		get := func() error {
+			req, _ := http.NewRequest(http.MethodGet, "http://codeforces.com/api/user.info?handles=alice", nil)
+			_, err := throttle.Do(req)
+			return err
+		}
+
+		for i := 0; i < breakerThreshold; i++ {
+			So(get(), ShouldBeNil)
+		}
+		Convey("Failing platform should be reported unavailable without being requested", func() {
+			So(get(), ShouldEqual, PlatformUnavailableError)
+			So(stub.requests, ShouldEqual, breakerThreshold)
+		})
+		Convey("Single probe should be made after the cooldown", func() {
+			now = now.Add(breakerCooldown)
+			stub.status = 0
+			So(get(), ShouldNotBeNil)
+			So(get(), ShouldEqual, PlatformUnavailableError)
+			So(stub.requests, ShouldEqual, breakerThreshold+1)
+
+			Convey("Successful probe should close the breaker", func() {
+				now = now.Add(breakerCooldown)
+				stub.status = http.StatusOK
+				So(get(), ShouldBeNil)
+				So(get(), ShouldBeNil)
+				So(stub.requests, ShouldEqual, breakerThreshold+3)
+			})
+		})
+		Convey("Client errors should not open the breaker", func() {
+			now = now.Add(breakerCooldown)
+			stub.status = http.StatusNotFound
+			for i := 0; i < 2*breakerThreshold; i++ {
+				So(get(), ShouldBeNil)
+			}
+		})
+	})
+}
+
+func TestSleep(t *testing.T) {
+	Convey("Subject: Sleeping between the retries\n", t, func() {
+		So(Sleep(context.Background(), time.Millisecond), ShouldBeNil)
+
+		Convey("Sleep should be cut short once the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			start := time.Now()
+			So(Sleep(ctx, time.Hour), ShouldEqual, context.Canceled)
+			So(time.Since(start), ShouldBeLessThan, time.Second)
+		})
+	})
+}
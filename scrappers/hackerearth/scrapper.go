@@ -0,0 +1,192 @@
+package hackerearth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gocolly/colly"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Layouts in which hackerearth renders the submission time
+var timeLayouts = []string{
+	"Jan 2, 2006, 3:04 PM",
+	"Jan. 2, 2006, 3:04 p.m.",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+var submissionPath = regexp.MustCompile(`^/submission/(\d+)/`)
+
+type Scrapper struct {
+	Handle  string
+	Context context.Context
+	Fetcher common.Fetcher
+}
+
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site: HACKEREARTH,
+		Name: "HackerEarth",
+		URL:  "https://www.hackerearth.com",
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
+	})
+}
+
+func (s Scrapper) CheckHandle() (bool, error) {
+	_, err := common.Get(s.Fetcher, HACKEREARTH, "https://www.hackerearth.com/@"+url.PathEscape(s.Handle), s.Context)
+	if ScrapeErrorKind(err) == HandleNotFound {
+		return false, nil
+	}
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
+	c := common.NewCollector(s.Fetcher, s.Context)
+	profile := types.ProfileInfo{UserName: s.Handle}
+
+	c.OnHTML("meta[property='og:title']", func(e *colly.HTMLElement) {
+		// Title is of the form "<Full name> | HackerEarth"
+		profile.Name = strings.TrimSpace(strings.Split(e.Attr("content"), "|")[0])
+	})
+	c.OnHTML(".education .institute-name, .track-education .name", func(e *colly.HTMLElement) {
+		if profile.School == "" {
+			profile.School = strings.TrimSpace(e.Text)
+		}
+	})
+
+	err := common.Visit(c, HACKEREARTH, "https://www.hackerearth.com/@"+url.PathEscape(s.Handle))
+	if err != nil {
+		log.Println(err.Error())
+		return types.ProfileInfo{}, err
+	}
+	return profile, nil
+}
+
+// Hackerearth does not hold rated contests in a form that could be fetched
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
+	return nil, nil
+}
+
+// Returns the submissions made after the given time, latest first.
+// On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
+	var subs []types.Submission
+	//Fetch submission until oldest submission not found
+	for page := 1; ; page++ {
+		newSub, err := getSubmissionParts(s.Fetcher, s.Handle, page, s.Context)
+		if err != nil {
+			log.Println(err.Error())
+			return subs, err
+		}
+		//Check for repetition of previous fetched submission
+		if len(newSub) == 0 || (len(subs) != 0 && !newSub[0].CreationDate.Before(subs[len(subs)-1].CreationDate)) {
+			break
+		}
+		for _, sub := range newSub {
+			if !sub.CreationDate.After(after) {
+				return subs, nil
+			}
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func getSubmissionParts(f common.Fetcher, handle string, page int, ctx context.Context) ([]types.Submission, error) {
+	c := common.NewCollector(f, ctx)
+	var submissions []types.Submission
+
+	c.OnHTML("tbody", func(e *colly.HTMLElement) {
+		e.ForEach("tr", func(_ int, elem *colly.HTMLElement) {
+			Name := elem.ChildText(".problem a")
+			if Name == "" {
+				return
+			}
+			URL := "https://www.hackerearth.com" + elem.ChildAttr(".problem a", "href")
+			// Link to the submission is of the form /submission/<id>/
+			var ID, SubmissionURL string
+			if match := submissionPath.FindStringSubmatch(elem.ChildAttr(".view a", "href")); match != nil {
+				ID = match[1]
+				SubmissionURL = "https://www.hackerearth.com" + match[0]
+			}
+			CreationDate := parseTime(elem.ChildAttr(".time [title]", "title"))
+			if CreationDate.IsZero() {
+				CreationDate = parseTime(elem.ChildText(".time"))
+			}
+			if CreationDate.IsZero() {
+				// Without the time, the submission could not be ordered
+				log.Println("could not parse submission time of", URL)
+				return
+			}
+			status := strings.ToLower(elem.ChildAttr(".result [title]", "title"))
+			if status == "" {
+				status = strings.ToLower(elem.ChildText(".result"))
+			}
+			language := elem.ChildText(".lang")
+			points := 0
+			switch {
+			case strings.HasPrefix(status, "accepted"):
+				status = StatusCorrect
+			case strings.HasPrefix(status, "wrong answer"):
+				status = StatusWrongAnswer
+			case strings.HasPrefix(status, "compilation error"):
+				status = StatusCompilationError
+			case strings.HasPrefix(status, "runtime error"):
+				status = StatusRuntimeError
+			case strings.HasPrefix(status, "time limit exceeded"):
+				status = StatusTimeLimitExceeded
+			case strings.HasPrefix(status, "memory limit exceeded"):
+				status = StatusMemoryLimitExceeded
+			case strings.HasPrefix(status, "partially accepted"):
+				status = StatusPartial
+			case strings.HasPrefix(status, "output limit exceeded"):
+				status = StatusOutputLimitExceeded
+			case strings.HasPrefix(status, "internal error"):
+				status = StatusInternalError
+			case strings.HasPrefix(status, "queued"), strings.HasPrefix(status, "running"):
+				status = StatusPending
+			default:
+				status = StatusWrongAnswer
+			}
+			if status == StatusCorrect {
+				points = 100
+			}
+			// Url of the problem is of the form /problem/<category>/<slug>/
+			problemID := types.ProblemID(HACKEREARTH, "", path.Base(URL))
+			submissions = append(submissions, types.Submission{ID: ID, Name: Name, URL: URL, ProblemID: problemID, SubmissionURL: SubmissionURL, CreationDate: CreationDate, Status: status, Language: language, Points: points})
+		})
+	})
+
+	err := common.Visit(c, HACKEREARTH, fmt.Sprintf("https://www.hackerearth.com/submissions/%s/?page=%d", url.PathEscape(handle), page))
+	if err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+func parseTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,77 @@
+package hackerearth
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	Convey("Subject: Hackerearth handle validation\n", t, func() {
+		valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeTrue)
+		valid, err = newScrapper(t, "nobody", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: Hackerearth profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "Alice Liddell")
+		So(profile.School, ShouldEqual, "IIT Roorkee")
+
+		Convey("Unknown handles should be reported", func() {
+			_, err := newScrapper(t, "nobody", "profile").GetProfileInfo()
+			So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+		})
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: Hackerearth submissions\n", t, func() {
+		subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		Convey("Rows with an unreadable time should be skipped", func() {
+			So(len(subs), ShouldEqual, 3)
+		})
+		So(subs[0].Name, ShouldEqual, "Monk and Inversions")
+		So(subs[0].URL, ShouldEqual, "https://www.hackerearth.com/problem/algorithm/monk-and-inversions/")
+		So(subs[0].ProblemID, ShouldEqual, "hackerearth/monk-and-inversions")
+		So(subs[0].ID, ShouldEqual, "36000004")
+		So(subs[0].SubmissionURL, ShouldEqual, "https://www.hackerearth.com/submission/36000004/")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[0].Points, ShouldEqual, 100)
+		So(subs[0].CreationDate, ShouldEqual, time.Date(2020, 2, 10, 18, 30, 0, 0, time.UTC))
+		So(subs[1].Status, ShouldEqual, StatusWrongAnswer)
+		So(subs[2].Status, ShouldEqual, StatusTimeLimitExceeded)
+		So(subs[2].Language, ShouldEqual, "Python 3")
+
+		Convey("Submissions after the last fetched one should be returned", func() {
+			subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Date(2020, 2, 10, 18, 10, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(len(subs), ShouldEqual, 1)
+		})
+	})
+}
@@ -3,102 +3,228 @@ package hackerrank
 import (
 	"context"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/getsentry/sentry-go"
 	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/types"
 	"github.com/mdg-iitr/Codephile/scrappers/common"
 	"log"
-	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
 type Scrapper struct {
 	Handle string
 	Context context.Context
+	Fetcher common.Fetcher
 }
 
-func (s Scrapper) GetProfileInfo() types.ProfileInfo {
-	hub := sentry.GetHubFromContext(s.Context)
-	if hub == nil {
-		hub = sentry.CurrentHub()
-	}
-	path := "https://www.hackerrank.com/rest/contests/master/hackers/" + s.Handle + "/profile";
-	byteValue, _ := common.HitGetRequest(path)
-	if byteValue == nil {
-		err := errors.New("GetRequest failed. Please check connection status")
-		log.Println(err)
-		hub.CaptureException(err)
-		return types.ProfileInfo{}
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site:         HACKERRANK,
+		Name:         "HackerRank",
+		URL:          "https://www.hackerrank.com",
+		AcceptedOnly: true,
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
+	})
+}
+
+// Fetches the JSON at the path into v
+func getJSON(f common.Fetcher, path string, v interface{}, hub *sentry.Hub, ctx context.Context) error {
+	byteValue, err := common.Get(f, HACKERRANK, path, ctx)
+	if err != nil {
+		return err
 	}
-	var JsonInterFace interface{}
-	err := json.Unmarshal(byteValue, &JsonInterFace)
+	err = json.Unmarshal(byteValue, v)
 	if err != nil {
 		hub.AddBreadcrumb(&sentry.Breadcrumb{
-			Category:  "JSON parse error",
-			Message:   string(byteValue),
+			Category: "JSON parse error",
+			Message:  string(byteValue),
 		}, nil)
 		hub.CaptureException(err)
-		log.Println(err.Error())
-		return types.ProfileInfo{}
-	}
-	Profile := JsonInterFace.(map[string]interface{})["model"].(map[string]interface{})
-	Name := Profile["name"].(string)
-	// Date := Profile["created_at"].(string)
-	UserName := Profile["username"].(string)
-	School := Profile["school"].(string)
-	return types.ProfileInfo{Name: Name, UserName: UserName, School: School}
+		return common.ParseError(HACKERRANK, err)
+	}
+	return nil
 }
 
-func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
+// Returns the profile along with the badges, certificates, track scores and contests
+// of the user. These are left out if they could not be fetched
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	path := "https://www.hackerrank.com/rest/hackers/" + s.Handle + "/recent_challenges?limit=1000&response_version=v1"
-	byteValue, _ := common.HitGetRequest(path)
-	var data types.HackerrankSubmisson
-	err := json.Unmarshal(byteValue, &data)
-	submissions := data.Models
+	path := "https://www.hackerrank.com/rest/contests/master/hackers/" + s.Handle + "/profile"
+	var JsonInterFace struct {
+		Model struct {
+			Name     string `json:"name"`
+			UserName string `json:"username"`
+			School   string `json:"school"`
+		} `json:"model"`
+	}
+	err := getJSON(s.Fetcher, path, &JsonInterFace, hub, s.Context)
 	if err != nil {
-		hub.AddBreadcrumb(&sentry.Breadcrumb{
-			Category:  "JSON parse error",
-			Message:   string(byteValue),
-		}, nil)
-		hub.CaptureException(err)
 		log.Println(err.Error())
-		return nil
-	}
-	var oldestSubIndex int;
-	if after.IsZero() {
-		oldestSubIndex = len(submissions)
-	} else {
-		for i, sub := range submissions {
-			if sub.CreationDate.Equal(after) || sub.CreationDate.Before(after) {
-				oldestSubIndex = i
-				break
-			}
+		return types.ProfileInfo{}, err
+	}
+	Profile := JsonInterFace.Model
+	profile := types.ProfileInfo{Name: Profile.Name, UserName: Profile.UserName, School: Profile.School}
+	if profile.Badges, err = s.getBadges(hub); err != nil {
+		log.Println(err.Error())
+	}
+	if profile.Certificates, err = s.getCertificates(hub); err != nil {
+		log.Println(err.Error())
+	}
+	if profile.Tracks, err = s.getTracks(hub); err != nil {
+		log.Println(err.Error())
+	}
+	if profile.Contests, err = s.getContests(hub); err != nil {
+		log.Println(err.Error())
+	}
+	return profile, nil
+}
+
+func (s Scrapper) getBadges(hub *sentry.Hub) ([]types.Badge, error) {
+	var data types.HackerrankBadges
+	err := getJSON(s.Fetcher, "https://www.hackerrank.com/rest/hackers/"+s.Handle+"/badges", &data, hub, s.Context)
+	if err != nil {
+		return nil, err
+	}
+	var badges []types.Badge
+	for _, b := range data.Models {
+		// Badges of the tracks not started yet are listed without stars
+		if b.Stars == 0 {
+			continue
 		}
+		badges = append(badges, types.Badge{Name: b.BadgeName, Stars: b.Stars, Solved: b.Solved, Points: b.CurrentPoints})
 	}
-	submissions = submissions[0:oldestSubIndex]
-	for i := 0; i < len(submissions); i++ {
-		submissions[i].Points = 100
-		submissions[i].Status = StatusCorrect
-		submissions[i].URL = "https://www.hackerrank.com" + submissions[i].URL
+	return badges, nil
+}
+
+func (s Scrapper) getCertificates(hub *sentry.Hub) ([]types.Certificate, error) {
+	var data types.HackerrankCertificates
+	err := getJSON(s.Fetcher, "https://www.hackerrank.com/community/v1/test_results/hacker_certificate?username="+url.QueryEscape(s.Handle), &data, hub, s.Context)
+	if err != nil {
+		return nil, err
 	}
-	return submissions
+	var certificates []types.Certificate
+	for _, c := range data.Data {
+		// Failed attempts of the tests are listed as well
+		if c.Attributes.Status != "test_passed" {
+			continue
+		}
+		certificates = append(certificates, types.Certificate{
+			Name:        c.Attributes.Certificate.Label,
+			Level:       c.Attributes.Certificate.Level,
+			URL:         "https://www.hackerrank.com/certificates/" + c.ID,
+			CompletedAt: c.Attributes.CompletedAt,
+		})
+	}
+	return certificates, nil
 }
 
-func (s Scrapper) CheckHandle() (bool, error) {
+func (s Scrapper) getTracks(hub *sentry.Hub) ([]types.TrackScore, error) {
+	var data []types.HackerrankTrackScore
+	err := getJSON(s.Fetcher, "https://www.hackerrank.com/rest/hackers/"+s.Handle+"/scores_elo", &data, hub, s.Context)
+	if err != nil {
+		return nil, err
+	}
+	var tracks []types.TrackScore
+	for _, t := range data {
+		if t.Practice.Score == 0 {
+			continue
+		}
+		tracks = append(tracks, types.TrackScore{Name: t.Name, Score: t.Practice.Score, Rank: t.Practice.Rank})
+	}
+	return tracks, nil
+}
+
+// Contests listed by hackerrank in a call
+const contestPageSize = 20
+
+func (s Scrapper) getContests(hub *sentry.Hub) ([]types.ContestParticipation, error) {
+	var contests []types.ContestParticipation
+	for offset := 0; ; offset += contestPageSize {
+		var data types.HackerrankContests
+		path := fmt.Sprintf("https://www.hackerrank.com/rest/hackers/%s/contest_participation?offset=%d&limit=%d", s.Handle, offset, contestPageSize)
+		err := getJSON(s.Fetcher, path, &data, hub, s.Context)
+		if err != nil {
+			return contests, err
+		}
+		for _, c := range data.Models {
+			contests = append(contests, types.ContestParticipation{
+				Name:  c.ContestName,
+				URL:   "https://www.hackerrank.com/contests/" + c.ContestSlug,
+				Rank:  c.Rank,
+				Score: c.Score,
+			})
+		}
+		if len(data.Models) == 0 || offset+len(data.Models) >= data.Total {
+			return contests, nil
+		}
+	}
+}
+
+// Challenges listed by hackerrank in a call
+const submissionPageSize = 50
+
+// Returns the challenges solved after the given time, latest first. The history is
+// paginated with the cursor returned along with every page.
+// On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
-	resp, err := http.Get("https://www.hackerrank.com/rest/contests/master/hackers/" + s.Handle + "/profile")
+	var subs []types.Submission
+	var cursor string
+	for {
+		path := fmt.Sprintf("https://www.hackerrank.com/rest/hackers/%s/recent_challenges?limit=%d&response_version=v1", s.Handle, submissionPageSize)
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		var data types.HackerrankSubmisson
+		err := getJSON(s.Fetcher, path, &data, hub, s.Context)
+		if err != nil {
+			log.Println(err.Error())
+			return subs, err
+		}
+		for _, sub := range data.Models {
+			if !sub.CreationDate.After(after) {
+				return subs, nil
+			}
+			// Only the solved challenges are listed by hackerrank
+			sub.Points = 100
+			sub.Status = StatusCorrect
+			// Url of the challenge is of the form /challenges/<slug>
+			sub.ProblemID = types.ProblemID(HACKERRANK, "", strings.TrimPrefix(sub.URL, "/challenges/"))
+			sub.URL = "https://www.hackerrank.com" + sub.URL
+			subs = append(subs, sub)
+		}
+		if data.LastPage || data.Cursor == "" || len(data.Models) == 0 {
+			return subs, nil
+		}
+		cursor = data.Cursor
+	}
+}
+
+func (s Scrapper) CheckHandle() (bool, error) {
+	_, err := common.Get(s.Fetcher, HACKERRANK, "https://www.hackerrank.com/rest/contests/master/hackers/"+s.Handle+"/profile", s.Context)
+	if ScrapeErrorKind(err) == HandleNotFound {
+		return false, nil
+	}
 	if err != nil {
 		log.Println(err.Error())
-		hub.CaptureException(err)
 		return false, err
 	}
-	return resp.StatusCode != http.StatusNotFound, nil
+	return true, nil
+}
+
+// Hackerrank does not hold rated contests, hence there is no rating history
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
+	return nil, nil
 }
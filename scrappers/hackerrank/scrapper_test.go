@@ -0,0 +1,106 @@
+package hackerrank
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	Convey("Subject: Hackerrank handle validation\n", t, func() {
+		valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeTrue)
+		valid, err = newScrapper(t, "nobody", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: Hackerrank profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "Alice Liddell")
+		So(profile.School, ShouldEqual, "IIT Roorkee")
+
+		Convey("Badges, certificates, tracks and contests should be included", func() {
+			So(len(profile.Badges), ShouldEqual, 2)
+			So(profile.Badges[0].Name, ShouldEqual, "Problem Solving")
+			So(profile.Badges[0].Stars, ShouldEqual, 3)
+			So(profile.Badges[0].Solved, ShouldEqual, 45)
+			So(profile.Badges[0].Points, ShouldEqual, 520.5)
+			So(len(profile.Certificates), ShouldEqual, 1)
+			So(profile.Certificates[0].Name, ShouldEqual, "Problem Solving (Basic)")
+			So(profile.Certificates[0].Level, ShouldEqual, "basic")
+			So(profile.Certificates[0].URL, ShouldEqual, "https://www.hackerrank.com/certificates/a1b2c3d4e5")
+			So(profile.Certificates[0].CompletedAt, ShouldEqual, time.Date(2020, 3, 1, 8, 0, 0, 0, time.UTC))
+			So(len(profile.Tracks), ShouldEqual, 2)
+			So(profile.Tracks[0].Name, ShouldEqual, "Algorithms")
+			So(profile.Tracks[0].Score, ShouldEqual, 1234.5)
+			So(profile.Tracks[0].Rank, ShouldEqual, 20345)
+			So(len(profile.Contests), ShouldEqual, 2)
+			So(profile.Contests[0].Name, ShouldEqual, "Week of Code 38")
+			So(profile.Contests[0].URL, ShouldEqual, "https://www.hackerrank.com/contests/w38")
+			So(profile.Contests[0].Rank, ShouldEqual, 1520)
+			So(profile.Contests[1].Score, ShouldEqual, 60)
+		})
+
+		Convey("Profile should be returned even if the rest could not be fetched", func() {
+			profile, err := newScrapper(t, "bob", "profile").GetProfileInfo()
+			So(err, ShouldBeNil)
+			So(profile.UserName, ShouldEqual, "bob")
+			So(profile.Badges, ShouldBeEmpty)
+			So(profile.Contests, ShouldBeEmpty)
+		})
+
+		Convey("Unknown handles should be reported", func() {
+			_, err := newScrapper(t, "nobody", "profile").GetProfileInfo()
+			So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+		})
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: Hackerrank submissions\n", t, func() {
+		subs, err := newScrapper(t, "alice", "profile").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		So(len(subs), ShouldEqual, 3)
+		So(subs[0].Name, ShouldEqual, "Mini-Max Sum")
+		So(subs[0].URL, ShouldEqual, "https://www.hackerrank.com/challenges/mini-max-sum")
+		So(subs[0].ProblemID, ShouldEqual, "hackerrank/mini-max-sum")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[1].CreationDate, ShouldEqual, time.Date(2020, 1, 5, 9, 30, 0, 0, time.UTC))
+
+		Convey("Older challenges should be fetched using the cursor", func() {
+			So(subs[2].Name, ShouldEqual, "Staircase")
+			So(subs[2].ProblemID, ShouldEqual, "hackerrank/staircase")
+			So(subs[2].CreationDate, ShouldEqual, time.Date(2019, 12, 20, 18, 15, 0, 0, time.UTC))
+		})
+
+		Convey("Submissions after the last fetched one should be returned", func() {
+			subs, err := newScrapper(t, "alice", "profile").GetSubmissions(time.Date(2020, 1, 5, 9, 30, 0, 0, time.UTC))
+			So(err, ShouldBeNil)
+			So(len(subs), ShouldEqual, 1)
+			So(subs[0].Name, ShouldEqual, "Mini-Max Sum")
+		})
+	})
+}
@@ -3,40 +3,161 @@ package scrappers
 import (
 	"context"
 	"errors"
+	"io"
+	"net/url"
+	"strings"
 	"time"
 
-	. "github.com/mdg-iitr/Codephile/conf"
 	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/types"
 	"github.com/mdg-iitr/Codephile/scrappers/codechef"
-	"github.com/mdg-iitr/Codephile/scrappers/codeforces"
-	"github.com/mdg-iitr/Codephile/scrappers/hackerrank"
-	"github.com/mdg-iitr/Codephile/scrappers/spoj"
-	"github.com/mdg-iitr/Codephile/scrappers/leetcode"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+	"github.com/mdg-iitr/Codephile/services/redis"
+
+	// Platforms are registered by their scrapper packages
+	_ "github.com/mdg-iitr/Codephile/scrappers/atcoder"
+	_ "github.com/mdg-iitr/Codephile/scrappers/codeforces"
+	_ "github.com/mdg-iitr/Codephile/scrappers/hackerearth"
+	_ "github.com/mdg-iitr/Codephile/scrappers/hackerrank"
+	_ "github.com/mdg-iitr/Codephile/scrappers/leetcode"
+	_ "github.com/mdg-iitr/Codephile/scrappers/spoj"
+)
+
+func init() {
+	// Instances share the rate limits of the platforms
+	common.UseRedisLimiter(redis.GetRedisClient())
+	// Codechef token survives restarts and is refreshed by one instance at a time for all of them
+	codechef.UseRedisTokenStore(redis.GetRedisClient())
+}
+
+// Formats of the uploaded submission histories
+const (
+	// Export offered by the site itself, eg. a dump of user.status of codeforces
+	ExportFormatSite = "export"
+	// CSV with the columns read by common.ParseCSVExport
+	ExportFormatCSV = "csv"
 )
 
-type Scrapper interface {
-	CheckHandle() (bool, error)
-	GetSubmissions(after time.Time) []types.Submission
-	GetProfileInfo() types.ProfileInfo
+type Scrapper = common.Scrapper
+
+type ProblemLister = common.ProblemLister
+
+type Platform = common.Platform
+
+type OwnershipVerifier = common.OwnershipVerifier
+
+type CanaryReport = common.CanaryReport
+
+func GetPlatform(site string) (Platform, bool) {
+	return common.LookupPlatform(site)
+}
+
+// Returns the supported platforms ordered by site
+func Platforms() []Platform {
+	return common.Platforms()
+}
+
+// Returns the supported sites in order
+func Sites() []string {
+	platforms := common.Platforms()
+	sites := make([]string, len(platforms))
+	for i, p := range platforms {
+		sites[i] = p.Site
+	}
+	return sites
+}
+
+func IsSiteValid(site string) bool {
+	_, ok := common.LookupPlatform(site)
+	return ok
+}
+
+// Returns the site whose URLs are on the host, eg. codeforces for codeforces.com
+func SiteOfHost(host string) (string, error) {
+	for _, p := range common.Platforms() {
+		if hostOf(p) == strings.TrimPrefix(host, "www.") {
+			return p.Site, nil
+		}
+	}
+	return "", errors.New("unrecognised platform host: " + host)
+}
+
+// Returns the hosts of the supported sites, without the www. prefix
+func Hosts() []string {
+	platforms := common.Platforms()
+	hosts := make([]string, len(platforms))
+	for i, p := range platforms {
+		hosts[i] = hostOf(p)
+	}
+	return hosts
+}
+
+func hostOf(p Platform) string {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(u.Host, "www.")
 }
 
 func NewScrapper(site string, handle string, ctx context.Context) (Scrapper, error) {
 	if handle == "" {
 		return nil, HandleNotFoundError
 	}
-	switch site {
-	case CODEFORCES:
-		return codeforces.Scrapper{Handle: handle, Context: ctx}, nil
-	case CODECHEF:
-		return codechef.Scrapper{Handle: handle, Context: ctx}, nil
-	case HACKERRANK:
-		return hackerrank.Scrapper{Handle: handle, Context: ctx}, nil
-	case SPOJ:
-		return spoj.Scrapper{Handle: handle, Context: ctx}, nil
-	case LEETCODE:
-		return leetcode.Scrapper{Handle: handle, Context: ctx}, nil
-	default:
+	p, ok := common.LookupPlatform(site)
+	if !ok {
+		return nil, errors.New("site invalid")
+	}
+	return p.NewScrapper(handle, ctx, common.DefaultFetcher()), nil
+}
+
+func NewProblemLister(site string, ctx context.Context) (ProblemLister, error) {
+	p, ok := common.LookupPlatform(site)
+	if !ok {
+		return nil, errors.New("site invalid")
+	}
+	lister, ok := p.NewScrapper("", ctx, common.DefaultFetcher()).(ProblemLister)
+	if !ok {
+		return nil, errors.New("problems of the site could not be listed")
+	}
+	return lister, nil
+}
+
+// Reports whether the owner of the handle has put the token on the site after the given time
+func HasOwnershipToken(site string, handle string, token string, since time.Time, ctx context.Context) (bool, error) {
+	scrapper, err := NewScrapper(site, handle, ctx)
+	if err != nil {
+		return false, err
+	}
+	return common.HasOwnershipToken(scrapper, token, since)
+}
+
+// Runs the scrapper of the site against the reference handle, which is expected to have
+// made submissions after the given time
+func RunCanary(site string, handle string, since time.Time, ctx context.Context) (CanaryReport, error) {
+	p, ok := common.LookupPlatform(site)
+	if !ok {
+		return CanaryReport{}, errors.New("site invalid")
+	}
+	return common.RunCanary(p, handle, p.NewScrapper(handle, ctx, common.DefaultFetcher()), since), nil
+}
+
+// Parses the uploaded submission history of the handle on the site, latest first
+//Returns ImportError/error
+func ParseSubmissionExport(site string, handle string, format string, r io.Reader) ([]types.Submission, error) {
+	p, ok := common.LookupPlatform(site)
+	if !ok {
 		return nil, errors.New("site invalid")
 	}
+	switch format {
+	case ExportFormatCSV:
+		return common.ParseCSVExport(p, r)
+	case ExportFormatSite:
+		if p.ParseExport == nil {
+			return nil, NewImportError(site, errors.New(p.Name+" does not offer an export, upload a csv instead"))
+		}
+		return p.ParseExport(handle, r)
+	default:
+		return nil, NewImportError(site, errors.New("format "+format+" is unknown"))
+	}
 }
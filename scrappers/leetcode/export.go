@@ -0,0 +1,79 @@
+package leetcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Parses an export of the submissions API, latest first. The export is either a page of
+// the API, the pages in an array, or the submissions of the pages in an array.
+// The pages do not name the user, so the submissions are taken to be of the handle
+func parseExport(handle string, r io.Reader) ([]types.Submission, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, NewImportError(LEETCODE, err)
+	}
+	var dumped []types.LeetcodeDumpedSubmission
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		dumped, err = parseDumpArray(data)
+	} else {
+		var page types.LeetcodeSubmissionDump
+		err = json.Unmarshal(data, &page)
+		dumped = page.SubmissionsDump
+	}
+	if err != nil {
+		return nil, NewImportError(LEETCODE, err)
+	}
+	subs := make([]types.Submission, 0, len(dumped))
+	for i, result := range dumped {
+		if result.ID == "" || result.TitleSlug == "" || result.Timestamp == 0 {
+			return nil, NewImportError(LEETCODE, fmt.Errorf("submission %d has no id, problem or timestamp", i))
+		}
+		sub := types.Submission{
+			ID:            result.ID.String(),
+			Name:          result.Title,
+			URL:           "https://leetcode.com/problems/" + result.TitleSlug + "/",
+			ProblemID:     types.ProblemID(LEETCODE, "", result.TitleSlug),
+			SubmissionURL: "https://leetcode.com/submissions/detail/" + result.ID.String() + "/",
+			CreationDate:  time.Unix(result.Timestamp, 0),
+			Status:        leetcodeStatus(result.StatusDisplay),
+			Language:      result.Lang,
+		}
+		if sub.Status == StatusCorrect {
+			sub.Points = 100
+		}
+		subs = append(subs, sub)
+	}
+	common.SortSubmissions(subs)
+	return subs, nil
+}
+
+// Parses an array of either the pages or the submissions
+func parseDumpArray(data []byte) ([]types.LeetcodeDumpedSubmission, error) {
+	var elements []struct {
+		SubmissionsDump []types.LeetcodeDumpedSubmission `json:"submissions_dump"`
+		types.LeetcodeDumpedSubmission
+	}
+	err := json.Unmarshal(data, &elements)
+	if err != nil {
+		return nil, err
+	}
+	var dumped []types.LeetcodeDumpedSubmission
+	for _, element := range elements {
+		if element.SubmissionsDump != nil {
+			dumped = append(dumped, element.SubmissionsDump...)
+		} else {
+			dumped = append(dumped, element.LeetcodeDumpedSubmission)
+		}
+	}
+	return dumped, nil
+}
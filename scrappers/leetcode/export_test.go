@@ -0,0 +1,47 @@
+package leetcode
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+const submissionsDump = `{"submissions_dump":[
+	{"id":400000002,"lang":"python3","timestamp":1580001000,"status_display":"Wrong Answer","title":"Two Sum","title_slug":"two-sum"},
+	{"id":400000001,"lang":"cpp","timestamp":1580000000,"status_display":"Accepted","title":"Two Sum","title_slug":"two-sum"}
+],"has_next":false}`
+
+func TestParseExport(t *testing.T) {
+	Convey("Subject: Leetcode submission exports\n", t, func() {
+		Convey("Page of the submissions API should be converted", func() {
+			subs, err := parseExport("alice", strings.NewReader(submissionsDump))
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 2)
+			So(subs[0].Status, ShouldEqual, StatusWrongAnswer)
+			So(subs[1].Status, ShouldEqual, StatusCorrect)
+			So(subs[1].Points, ShouldEqual, 100)
+			So(subs[1].URL, ShouldEqual, "https://leetcode.com/problems/two-sum/")
+			// Scrapped submissions have the same permalink, so that they are not stored twice
+			So(subs[1].SubmissionURL, ShouldEqual, "https://leetcode.com/submissions/detail/400000001/")
+		})
+		Convey("Pages and submissions in arrays should be accepted", func() {
+			subs, err := parseExport("alice", strings.NewReader("["+submissionsDump+","+submissionsDump+"]"))
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 4)
+			list := submissionsDump[strings.Index(submissionsDump, "[") : strings.Index(submissionsDump, "]")+1]
+			subs, err = parseExport("alice", strings.NewReader(list))
+			So(err, ShouldBeNil)
+			So(subs, ShouldHaveLength, 2)
+		})
+		Convey("Submissions without a problem should be rejected", func() {
+			_, err := parseExport("alice", strings.NewReader(`[{"id":1,"timestamp":1580000000}]`))
+			So(IsImportError(err), ShouldBeTrue)
+			_, err = parseExport("alice", strings.NewReader(`not json`))
+			So(IsImportError(err), ShouldBeTrue)
+		})
+	})
+}
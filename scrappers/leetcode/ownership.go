@@ -0,0 +1,64 @@
+package leetcode
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Reports whether the token is in the name, bio, school or company of the user.
+// Leetcode does not accept any other proof, so the time is not looked at
+func (s Scrapper) HasToken(token string, since time.Time) (bool, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	query := `
+		{
+			matchedUser(username: "` + s.Handle + `") {
+				profile {
+					realName
+					aboutMe
+					school
+					company
+				}
+			}
+		}
+	`
+	responseData, err := leetcodeGraphQLRequest(s.Fetcher, query, s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return false, err
+	}
+	var responseValue types.GraphQLResponse
+	err = json.Unmarshal(responseData, &responseValue)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(responseData),
+		}, nil)
+		log.Println(err.Error())
+		hub.CaptureException(err)
+		return false, common.ParseError(LEETCODE, err)
+	}
+	matchedUser := responseValue.Data.MatchedUser
+	if matchedUser == nil {
+		return false, NewScrapeError(HandleNotFound, LEETCODE, errors.New("user not found"))
+	}
+	profile := matchedUser.Profile
+	for _, field := range []string{profile.RealName, profile.AboutMe, profile.School, profile.Company} {
+		if token != "" && strings.Contains(field, token) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
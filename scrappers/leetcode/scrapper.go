@@ -1,26 +1,47 @@
 package leetcode
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/getsentry/sentry-go"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
 )
 
+// Most recent submissions served by LeetCode, older ones cannot be fetched
+// and are to be uploaded by the users instead
+const recentSubmissionLimit = 20
+
 type Scrapper struct {
 	Handle  string
 	Context context.Context
+	Fetcher common.Fetcher
+}
+
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site:          LEETCODE,
+		Name:          "LeetCode",
+		URL:           "https://leetcode.com",
+		RatedContests: true,
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
+		ParseExport: parseExport,
+	})
 }
 
-func leetcodeGraphQLRequest(query string) ([]byte, error) {
+func leetcodeGraphQLRequest(f common.Fetcher, query string, ctx context.Context) ([]byte, error) {
 	jsonData := map[string]string{
 		"query": query,
 	}
@@ -28,19 +49,10 @@ func leetcodeGraphQLRequest(query string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.Post("https://leetcode.com/graphql", "application/json", bytes.NewBuffer(jsonValue))
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	responseValue, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return responseValue, err
+	return common.Post(f, LEETCODE, "https://leetcode.com/graphql", "application/json", jsonValue, ctx)
 }
 
-func (s Scrapper) GetProfileInfo() types.ProfileInfo {
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
@@ -64,32 +76,56 @@ func (s Scrapper) GetProfileInfo() types.ProfileInfo {
 					}
 				}
 			}
+			userContestRanking(username: "` + s.Handle + `") {
+				attendedContestsCount
+				rating
+				globalRanking
+				badge {
+					name
+				}
+			}
 		}
 	`
-	responseData, err := leetcodeGraphQLRequest(query)
+	responseData, err := leetcodeGraphQLRequest(s.Fetcher, query, s.Context)
 	if err != nil {
 		log.Println(err.Error())
-		hub.CaptureException(err)
-		return types.ProfileInfo{}
+		return types.ProfileInfo{}, err
 	}
 	var responseValue types.GraphQLResponse
 	err = json.Unmarshal(responseData, &responseValue)
 	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(responseData),
+		}, nil)
 		log.Println(err.Error())
 		hub.CaptureException(err)
-		return types.ProfileInfo{}
+		return types.ProfileInfo{}, common.ParseError(LEETCODE, err)
 	}
 	matchedUser := responseValue.Data.MatchedUser
+	if matchedUser == nil {
+		return types.ProfileInfo{}, NewScrapeError(HandleNotFound, LEETCODE, errors.New("user not found"))
+	}
 	profile := matchedUser.Profile
 	submitStats := matchedUser.SubmitStats
-	accuracy := submitStats.AcSubmissionNum[0].Submissions / math.Max(1, submitStats.TotalSubmissionNum[0].Submissions) * 100
-	return types.ProfileInfo{
-		Name:      profile.RealName,
-		UserName:  matchedUser.Username,
-		School:    profile.School,
-		WorldRank: fmt.Sprintf("%.0f", profile.Ranking),
-		Accuracy:  fmt.Sprintf("%.2f", accuracy),
+	userProfile := types.ProfileInfo{
+		Name:     profile.RealName,
+		UserName: matchedUser.Username,
+		School:   profile.School,
+	}
+	userProfile.GlobalRank = int(profile.Ranking)
+	if len(submitStats.AcSubmissionNum) != 0 && len(submitStats.TotalSubmissionNum) != 0 {
+		userProfile.Accuracy = submitStats.AcSubmissionNum[0].Submissions / math.Max(1, submitStats.TotalSubmissionNum[0].Submissions)
+	}
+	// Users who have not taken part in any contest are not ranked
+	if ranking := responseValue.Data.UserContestRanking; ranking != nil {
+		userProfile.Rating = int(math.Round(ranking.Rating))
+		userProfile.ContestsAttended = ranking.AttendedContestsCount
+		if ranking.Badge != nil {
+			userProfile.RankTitle = ranking.Badge.Name
+		}
 	}
+	return userProfile, nil
 }
 
 func (s Scrapper) CheckHandle() (bool, error) {
@@ -105,61 +141,229 @@ func (s Scrapper) CheckHandle() (bool, error) {
 			}
 		}
 	`
-	responseData, err := leetcodeGraphQLRequest(query)
+	responseData, err := leetcodeGraphQLRequest(s.Fetcher, query, s.Context)
 	if err != nil {
 		log.Println(err.Error())
-		hub.CaptureException(err)
 		return false, err
 	}
-	var responseValue map[string]interface{}
+	var responseValue types.GraphQLResponse
 	err = json.Unmarshal(responseData, &responseValue)
 	if err != nil {
 		log.Println(err.Error())
 		hub.CaptureException(err)
-		return false, err
+		return false, common.ParseError(LEETCODE, err)
+	}
+	return responseValue.Data.MatchedUser != nil, nil
+}
+
+// Returns the recent submissions of the user, the latest limit of them at most
+func getRecentSubmissions(f common.Fetcher, handle string, limit int, hub *sentry.Hub, ctx context.Context) ([]types.LeetcodeRecentSubmission, error) {
+	query := `
+		{
+			recentSubmissionList(username: "` + handle + `", limit: ` + strconv.Itoa(limit) + `) {
+				id
+				title
+				titleSlug
+				timestamp
+				statusDisplay
+				lang
+			}
+		}
+	`
+	body, err := leetcodeGraphQLRequest(f, query, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var submissions types.LeetcodeSubmissions
+	err = json.Unmarshal(body, &submissions)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(body),
+		}, nil)
+		hub.CaptureException(err)
+		return nil, common.ParseError(LEETCODE, err)
 	}
-	matchedUser := responseValue["data"].(map[string]interface{})["matchedUser"]
-	return matchedUser != nil, err
+	return submissions.Data.RecentSubmissionList, nil
 }
 
-func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
+// Returns the difficulty and topic tags of the problem
+func getQuestion(f common.Fetcher, titleSlug string, hub *sentry.Hub, ctx context.Context) (types.LeetcodeQuestion, error) {
+	query := `
+		{
+			question(titleSlug: "` + titleSlug + `") {
+				difficulty
+				topicTags {
+					name
+				}
+			}
+		}
+	`
+	body, err := leetcodeGraphQLRequest(f, query, ctx)
+	if err != nil {
+		return types.LeetcodeQuestion{}, err
+	}
+	var response types.LeetcodeQuestionResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(body),
+		}, nil)
+		hub.CaptureException(err)
+		return types.LeetcodeQuestion{}, common.ParseError(LEETCODE, err)
+	}
+	if response.Data.Question == nil {
+		return types.LeetcodeQuestion{}, common.ParseError(LEETCODE, errors.New("question "+titleSlug+" not found"))
+	}
+	return *response.Data.Question, nil
+}
+
+// Returns the submissions made after the given time, latest first, among the recent ones.
+// If some of them may be older than the recent ones, a SubmissionsTruncated error is returned
+// along with the recent ones. On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
+	hub := sentry.GetHubFromContext(s.Context)
+	if hub == nil {
+		hub = sentry.CurrentHub()
+	}
+	// LeetCode offers no pagination and only the latest few submissions of the user
+	recent, err := getRecentSubmissions(s.Fetcher, s.Handle, recentSubmissionLimit, hub, s.Context)
+	if err != nil {
+		log.Println(err.Error())
+		return nil, err
+	}
+	var subs []types.Submission
+	for _, result := range recent {
+		timestamp, err := strconv.ParseInt(result.Timestamp, 10, 64)
+		if err != nil {
+			hub.CaptureException(err)
+			log.Println(err.Error())
+			return subs, common.ParseError(LEETCODE, err)
+		}
+		creationDate := time.Unix(timestamp, 0)
+		if !creationDate.After(after) {
+			return subs, nil
+		}
+		problemURL := "https://leetcode.com/problems/" + result.TitleSlug + "/"
+		// Problems are fetched once for every user, who tend to submit the same one repeatedly
+		problem, err := common.Problem(problemURL, func() (types.ProblemMetadata, error) {
+			question, err := getQuestion(s.Fetcher, result.TitleSlug, hub, s.Context)
+			problem := types.ProblemMetadata{Name: result.Title, Difficulty: question.Difficulty}
+			for _, tag := range question.TopicTags {
+				problem.Tags = append(problem.Tags, tag.Name)
+			}
+			return problem, err
+		})
+		// Submission is still of use without the problem details
+		if err != nil {
+			log.Println(err.Error())
+		}
+		sub := types.Submission{
+			ID:            result.ID,
+			Name:          result.Title,
+			URL:           problemURL,
+			ProblemID:     types.ProblemID(LEETCODE, "", result.TitleSlug),
+			SubmissionURL: "https://leetcode.com/submissions/detail/" + result.ID + "/",
+			CreationDate:  creationDate,
+			Status:        leetcodeStatus(result.StatusDisplay),
+			Language:      result.Lang,
+			Tags:          problem.Tags,
+			Difficulty:    problem.Difficulty,
+		}
+		if sub.Status == StatusCorrect {
+			sub.Points = 100
+		}
+		subs = append(subs, sub)
+	}
+	// Oldest recent submission is still after the given time, the ones in between are missed
+	if len(recent) >= recentSubmissionLimit {
+		err := fmt.Errorf("only the %d latest submissions are served, upload the older ones to /v1/submission/import/%s", recentSubmissionLimit, LEETCODE)
+		return subs, NewScrapeError(SubmissionsTruncated, LEETCODE, err)
+	}
+	return subs, nil
+}
+
+// Maps the verdict displayed by LeetCode to the status of the submission
+func leetcodeStatus(status string) string {
+	switch status {
+	case "Accepted":
+		return StatusCorrect
+	case "Wrong Answer":
+		return StatusWrongAnswer
+	case "Compile Error":
+		return StatusCompilationError
+	case "Runtime Error":
+		return StatusRuntimeError
+	case "Time Limit Exceeded":
+		return StatusTimeLimitExceeded
+	case "Memory Limit Exceeded":
+		return StatusMemoryLimitExceeded
+	case "Output Limit Exceeded":
+		return StatusOutputLimitExceeded
+	case "Internal Error":
+		return StatusInternalError
+	case "Pending", "Judging":
+		return StatusPending
+	default:
+		return StatusWrongAnswer
+	}
+}
+
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
 	}
 
 	query := `
-            { 
-				recentSubmissionList(username: "` + s.Handle + `"){
+		{
+			userContestRankingHistory(username: "` + s.Handle + `") {
+				attended
+				rating
+				ranking
+				contest {
 					title
 					titleSlug
-				    timestamp
-				}	
-            }`
-
-	body, err := leetcodeGraphQLRequest(query)
+					startTime
+				}
+			}
+		}
+	`
+	responseData, err := leetcodeGraphQLRequest(s.Fetcher, query, s.Context)
 	if err != nil {
-		hub.CaptureException(err)
 		log.Println(err.Error())
-		return nil
-	}
-	var Leetcodesubmissions []types.LeetcodeSubmissions
-	err1 := json.Unmarshal(body, &Leetcodesubmissions)
-	if err1 != nil {
-		hub.CaptureException(err1)
-		log.Println(err1.Error())
-		return nil
-	}
-	submissions := make([]types.Submission, len(Leetcodesubmissions))
-	for i, result := range Leetcodesubmissions {
-		submissions[i].Name = result.Title
-		submissions[i].URL = "https://leetcode.com/problems/" + result.URL
-		t, err := time.Parse("2006-01-02 15:04:05", result.TimeSTamp)
-		if err != nil {
-			hub.CaptureException(err)
+		return nil, err
+	}
+	var history types.LeetcodeContestHistory
+	err = json.Unmarshal(responseData, &history)
+	if err != nil {
+		hub.AddBreadcrumb(&sentry.Breadcrumb{
+			Category: "JSON parse error",
+			Message:  string(responseData),
+		}, nil)
+		log.Println(err.Error())
+		hub.CaptureException(err)
+		return nil, common.ParseError(LEETCODE, err)
+	}
+	var graph types.RatingGraph
+	// Every user starts with a rating of 1500
+	oldRating := 1500
+	for _, r := range history.Data.UserContestRankingHistory {
+		// History contains every contest held, only the attended ones change rating
+		if !r.Attended {
+			continue
 		}
-		submissions[i].CreationDate = t
-
+		newRating := int(math.Round(r.Rating))
+		graph = append(graph, types.RatingChange{
+			ContestName:  r.Contest.Title,
+			ContestURL:   "https://leetcode.com/contest/" + r.Contest.TitleSlug,
+			Rank:         r.Ranking,
+			OldRating:    oldRating,
+			NewRating:    newRating,
+			CreationDate: time.Unix(r.Contest.StartTime, 0),
+		})
+		oldRating = newRating
 	}
-	return submissions
+	return graph, nil
 }
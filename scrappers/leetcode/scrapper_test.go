@@ -0,0 +1,109 @@
+package leetcode
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	Convey("Subject: Leetcode handle validation\n", t, func() {
+		valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeTrue)
+		valid, err = newScrapper(t, "nobody", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: Leetcode profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "Alice Liddell")
+		So(profile.School, ShouldEqual, "IIT Roorkee")
+		So(profile.GlobalRank, ShouldEqual, 45678)
+		So(profile.Accuracy, ShouldEqual, 0.75)
+		So(profile.Rating, ShouldEqual, 1602)
+		So(profile.ContestsAttended, ShouldEqual, 2)
+	})
+}
+
+func TestGetRatingHistory(t *testing.T) {
+	Convey("Subject: Leetcode rating history\n", t, func() {
+		graph, err := newScrapper(t, "alice", "profile").GetRatingHistory()
+		So(err, ShouldBeNil)
+		So(len(graph), ShouldEqual, 2)
+		So(graph[0].ContestName, ShouldEqual, "Weekly Contest 170")
+		So(graph[0].ContestURL, ShouldEqual, "https://leetcode.com/contest/weekly-contest-170")
+		So(graph[0].OldRating, ShouldEqual, 1500)
+		So(graph[0].NewRating, ShouldEqual, 1546)
+		So(graph[1].OldRating, ShouldEqual, 1546)
+		So(graph[1].NewRating, ShouldEqual, 1602)
+		So(graph[1].Rank, ShouldEqual, 1500)
+		So(graph[1].CreationDate.Unix(), ShouldEqual, 1579401000)
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: Leetcode submissions\n", t, func() {
+		// Submissions older than the latest 20 are not served
+		subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Time{})
+		So(ScrapeErrorKind(err), ShouldEqual, SubmissionsTruncated)
+		So(len(subs), ShouldEqual, 20)
+		So(subs[0].Name, ShouldEqual, "Two Sum")
+		So(subs[0].URL, ShouldEqual, "https://leetcode.com/problems/two-sum/")
+		So(subs[0].ProblemID, ShouldEqual, "leetcode/two-sum")
+		So(subs[0].ID, ShouldEqual, "400000000")
+		So(subs[0].SubmissionURL, ShouldEqual, "https://leetcode.com/submissions/detail/400000000/")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[0].Points, ShouldEqual, 100)
+		So(subs[0].Language, ShouldEqual, "cpp")
+		So(subs[0].Difficulty, ShouldEqual, "Easy")
+		So(subs[0].Tags, ShouldResemble, []string{"Array", "Hash Table"})
+		So(subs[1].Status, ShouldEqual, StatusWrongAnswer)
+		So(subs[2].Status, ShouldEqual, StatusTimeLimitExceeded)
+		So(subs[2].Difficulty, ShouldEqual, "Hard")
+		So(subs[4].Status, ShouldEqual, StatusCompilationError)
+		So(subs[19].CreationDate.Unix(), ShouldEqual, 1581000000-19*3600)
+
+		Convey("Submissions up to the last fetched one should be returned", func() {
+			subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Unix(1581000000-5*3600, 0))
+			So(err, ShouldBeNil)
+			So(len(subs), ShouldEqual, 5)
+			So(subs[4].CreationDate.Unix(), ShouldEqual, 1581000000-4*3600)
+		})
+	})
+}
+
+func TestHasToken(t *testing.T) {
+	Convey("Subject: LeetCode handle ownership\n", t, func() {
+		token := "codephile-1a2b3c4d5e6f"
+		ok, err := newScrapper(t, "alice", "ownership").HasToken(token, time.Time{})
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		ok, err = newScrapper(t, "bob", "ownership").HasToken(token, time.Time{})
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+		_, err = newScrapper(t, "nobody", "ownership").HasToken(token, time.Time{})
+		So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+	})
+}
@@ -0,0 +1,66 @@
+package spoj
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/gocolly/colly"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Sections of the problems of spoj
+var problemCategories = []string{"classical", "challenge", "partial", "tutorial", "riddle", "basics"}
+
+// Problems listed on a page of a section
+const problemPageSize = 50
+
+// Returns the problems of every section of spoj
+func (s Scrapper) GetProblems() ([]types.Problem, error) {
+	var problems []types.Problem
+	for _, category := range problemCategories {
+		// Pages beyond the last one are served empty
+		for start := 0; ; start += problemPageSize {
+			page, err := getProblemsPage(s.Fetcher, category, start, s.Context)
+			if err != nil {
+				log.Println(err.Error())
+				return problems, err
+			}
+			problems = append(problems, page...)
+			if len(page) < problemPageSize {
+				break
+			}
+		}
+	}
+	return problems, nil
+}
+
+func getProblemsPage(f common.Fetcher, category string, start int, ctx context.Context) ([]types.Problem, error) {
+	c := common.NewCollector(f, ctx)
+	var problems []types.Problem
+	c.OnHTML("table.problems tbody tr", func(e *colly.HTMLElement) {
+		href := e.ChildAttr("td a[href^='/problems/']", "href")
+		if href == "" {
+			return
+		}
+		// Link to the problem is of the form /problems/<code>/
+		code := path.Base(href)
+		problems = append(problems, types.Problem{
+			ID:       types.ProblemID(SPOJ, "", code),
+			Platform: SPOJ,
+			Index:    code,
+			Name:     strings.TrimSpace(e.ChildText("td a[href^='/problems/']")),
+			URL:      "https://www.spoj.com/problems/" + code + "/",
+		})
+	})
+	err := common.Visit(c, SPOJ, fmt.Sprintf("https://www.spoj.com/problems/%s/sort=0,start=%d", category, start))
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
@@ -1,14 +1,19 @@
 package spoj
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/getsentry/sentry-go"
 	"github.com/gocolly/colly"
 	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
 	"github.com/mdg-iitr/Codephile/models/types"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
 	"log"
-	"regexp"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,26 +21,40 @@ import (
 type Scrapper struct {
 	Handle  string
 	Context context.Context
+	Fetcher common.Fetcher
 }
 
-func (s Scrapper) GetProfileInfo() types.ProfileInfo {
-	hub := sentry.GetHubFromContext(s.Context)
-	if hub == nil {
-		hub = sentry.CurrentHub()
-	}
-	c := colly.NewCollector()
+func init() {
+	common.RegisterPlatform(common.Platform{
+		Site: SPOJ,
+		Name: "SPOJ",
+		URL:  "https://www.spoj.com",
+		NewScrapper: func(handle string, ctx context.Context, f common.Fetcher) common.Scrapper {
+			return Scrapper{Handle: handle, Context: ctx, Fetcher: f}
+		},
+	})
+}
+
+func (s Scrapper) GetProfileInfo() (types.ProfileInfo, error) {
+	c := common.NewCollector(s.Fetcher, s.Context)
 	var Profile types.ProfileInfo
+	var found = false
 
 	c.OnHTML("#user-profile-left", func(e *colly.HTMLElement) {
+		found = true
 		Name := e.ChildText("h3")
 		flag := 0
-		var WorldRank string
+		var WorldRank int
 		var School string
 		UserName := s.Handle
 		for i := 4; i <= 6; i++ {
 			cssSelector1 := fmt.Sprintf(":nth-child(%d)", i)
 			if strings.Split(e.ChildText(cssSelector1), ":")[0] == "World Rank" {
-				WorldRank = strings.Split(e.ChildText(cssSelector1), ":")[1]
+				// World rank is of the form #1234 (12.3 points)
+				rank := strings.Fields(strings.Split(e.ChildText(cssSelector1), ":")[1])
+				if len(rank) != 0 {
+					WorldRank, _ = strconv.Atoi(strings.TrimPrefix(rank[0], "#"))
+				}
 				flag = i
 				break
 			}
@@ -46,29 +65,32 @@ func (s Scrapper) GetProfileInfo() types.ProfileInfo {
 			if r := recover(); r != nil {
 				//catching index out of range exception in fetching School
 				School = ""
-				Profile = types.ProfileInfo{Name: Name, UserName: UserName, School: School, WorldRank: WorldRank}
+				Profile = types.ProfileInfo{Name: Name, UserName: UserName, School: School}
+				Profile.GlobalRank = WorldRank
 			}
 		}()
 
 		School = strings.Split(e.ChildText(cssSelector2), ":")[1]
 
-		Profile = types.ProfileInfo{Name: Name, UserName: UserName, School: School, WorldRank: WorldRank}
-	})
-
-	c.OnError(func(_ *colly.Response, err error) {
-		fmt.Println("Something went wrong:", err)
-		hub.CaptureException(err)
+		Profile = types.ProfileInfo{Name: Name, UserName: UserName, School: School}
+		Profile.GlobalRank = WorldRank
 	})
 
-	err := c.Visit(fmt.Sprintf("https://www.spoj.com/users/%s/", s.Handle))
+	err := common.Visit(c, SPOJ, fmt.Sprintf("https://www.spoj.com/users/%s/", s.Handle))
 	if err != nil {
-		hub.CaptureException(err)
 		log.Println(err.Error())
+		return types.ProfileInfo{}, err
+	}
+	// Spoj serves its home page for unknown users
+	if !found {
+		return types.ProfileInfo{}, NewScrapeError(HandleNotFound, SPOJ, errors.New("profile not found"))
 	}
-	return Profile
+	return Profile, nil
 }
 
-func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
+// Returns the submissions made after the given time, latest first.
+// On failure, the submissions fetched till then are returned along with the error
+func (s Scrapper) GetSubmissions(after time.Time) ([]types.Submission, error) {
 	hub := sentry.GetHubFromContext(s.Context)
 	if hub == nil {
 		hub = sentry.CurrentHub()
@@ -78,7 +100,11 @@ func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
 	subs := []types.Submission{{CreationDate: time.Now()}}
 	//Fetch submission until oldest submission not found
 	for !oldestSubFound {
-		newSub := getSubmissionParts(s.Handle, current, hub)
+		newSub, err := getSubmissionParts(s.Fetcher, s.Handle, current, hub, s.Context)
+		if err != nil {
+			log.Println(err.Error())
+			return subs[1:], err
+		}
 		//Check for repetition of previous fetched submission
 		if len(newSub) != 0 && newSub[0].CreationDate.Before(subs[len(subs)-1].CreationDate) {
 			for i, sub := range newSub {
@@ -97,98 +123,110 @@ func (s Scrapper) GetSubmissions(after time.Time) []types.Submission {
 		}
 	}
 	if len(subs) < 2 {
-		return nil
+		return nil, nil
 	}
 	subs = subs[1 : oldestSubIndex+1]
-	return subs
+	return subs, nil
 }
 
-func getSubmissionParts(handle string, afterIndex int, hub *sentry.Hub) []types.Submission {
+func getSubmissionParts(f common.Fetcher, handle string, afterIndex int, hub *sentry.Hub, ctx context.Context) ([]types.Submission, error) {
 
-	c := colly.NewCollector()
+	c := common.NewCollector(f, ctx)
 	var submissions []types.Submission
 
 	c.OnHTML("tbody", func(e *colly.HTMLElement) {
 		e.ForEach("tr", func(_ int, elem *colly.HTMLElement) {
+			ID := strings.TrimSpace(elem.ChildText(".statustext"))
 			Name := elem.ChildText(".sproblem a")
 			URL := "https://www.spoj.com" + elem.ChildAttr(".sproblem a", "href")
 			str_date := elem.ChildText(".status_sm span")
 			CreationDate, err := time.Parse("2006-01-02 15:04:05", str_date)
 			if err != nil {
-				sentry.CaptureException(err)
+				hub.CaptureException(err)
 				log.Println(err.Error())
 			}
 			status := elem.ChildText(".statusres")
 			language := elem.ChildText(".slang span")
 			points := 0
-			switch status {
-			case "accepted":
+			// Runtime errors are followed by the signal, eg. runtime error (SIGSEGV)
+			switch {
+			case status == "accepted":
 				status = StatusCorrect
-			case "wrong answer":
+			case status == "wrong answer":
 				status = StatusWrongAnswer
-			case "compilation error":
+			case status == "compilation error":
 				status = StatusCompilationError
-			case "runtime error":
+			case strings.HasPrefix(status, "runtime error"):
 				status = StatusRuntimeError
+			case status == "time limit exceeded":
+				status = StatusTimeLimitExceeded
+			case status == "internal error":
+				status = StatusInternalError
+			case status == "waiting", status == "compiling", strings.HasPrefix(status, "running"):
+				status = StatusPending
 			default:
 				status = StatusWrongAnswer
 			}
 			if status == StatusCorrect {
 				points = 100
 			}
-			tags := getProbTags(URL)
-			submissions = append(submissions, types.Submission{Name: Name, URL: URL, CreationDate: CreationDate, Status: status, Language: language, Points: points, Tags: tags})
+			tags := getProblem(f, URL, ctx).Tags
+			// Url of the problem is of the form /problems/<code>/
+			problemID := types.ProblemID(SPOJ, "", path.Base(URL))
+			submissions = append(submissions, types.Submission{ID: ID, Name: Name, URL: URL, ProblemID: problemID, SubmissionURL: "https://www.spoj.com/files/src/" + ID + "/", CreationDate: CreationDate, Status: status, Language: language, Points: points, Tags: tags})
 		})
 	})
 
-	c.OnError(func(_ *colly.Response, err error) {
-		hub.CaptureException(err)
-		fmt.Println("Something went wrong:", err)
-	})
-
 	c.OnRequest(func(request *colly.Request) {
 		fmt.Println(request.URL)
 	})
-	err := c.Visit(fmt.Sprintf("https://www.spoj.com/status/%s/all/start=%d", handle, afterIndex))
+	err := common.Visit(c, SPOJ, fmt.Sprintf("https://www.spoj.com/status/%s/all/start=%d", handle, afterIndex))
 	if err != nil {
-		hub.CaptureException(err)
-		log.Println(err.Error())
+		return nil, err
 	}
 
-	return submissions
+	return submissions, nil
 }
 
 func (s Scrapper) CheckHandle() (bool, error) {
-	hub := sentry.GetHubFromContext(s.Context)
-	if hub == nil {
-		hub = sentry.CurrentHub()
-	}
-	c := colly.NewCollector()
+	c := common.NewCollector(s.Fetcher, s.Context)
 	var valid = false
-	var err error
 	c.OnResponse(func(response *colly.Response) {
-		valid, err = regexp.Match("user-profile-left", response.Body)
-		if err != nil {
-			hub.CaptureException(err)
-		}
+		valid = bytes.Contains(response.Body, []byte("user-profile-left"))
 	})
-	err = c.Visit(fmt.Sprintf("https://www.spoj.com/users/%s/", s.Handle))
+	err := common.Visit(c, SPOJ, fmt.Sprintf("https://www.spoj.com/users/%s/", s.Handle))
+	if ScrapeErrorKind(err) == HandleNotFound {
+		return false, nil
+	}
 	if err != nil {
 		log.Println(err.Error())
-		hub.CaptureException(err)
+		return false, err
 	}
 	return valid, nil
 }
 
-func getProbTags(url string) []string {
-	var tags []string
-	c := colly.NewCollector()
-	c.OnHTML(".problem-tag", func(e *colly.HTMLElement) {
-		tags = append(tags, e.Text)
+// Returns the name and tags of the problem. Its page is visited only
+// if the problem is not known from the submissions of any user
+func getProblem(f common.Fetcher, url string, ctx context.Context) types.ProblemMetadata {
+	problem, err := common.Problem(url, func() (types.ProblemMetadata, error) {
+		var problem types.ProblemMetadata
+		c := common.NewCollector(f, ctx)
+		c.OnHTML("#problem-name", func(e *colly.HTMLElement) {
+			problem.Name = strings.TrimSpace(e.Text)
+		})
+		c.OnHTML(".problem-tag", func(e *colly.HTMLElement) {
+			problem.Tags = append(problem.Tags, e.Text)
+		})
+		err := common.Visit(c, SPOJ, url)
+		return problem, err
 	})
-	err := c.Visit(url)
 	if err != nil {
 		log.Println("could not fetch tags")
 	}
-	return tags
+	return problem
+}
+
+// Spoj does not hold rated contests, hence there is no rating history
+func (s Scrapper) GetRatingHistory() (types.RatingGraph, error) {
+	return nil, nil
 }
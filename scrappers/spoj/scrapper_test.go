@@ -0,0 +1,96 @@
+package spoj
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	"github.com/mdg-iitr/Codephile/scrappers/common"
+)
+
+// Returns a scrapper replaying the fixtures of testdata/<fixture>
+func newScrapper(t *testing.T, handle string, fixture string) Scrapper {
+	recorder, err := common.NewRecorder(common.ModeReplay, filepath.Join("testdata", fixture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Scrapper{Handle: handle, Context: context.Background(), Fetcher: recorder}
+}
+
+func TestCheckHandle(t *testing.T) {
+	Convey("Subject: Spoj handle validation\n", t, func() {
+		valid, err := newScrapper(t, "alice", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeTrue)
+		valid, err = newScrapper(t, "nobody", "profile").CheckHandle()
+		So(err, ShouldBeNil)
+		So(valid, ShouldBeFalse)
+	})
+}
+
+func TestGetProfileInfo(t *testing.T) {
+	Convey("Subject: Spoj profile\n", t, func() {
+		profile, err := newScrapper(t, "alice", "profile").GetProfileInfo()
+		So(err, ShouldBeNil)
+		So(profile.UserName, ShouldEqual, "alice")
+		So(profile.Name, ShouldEqual, "Alice Liddell")
+		So(profile.GlobalRank, ShouldEqual, 1234)
+		So(profile.School, ShouldContainSubstring, "IIT Roorkee")
+
+		Convey("Unknown handles should be reported", func() {
+			_, err := newScrapper(t, "nobody", "profile").GetProfileInfo()
+			So(ScrapeErrorKind(err), ShouldEqual, HandleNotFound)
+		})
+	})
+}
+
+func TestGetSubmissions(t *testing.T) {
+	Convey("Subject: Spoj submissions\n", t, func() {
+		subs, err := newScrapper(t, "alice", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		So(len(subs), ShouldEqual, 3)
+		So(subs[0].Name, ShouldEqual, "PRIME1")
+		So(subs[0].URL, ShouldEqual, "https://www.spoj.com/problems/PRIME1/")
+		So(subs[0].ProblemID, ShouldEqual, "spoj/PRIME1")
+		So(subs[0].ID, ShouldEqual, "25000003")
+		So(subs[0].SubmissionURL, ShouldEqual, "https://www.spoj.com/files/src/25000003/")
+		So(subs[0].Status, ShouldEqual, StatusCorrect)
+		So(subs[0].Points, ShouldEqual, 100)
+		So(subs[0].Language, ShouldEqual, "C++")
+		So(subs[0].Tags, ShouldResemble, []string{"#number-theory", "#sieve"})
+		So(subs[1].Status, ShouldEqual, StatusWrongAnswer)
+		So(subs[2].Status, ShouldEqual, StatusCompilationError)
+		So(subs[2].Tags, ShouldBeEmpty)
+		So(subs[2].CreationDate, ShouldEqual, time.Date(2020, 2, 1, 10, 0, 0, 0, time.UTC))
+	})
+}
+
+func TestVerdicts(t *testing.T) {
+	Convey("Subject: Spoj verdicts\n", t, func() {
+		subs, err := newScrapper(t, "bob", "submissions").GetSubmissions(time.Time{})
+		So(err, ShouldBeNil)
+		So(len(subs), ShouldEqual, 3)
+		So(subs[0].Status, ShouldEqual, StatusPending)
+		So(subs[1].Status, ShouldEqual, StatusTimeLimitExceeded)
+		So(subs[2].Status, ShouldEqual, StatusRuntimeError)
+	})
+}
+
+func TestGetProblems(t *testing.T) {
+	Convey("Subject: Spoj problems\n", t, func() {
+		problems, err := newScrapper(t, "", "problems").GetProblems()
+		So(err, ShouldBeNil)
+		So(len(problems), ShouldEqual, 51)
+		So(problems[0].ID, ShouldEqual, "spoj/PRIME1")
+		So(problems[0].Index, ShouldEqual, "PRIME1")
+		So(problems[0].Name, ShouldEqual, "Prime Generator")
+		So(problems[0].URL, ShouldEqual, "https://www.spoj.com/problems/PRIME1/")
+		So(problems[50].ID, ShouldEqual, "spoj/ONP")
+	})
+}
@@ -0,0 +1,128 @@
+package lifecycle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/getsentry/sentry-go"
+)
+
+// Tasks still running once their contexts are canceled are waited for this long to wind up,
+// eg. to put their jobs back in the queue
+const windUpPeriod = 5 * time.Second
+
+// Manager runs the long running background work of the process, eg. the workers. Work which is
+// to survive the shutdown is queued as jobs instead, as it is lost once canceled.
+// On shutdown, new work is no longer taken and the running tasks are waited for till the grace
+// period is over, after which their contexts are canceled
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	// Closed once the shutdown begins
+	stopping chan struct{}
+	mutex    sync.Mutex
+	tasks    sync.WaitGroup
+}
+
+func New() *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{ctx: ctx, cancel: cancel, stopping: make(chan struct{})}
+}
+
+// Context returns a context for the work started by the parent, eg. by a request. It carries
+// a clone of the sentry hub of the parent, but is canceled only on shutdown or by the returned func
+func (m *Manager) Context(parent context.Context) (context.Context, context.CancelFunc) {
+	hub := sentry.CurrentHub()
+	if parent != nil {
+		if h := sentry.GetHubFromContext(parent); h != nil {
+			hub = h
+		}
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	return sentry.SetHubOnContext(ctx, hub.Clone()), cancel
+}
+
+// Go runs the task in the background with a context from Context. Shutdown waits for the tasks
+// started before it begins, the later ones are not waited for
+func (m *Manager) Go(parent context.Context, task func(ctx context.Context)) {
+	ctx, cancel := m.Context(parent)
+	waited := false
+	m.mutex.Lock()
+	select {
+	case <-m.stopping:
+	default:
+		m.tasks.Add(1)
+		waited = true
+	}
+	m.mutex.Unlock()
+	go func() {
+		defer cancel()
+		if waited {
+			defer m.tasks.Done()
+		}
+		task(ctx)
+	}()
+}
+
+// Stopping is closed once the shutdown begins, long running tasks stop taking new work then
+func (m *Manager) Stopping() <-chan struct{} {
+	return m.stopping
+}
+
+// Shutdown waits for the background tasks till the grace period is over, then cancels their
+// contexts and gives them a few seconds more. Returns whether every task finished in time
+func (m *Manager) Shutdown(grace time.Duration) bool {
+	m.mutex.Lock()
+	select {
+	case <-m.stopping:
+	default:
+		close(m.stopping)
+	}
+	m.mutex.Unlock()
+	done := make(chan struct{})
+	go func() {
+		m.tasks.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+	}
+	m.cancel()
+	select {
+	case <-done:
+	case <-time.After(windUpPeriod):
+	}
+	return false
+}
+
+// Manager of the background work of the process
+var manager = New()
+
+// Background work is given this long to finish on shutdown
+var gracePeriod = time.Duration(beego.AppConfig.DefaultInt("SHUTDOWN_GRACE_PERIOD", 25)) * time.Second
+
+// Context returns a context of the process manager for the work started by the parent
+func Context(parent context.Context) (context.Context, context.CancelFunc) {
+	return manager.Context(parent)
+}
+
+// Go runs the task in the background of the process, shutdown waits for it
+func Go(parent context.Context, task func(ctx context.Context)) {
+	manager.Go(parent, task)
+}
+
+// Stopping is closed once the process begins shutting down
+func Stopping() <-chan struct{} {
+	return manager.Stopping()
+}
+
+// Shutdown stops the background work of the process within SHUTDOWN_GRACE_PERIOD seconds
+func Shutdown() bool {
+	return manager.Shutdown(gracePeriod)
+}
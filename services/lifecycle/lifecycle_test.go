@@ -0,0 +1,43 @@
+package lifecycle
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestManager(t *testing.T) {
+	Convey("Subject: Background work of the process\n", t, func() {
+		m := New()
+
+		Convey("Context should outlive its parent, along with the hub", func() {
+			hub := sentry.CurrentHub().Clone()
+			parent, cancel := context.WithCancel(sentry.SetHubOnContext(context.Background(), hub))
+			ctx, _ := m.Context(parent)
+			cancel()
+			So(ctx.Err(), ShouldBeNil)
+			So(sentry.GetHubFromContext(ctx), ShouldNotBeNil)
+			So(sentry.GetHubFromContext(ctx), ShouldNotEqual, hub)
+		})
+		Convey("Shutdown should wait for the tasks finishing in time", func() {
+			finished := make(chan bool, 1)
+			m.Go(context.Background(), func(ctx context.Context) {
+				time.Sleep(10 * time.Millisecond)
+				finished <- ctx.Err() == nil
+			})
+			So(m.Shutdown(time.Second), ShouldBeTrue)
+			So(<-finished, ShouldBeTrue)
+			_, open := <-m.Stopping()
+			So(open, ShouldBeFalse)
+		})
+		Convey("Tasks outliving the grace period should be canceled", func() {
+			m.Go(context.Background(), func(ctx context.Context) {
+				<-ctx.Done()
+			})
+			So(m.Shutdown(10*time.Millisecond), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/globalsign/mgo/bson"
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/services/lifecycle"
+	"github.com/mdg-iitr/Codephile/services/worker"
+)
+
+// Refresh of the data of a user on a site, which is due now
+type Refresh struct {
+	User bson.ObjectId
+	Site string
+	// Time of the latest submission of the user on the site, zero if there is none
+	LastActivity time.Time
+	// Last time the user was seen on codephile, zero if never
+	LastSeen time.Time
+	// Kind of the failure of the last sync with the site, empty if it succeeded
+	FailureKind string
+}
+
+// Store keeps the time every handle is due to be refreshed at
+type Store interface {
+	// Seed schedules the handles which are not scheduled yet, at times spread over the span
+	Seed(span time.Duration) error
+	// Due returns the refreshes due by the time, the most overdue first
+	Due(now time.Time, limit int) ([]Refresh, error)
+	// Reschedule sets the time the refresh is due at next
+	Reschedule(r Refresh, next time.Time) error
+}
+
+// Due refreshes are looked up this often
+const tick = time.Minute
+
+// Handles linked since the last seeding are scheduled this often
+const seedInterval = time.Hour
+
+// Refreshes queued at most in a tick, the rest are queued in the next ones
+const batchSize = 200
+
+// Recent users are refreshed this many times in the time since they were last active
+const activityRatio = 6
+
+// Intervals are varied by this fraction, so that the refreshes queued together drift apart
+const jitter = 0.2
+
+// Kinds of the jobs queued for a refresh
+var refreshJobs = []string{worker.SubmissionsJob, worker.ProfileJob}
+
+// Returns the interval after which the handle is refreshed again. It shrinks with the time since
+// the user was last active on the site or on codephile, so that their new solves show up soon
+func Interval(r Refresh, now time.Time) time.Duration {
+	// Missing handles stay missing, till they are changed
+	if r.FailureKind == HandleNotFound {
+		return MaxRefreshInterval
+	}
+	recent := r.LastActivity
+	if r.LastSeen.After(recent) {
+		recent = r.LastSeen
+	}
+	if recent.IsZero() {
+		return MaxRefreshInterval
+	}
+	interval := now.Sub(recent) / activityRatio
+	if interval < MinRefreshInterval {
+		return MinRefreshInterval
+	}
+	if interval > MaxRefreshInterval {
+		return MaxRefreshInterval
+	}
+	return interval
+}
+
+// Locker elects the instance which schedules the refreshes
+type Locker interface {
+	// Acquire takes or renews the lock for the duration, false is returned if it is held by another
+	Acquire(ttl time.Duration) (bool, error)
+}
+
+// Takes the lock if it is free, or renews it if it is held by the same instance
+var acquireScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if not holder then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+const lockKey = "scheduler:lock"
+
+// redisLock is held by an instance till it stops renewing it
+type redisLock struct {
+	client *redis.Client
+	id     string
+}
+
+func (l redisLock) Acquire(ttl time.Duration) (bool, error) {
+	held, err := acquireScript.Run(l.client, []string{lockKey}, l.id, int64(ttl/time.Millisecond)).Int64()
+	return held == 1, err
+}
+
+// Scheduler queues the refreshes of the handles in the background lane of the workers
+type Scheduler struct {
+	store    Store
+	lock     Locker
+	enqueue  func(job worker.Job) (string, error)
+	now      func() time.Time
+	random   func() float64
+	lastSeed time.Time
+}
+
+func New(store Store, lock Locker) *Scheduler {
+	return &Scheduler{store: store, lock: lock, enqueue: worker.Enqueue, now: time.Now, random: rand.Float64}
+}
+
+var startOnce sync.Once
+
+// Start runs the scheduler in the background, once for the process. Every instance runs it,
+// the one holding the lock in redis schedules the refreshes
+func Start(store Store, client *redis.Client) {
+	startOnce.Do(func() {
+		s := New(store, redisLock{client: client, id: uuid.New().String()})
+		lifecycle.Go(context.Background(), func(context.Context) { s.Run() })
+	})
+}
+
+// Run schedules the refreshes every tick while the lock is held, till the process begins shutting down
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		s.scheduleIfHeld()
+		select {
+		case <-lifecycle.Stopping():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) scheduleIfHeld() {
+	// Lock outlives a few missed renewals
+	held, err := s.lock.Acquire(3 * tick)
+	if err != nil {
+		log.Println("scheduler:", err.Error())
+		return
+	}
+	if !held {
+		return
+	}
+	if err := s.Schedule(); err != nil {
+		sentry.CaptureException(err)
+		log.Println("scheduler:", err.Error())
+	}
+}
+
+// Schedule seeds the new handles once in a while, and queues the refreshes which are due
+func (s *Scheduler) Schedule() error {
+	now := s.now()
+	if now.Sub(s.lastSeed) >= seedInterval {
+		// New handles are spread over a day, rather than refreshed at once
+		if err := s.store.Seed(24 * time.Hour); err != nil {
+			return err
+		}
+		s.lastSeed = now
+	}
+	due, err := s.store.Due(now, batchSize)
+	if err != nil {
+		return err
+	}
+	for _, r := range due {
+		for _, kind := range refreshJobs {
+			job := worker.NewJob(r.User, r.Site, kind)
+			job.Priority = worker.Background
+			_, err := s.enqueue(job)
+			// Rest of the refreshes stay due till the workers catch up
+			if err == ErrJobQueueFull {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+		interval := Interval(r, now)
+		interval += time.Duration((s.random()*2 - 1) * jitter * float64(interval))
+		if err := s.store.Reschedule(r, now.Add(interval)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/smartystreets/goconvey/convey"
+
+	. "github.com/mdg-iitr/Codephile/conf"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/services/worker"
+)
+
+// Store holding the due refreshes in memory
+type memoryStore struct {
+	due    []Refresh
+	next   map[string]time.Time
+	seeded int
+}
+
+func (s *memoryStore) Seed(span time.Duration) error {
+	s.seeded++
+	return nil
+}
+
+func (s *memoryStore) Due(now time.Time, limit int) ([]Refresh, error) {
+	return s.due, nil
+}
+
+func (s *memoryStore) Reschedule(r Refresh, next time.Time) error {
+	s.next[r.User.Hex()+":"+r.Site] = next
+	return nil
+}
+
+func TestInterval(t *testing.T) {
+	Convey("Subject: Refresh intervals\n", t, func() {
+		now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+		Convey("Users active lately should be refreshed often", func() {
+			So(Interval(Refresh{LastActivity: now.Add(-time.Minute)}, now), ShouldEqual, MinRefreshInterval)
+			So(Interval(Refresh{LastActivity: now.Add(-6 * time.Hour)}, now), ShouldEqual, time.Hour)
+		})
+		Convey("Users seen on codephile should be refreshed as often as the active ones", func() {
+			r := Refresh{LastActivity: now.Add(-30 * 24 * time.Hour), LastSeen: now.Add(-12 * time.Hour)}
+			So(Interval(r, now), ShouldEqual, 2*time.Hour)
+		})
+		Convey("Dormant users and missing handles should be refreshed rarely", func() {
+			So(Interval(Refresh{}, now), ShouldEqual, MaxRefreshInterval)
+			So(Interval(Refresh{LastActivity: now.Add(-365 * 24 * time.Hour)}, now), ShouldEqual, MaxRefreshInterval)
+			r := Refresh{LastActivity: now.Add(-time.Minute), FailureKind: HandleNotFound}
+			So(Interval(r, now), ShouldEqual, MaxRefreshInterval)
+		})
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	Convey("Subject: Scheduling refreshes\n", t, func() {
+		now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+		alice, bob := bson.NewObjectId(), bson.NewObjectId()
+		store := &memoryStore{
+			due: []Refresh{
+				{User: alice, Site: "codeforces", LastActivity: now.Add(-6 * time.Hour)},
+				{User: bob, Site: "spoj"},
+			},
+			next: make(map[string]time.Time),
+		}
+		var queued []worker.Job
+		s := New(store, nil)
+		s.now = func() time.Time { return now }
+		s.random = func() float64 { return 0.5 }
+		s.enqueue = func(job worker.Job) (string, error) {
+			queued = append(queued, job)
+			return "", nil
+		}
+
+		Convey("Due refreshes should be queued in the background lane and rescheduled", func() {
+			So(s.Schedule(), ShouldBeNil)
+			So(store.seeded, ShouldEqual, 1)
+			So(queued, ShouldHaveLength, 4)
+			for _, job := range queued {
+				So(job.Priority, ShouldEqual, worker.Background)
+			}
+			So(queued[0].Kind, ShouldEqual, worker.SubmissionsJob)
+			So(queued[1].Kind, ShouldEqual, worker.ProfileJob)
+			So(store.next[alice.Hex()+":codeforces"], ShouldEqual, now.Add(time.Hour))
+			So(store.next[bob.Hex()+":spoj"], ShouldEqual, now.Add(MaxRefreshInterval))
+		})
+		Convey("Handles should be seeded once in a while", func() {
+			So(s.Schedule(), ShouldBeNil)
+			now = now.Add(tick)
+			So(s.Schedule(), ShouldBeNil)
+			So(store.seeded, ShouldEqual, 1)
+			now = now.Add(seedInterval)
+			So(s.Schedule(), ShouldBeNil)
+			So(store.seeded, ShouldEqual, 2)
+		})
+		Convey("Refreshes should stay due while the lane is full", func() {
+			s.enqueue = func(job worker.Job) (string, error) {
+				if job.User == bob {
+					return "", ErrJobQueueFull
+				}
+				return "", nil
+			}
+			So(s.Schedule(), ShouldBeNil)
+			So(store.next, ShouldContainKey, alice.Hex()+":codeforces")
+			So(store.next, ShouldNotContainKey, bob.Hex()+":spoj")
+		})
+	})
+}
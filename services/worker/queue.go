@@ -0,0 +1,519 @@
+package worker
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/mdg-iitr/Codephile/errors"
+)
+
+// Queue holds the jobs till a worker takes them. A taken job is leased to the worker for
+// the visibility timeout, and is handed out again unless it is acknowledged or extended by then.
+// Jobs are kept in a lane per priority, the background lane is served once the interactive one is empty
+type Queue interface {
+	// Push adds the job and returns its ID. A job of the same user, site and kind which is still
+	// queued takes the place of the new one, its ID is returned instead. It is moved to the
+	// interactive lane if the new job is interactive. ErrJobQueueFull is returned if the lane is full
+	Push(job Job) (string, error)
+	// Pop leases the oldest job of the interactive lane, or of the background one if it is empty.
+	// False is returned if there is no job
+	Pop(timeout time.Duration) (Job, bool, error)
+	// Extend renews the lease of a job which is still being worked upon
+	Extend(job Job, timeout time.Duration) error
+	// Ack removes the finished job
+	Ack(job Job) error
+	// Release ends the lease of the job cut off by the shutdown, it is handed out again at once
+	// with no attempt counted
+	Release(job Job) error
+	// Retry ends the lease of the failed job, and queues it again at the given time
+	Retry(job Job, at time.Time) error
+	// Bury ends the lease of the job which has exhausted its retries, and keeps it as dead-lettered
+	Bury(dead DeadJob) error
+	// Dead returns the dead-lettered jobs
+	Dead() ([]DeadJob, error)
+	// Revive queues the dead-lettered job of the ID again, with no attempts made.
+	// DeadJobNotFoundError is returned if there is no such job
+	Revive(id string) (Job, error)
+	// Purge removes the dead-lettered jobs of the IDs, or all of them if none is given
+	Purge(ids ...string) error
+}
+
+// Jobs of the same user, site and kind share the key, only one of them is queued at once
+func (job Job) key() string {
+	return job.User.Hex() + ":" + job.Site + ":" + job.Kind
+}
+
+// Returns the job encoded for the queue, which identifies its lease
+func encode(job Job) (string, error) {
+	payload, err := json.Marshal(job)
+	return string(payload), err
+}
+
+func decode(payload string) (Job, error) {
+	var job Job
+	err := json.Unmarshal([]byte(payload), &job)
+	job.payload = payload
+	return job, err
+}
+
+// Sizes of the lanes
+type laneSizes struct {
+	interactive int
+	background  int
+}
+
+func (s laneSizes) of(priority string) int {
+	if priority == Interactive {
+		return s.interactive
+	}
+	return s.background
+}
+
+// memoryQueue keeps the jobs in the process, they are lost on restarts
+type memoryQueue struct {
+	mutex    sync.Mutex
+	sizes    laneSizes
+	lanes    map[string][]Job
+	inFlight map[string]Job
+	// Ends of the leases of the jobs in flight, by payload
+	deadlines map[string]time.Time
+	// Jobs to be retried, along with the time they are queued at
+	delayed map[string]time.Time
+	dead    map[string]DeadJob
+	now     func() time.Time
+}
+
+func newMemoryQueue(sizes laneSizes) *memoryQueue {
+	return &memoryQueue{
+		sizes:     sizes,
+		lanes:     map[string][]Job{Interactive: nil, Background: nil},
+		inFlight:  make(map[string]Job),
+		deadlines: make(map[string]time.Time),
+		delayed:   make(map[string]time.Time),
+		dead:      make(map[string]DeadJob),
+		now:       time.Now,
+	}
+}
+
+func (q *memoryQueue) Push(job Job) (string, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for priority, lane := range q.lanes {
+		for i, queued := range lane {
+			if queued.key() != job.key() {
+				continue
+			}
+			if job.Priority == Interactive && priority != Interactive {
+				queued.Priority = Interactive
+				q.lanes[priority] = append(lane[:i:i], lane[i+1:]...)
+				q.lanes[Interactive] = append(q.lanes[Interactive], queued)
+			}
+			return queued.ID, nil
+		}
+	}
+	if len(q.lanes[job.Priority]) >= q.sizes.of(job.Priority) {
+		return "", errors.ErrJobQueueFull
+	}
+	q.lanes[job.Priority] = append(q.lanes[job.Priority], job)
+	return job.ID, nil
+}
+
+func (q *memoryQueue) Pop(timeout time.Duration) (Job, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	now := q.now()
+	// Jobs whose lease has expired are handed out first, expiries count as failed attempts
+	maxRetries := retryPolicyOf(LeaseExpired).MaxRetries
+	for payload, deadline := range q.deadlines {
+		if !deadline.After(now) {
+			job := q.inFlight[payload]
+			delete(q.deadlines, payload)
+			delete(q.inFlight, payload)
+			if job.Attempt >= maxRetries {
+				q.dead[job.ID] = DeadJob{Job: job, ErrorKind: LeaseExpired, Error: leaseExpiredError, DiedAt: now}
+				continue
+			}
+			job.Attempt++
+			q.lanes[job.Priority] = append([]Job{job}, q.lanes[job.Priority]...)
+		}
+	}
+	for payload, at := range q.delayed {
+		if !at.After(now) {
+			delete(q.delayed, payload)
+			job, err := decode(payload)
+			if err != nil {
+				return Job{}, false, err
+			}
+			q.lanes[job.Priority] = append(q.lanes[job.Priority], job)
+		}
+	}
+	for _, priority := range []string{Interactive, Background} {
+		lane := q.lanes[priority]
+		if len(lane) == 0 {
+			continue
+		}
+		job := lane[0]
+		q.lanes[priority] = lane[1:]
+		payload, err := encode(job)
+		if err != nil {
+			return Job{}, false, err
+		}
+		job.payload = payload
+		q.inFlight[payload] = job
+		q.deadlines[payload] = now.Add(timeout)
+		return job, true, nil
+	}
+	return Job{}, false, nil
+}
+
+func (q *memoryQueue) Extend(job Job, timeout time.Duration) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.deadlines[job.payload]; ok {
+		q.deadlines[job.payload] = q.now().Add(timeout)
+	}
+	return nil
+}
+
+func (q *memoryQueue) Ack(job Job) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.deadlines, job.payload)
+	delete(q.inFlight, job.payload)
+	return nil
+}
+
+func (q *memoryQueue) Release(job Job) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, ok := q.inFlight[job.payload]; !ok {
+		return nil
+	}
+	delete(q.deadlines, job.payload)
+	delete(q.inFlight, job.payload)
+	q.lanes[job.Priority] = append([]Job{job}, q.lanes[job.Priority]...)
+	return nil
+}
+
+func (q *memoryQueue) Retry(job Job, at time.Time) error {
+	payload, err := encode(job)
+	if err != nil {
+		return err
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.deadlines, job.payload)
+	delete(q.inFlight, job.payload)
+	q.delayed[payload] = at
+	return nil
+}
+
+func (q *memoryQueue) Bury(dead DeadJob) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.deadlines, dead.Job.payload)
+	delete(q.inFlight, dead.Job.payload)
+	q.dead[dead.Job.ID] = dead
+	return nil
+}
+
+func (q *memoryQueue) Dead() ([]DeadJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	dead := make([]DeadJob, 0, len(q.dead))
+	for _, d := range q.dead {
+		dead = append(dead, d)
+	}
+	return dead, nil
+}
+
+func (q *memoryQueue) Revive(id string) (Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	dead, ok := q.dead[id]
+	if !ok {
+		return Job{}, errors.DeadJobNotFoundError
+	}
+	delete(q.dead, id)
+	job := dead.Job
+	job.Attempt = 0
+	q.lanes[job.Priority] = append(q.lanes[job.Priority], job)
+	return job, nil
+}
+
+func (q *memoryQueue) Purge(ids ...string) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(ids) == 0 {
+		q.dead = make(map[string]DeadJob)
+		return nil
+	}
+	removed := 0
+	for _, id := range ids {
+		if _, ok := q.dead[id]; ok {
+			delete(q.dead, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return errors.DeadJobNotFoundError
+	}
+	return nil
+}
+
+// Keys of the redis queue
+const (
+	inFlightKey = "worker:inflight"
+	// Jobs to be retried, scored by the time they are queued at
+	delayedKey = "worker:delayed"
+	// Dead-lettered jobs by ID
+	deadKey = "worker:dead"
+	// Lane of the jobs of a priority, followed by the priority
+	laneKeyPrefix = "worker:lane:"
+	// Queued job of a user, site and kind, followed by the key of the job
+	queuedKeyPrefix = "worker:queued:"
+)
+
+// Jobs are marked as queued for at most this long, in case they are lost along with redis data
+const queuedMarkTTL = 24 * time.Hour
+
+// Error of the jobs dead-lettered as their leases kept expiring
+const leaseExpiredError = "lease expired, the worker crashed or hung"
+
+// Pushes the job unless one of the same key is queued, which is promoted to the interactive
+// lane if the new job is interactive. Returns the ID of the queued job, or false if the lane is full
+var pushScript = redis.NewScript(`
+local queued = redis.call("GET", KEYS[1])
+if queued then
+	local job = cjson.decode(queued)
+	if ARGV[2] == "interactive" and job.priority ~= "interactive" and redis.call("LREM", KEYS[3], 1, queued) == 1 then
+		job.priority = "interactive"
+		local promoted = cjson.encode(job)
+		redis.call("SET", KEYS[1], promoted, "EX", ARGV[4])
+		redis.call("LPUSH", KEYS[2], promoted)
+	end
+	return job.id
+end
+local lane = KEYS[3]
+if ARGV[2] == "interactive" then
+	lane = KEYS[2]
+end
+if redis.call("LLEN", lane) >= tonumber(ARGV[3]) then
+	return false
+end
+redis.call("SET", KEYS[1], ARGV[1], "EX", ARGV[4])
+redis.call("LPUSH", lane, ARGV[1])
+return ARGV[5]
+`)
+
+// Puts the jobs with expired leases back at the head of their lanes with an attempt counted, or
+// dead-letters them past the retries, and puts the retries which are due at the tail. Requeued
+// jobs are marked as queued again unless a job of the same key is. Then the head of the interactive
+// lane, or of the background one, is leased. The leased job is no longer marked as queued
+var popScript = redis.NewScript(`
+local function lane(job)
+	if job.priority == "interactive" then
+		return KEYS[1]
+	end
+	return KEYS[2]
+end
+local function mark(job, payload)
+	redis.call("SET", ARGV[3] .. job.user .. ":" .. job.site .. ":" .. job.kind, payload, "EX", ARGV[5], "NX")
+end
+local expired = redis.call("ZRANGEBYSCORE", KEYS[3], "-inf", ARGV[1])
+for _, payload in ipairs(expired) do
+	redis.call("ZREM", KEYS[3], payload)
+	local job = cjson.decode(payload)
+	local attempt = job.attempt or 0
+	if attempt >= tonumber(ARGV[4]) then
+		redis.call("HSET", KEYS[5], job.id, cjson.encode({job = job, error_kind = ARGV[6], error = ARGV[7], died_at = ARGV[8]}))
+	else
+		job.attempt = attempt + 1
+		local requeued = cjson.encode(job)
+		mark(job, requeued)
+		redis.call("RPUSH", lane(job), requeued)
+	end
+end
+local retries = redis.call("ZRANGEBYSCORE", KEYS[4], "-inf", ARGV[1])
+for _, payload in ipairs(retries) do
+	redis.call("ZREM", KEYS[4], payload)
+	local job = cjson.decode(payload)
+	mark(job, payload)
+	redis.call("LPUSH", lane(job), payload)
+end
+local payload = redis.call("RPOP", KEYS[1])
+if not payload then
+	payload = redis.call("RPOP", KEYS[2])
+end
+if not payload then
+	return false
+end
+redis.call("ZADD", KEYS[3], ARGV[2], payload)
+local job = cjson.decode(payload)
+local queued = ARGV[3] .. job.user .. ":" .. job.site .. ":" .. job.kind
+if redis.call("GET", queued) == payload then
+	redis.call("DEL", queued)
+end
+return payload
+`)
+
+// redisQueue keeps the jobs in redis, so that they survive restarts and are shared by
+// the instances. Leased jobs are kept in a sorted set scored by the end of their lease
+type redisQueue struct {
+	client *redis.Client
+	sizes  laneSizes
+}
+
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+func (q redisQueue) Push(job Job) (string, error) {
+	payload, err := encode(job)
+	if err != nil {
+		return "", err
+	}
+	id, err := pushScript.Run(q.client,
+		[]string{queuedKeyPrefix + job.key(), laneKeyPrefix + Interactive, laneKeyPrefix + Background},
+		payload, job.Priority, q.sizes.of(job.Priority), int64(queuedMarkTTL/time.Second), job.ID).String()
+	if err == redis.Nil {
+		return "", errors.ErrJobQueueFull
+	}
+	return id, err
+}
+
+func (q redisQueue) Pop(timeout time.Duration) (Job, bool, error) {
+	now := time.Now()
+	payload, err := popScript.Run(q.client, []string{laneKeyPrefix + Interactive, laneKeyPrefix + Background, inFlightKey, delayedKey, deadKey},
+		millis(now), millis(now.Add(timeout)), queuedKeyPrefix, retryPolicyOf(LeaseExpired).MaxRetries,
+		int64(queuedMarkTTL/time.Second), LeaseExpired, leaseExpiredError, now.Format(time.RFC3339Nano)).String()
+	if err == redis.Nil {
+		return Job{}, false, nil
+	} else if err != nil {
+		return Job{}, false, err
+	}
+	job, err := decode(payload)
+	if err != nil {
+		// Undecodable jobs would be handed out forever
+		q.client.ZRem(inFlightKey, payload)
+		return Job{}, false, err
+	}
+	return job, true, nil
+}
+
+func (q redisQueue) Extend(job Job, timeout time.Duration) error {
+	return q.client.ZAddXX(inFlightKey, redis.Z{
+		Score:  float64(time.Now().Add(timeout).UnixNano() / int64(time.Millisecond)),
+		Member: job.payload,
+	}).Err()
+}
+
+func (q redisQueue) Ack(job Job) error {
+	return q.client.ZRem(inFlightKey, job.payload).Err()
+}
+
+// Puts the leased job back at the head of its lane, marked as queued unless a job of the same key is
+var releaseScript = redis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+local job = cjson.decode(ARGV[1])
+redis.call("SET", ARGV[2] .. job.user .. ":" .. job.site .. ":" .. job.kind, ARGV[1], "EX", ARGV[3], "NX")
+if job.priority == "interactive" then
+	redis.call("RPUSH", KEYS[2], ARGV[1])
+else
+	redis.call("RPUSH", KEYS[3], ARGV[1])
+end
+return 1
+`)
+
+func (q redisQueue) Release(job Job) error {
+	return releaseScript.Run(q.client, []string{inFlightKey, laneKeyPrefix + Interactive, laneKeyPrefix + Background},
+		job.payload, queuedKeyPrefix, int64(queuedMarkTTL/time.Second)).Err()
+}
+
+func (q redisQueue) Retry(job Job, at time.Time) error {
+	payload, err := encode(job)
+	if err != nil {
+		return err
+	}
+	pipe := q.client.TxPipeline()
+	pipe.ZRem(inFlightKey, job.payload)
+	pipe.ZAdd(delayedKey, redis.Z{Score: float64(at.UnixNano() / int64(time.Millisecond)), Member: payload})
+	_, err = pipe.Exec()
+	return err
+}
+
+func (q redisQueue) Bury(dead DeadJob) error {
+	entry, err := json.Marshal(dead)
+	if err != nil {
+		return err
+	}
+	pipe := q.client.TxPipeline()
+	pipe.ZRem(inFlightKey, dead.Job.payload)
+	pipe.HSet(deadKey, dead.Job.ID, entry)
+	_, err = pipe.Exec()
+	return err
+}
+
+func (q redisQueue) Dead() ([]DeadJob, error) {
+	entries, err := q.client.HVals(deadKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	dead := make([]DeadJob, 0, len(entries))
+	for _, entry := range entries {
+		var d DeadJob
+		if err := json.Unmarshal([]byte(entry), &d); err != nil {
+			return nil, err
+		}
+		dead = append(dead, d)
+	}
+	return dead, nil
+}
+
+// Moves the dead-lettered job to the tail of its lane with no attempts made, returns false if
+// there is no such job
+var reviveScript = redis.NewScript(`
+local dead = redis.call("HGET", KEYS[1], ARGV[1])
+if not dead then
+	return false
+end
+redis.call("HDEL", KEYS[1], ARGV[1])
+local job = cjson.decode(dead).job
+job.attempt = 0
+local payload = cjson.encode(job)
+if job.priority == "interactive" then
+	redis.call("LPUSH", KEYS[2], payload)
+else
+	redis.call("LPUSH", KEYS[3], payload)
+end
+return payload
+`)
+
+func (q redisQueue) Revive(id string) (Job, error) {
+	payload, err := reviveScript.Run(q.client, []string{deadKey, laneKeyPrefix + Interactive, laneKeyPrefix + Background}, id).String()
+	if err == redis.Nil {
+		return Job{}, errors.DeadJobNotFoundError
+	} else if err != nil {
+		return Job{}, err
+	}
+	return decode(payload)
+}
+
+func (q redisQueue) Purge(ids ...string) error {
+	if len(ids) == 0 {
+		return q.client.Del(deadKey).Err()
+	}
+	removed, err := q.client.HDel(deadKey, ids...).Result()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return errors.DeadJobNotFoundError
+	}
+	return nil
+}
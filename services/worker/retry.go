@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	. "github.com/mdg-iitr/Codephile/errors"
+)
+
+// RetryPolicy tells how the jobs failing for a reason are retried. The n-th retry is made
+// after Backoff doubled n-1 times, at most MaxBackoff, of which a random half is taken off
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jobs which have exhausted their retries are kept as dead-lettered, to be inspected
+	DeadLetter bool
+}
+
+// Kinds of the failures which are not scrapper errors
+const (
+	// Failures which would recur, eg. the user is deleted
+	PermanentFailure = "permanent"
+	UnknownFailure   = "unknown"
+	// Worker crashed or hung while performing the job, which is handed out again at once
+	LeaseExpired = "lease_expired"
+)
+
+var (
+	retryPolicies = map[string]RetryPolicy{
+		RateLimited:         {MaxRetries: 5, Backoff: time.Minute, MaxBackoff: 30 * time.Minute, DeadLetter: true},
+		PlatformUnavailable: {MaxRetries: 5, Backoff: 5 * time.Minute, MaxBackoff: 2 * time.Hour, DeadLetter: true},
+		// Scrapper has to be fixed, unless the site sent a broken response once
+		ParseFailure:   {MaxRetries: 1, Backoff: time.Hour, MaxBackoff: time.Hour, DeadLetter: true},
+		HandleNotFound: {},
+		// Fetched submissions are stored, the rest are to be uploaded by the user
+		SubmissionsTruncated: {},
+		// Errors of the database and such
+		UnknownFailure:   {MaxRetries: 3, Backoff: 30 * time.Second, MaxBackoff: 10 * time.Minute, DeadLetter: true},
+		PermanentFailure: {},
+		// Jobs crashing their workers are not to be handed out forever
+		LeaseExpired: {MaxRetries: 3, DeadLetter: true},
+	}
+	retryPoliciesMutex sync.RWMutex
+)
+
+// SetRetryPolicy changes how the jobs failing for the kind of failure are retried
+func SetRetryPolicy(kind string, policy RetryPolicy) {
+	retryPoliciesMutex.Lock()
+	defer retryPoliciesMutex.Unlock()
+	retryPolicies[kind] = policy
+}
+
+func retryPolicyOf(kind string) RetryPolicy {
+	retryPoliciesMutex.RLock()
+	defer retryPoliciesMutex.RUnlock()
+	if policy, ok := retryPolicies[kind]; ok {
+		return policy
+	}
+	return retryPolicies[UnknownFailure]
+}
+
+// FailureKind returns the kind of the failure of a job, which its retries depend on
+func FailureKind(err error) string {
+	if kind := ScrapeErrorKind(err); kind != "" {
+		return kind
+	}
+	switch err {
+	case HandleNotFoundError:
+		return HandleNotFound
+	case UserNotFoundError, VerificationTokenNotFoundError, HandleOwnershipUnprovenError:
+		return PermanentFailure
+	}
+	return UnknownFailure
+}
+
+// Returns the wait before the retry following the attempt, which counts from 0
+func backoff(policy RetryPolicy, attempt int, random func() float64) time.Duration {
+	wait := policy.Backoff
+	for i := 0; i < attempt && wait < policy.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > policy.MaxBackoff {
+		wait = policy.MaxBackoff
+	}
+	// Jitter keeps the jobs failing together from being retried together
+	return wait/2 + time.Duration(random()*float64(wait/2))
+}
+
+var random = rand.Float64
+
+// DeadJob is a job which has exhausted its retries
+type DeadJob struct {
+	Job       Job       `json:"job"`
+	ErrorKind string    `json:"error_kind"`
+	Error     string    `json:"error"`
+	DiedAt    time.Time `json:"died_at"`
+}
+
+// DeadJobs returns the dead-lettered jobs, the latest first
+func DeadJobs() ([]DeadJob, error) {
+	dead, err := currentQueue().Dead()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		return dead[i].DiedAt.After(dead[j].DiedAt)
+	})
+	return dead, nil
+}
+
+// ReviveDeadJob queues the dead-lettered job again, with its retries renewed.
+// Returns DeadJobNotFoundError/error
+func ReviveDeadJob(id string) error {
+	job, err := currentQueue().Revive(id)
+	if err != nil {
+		return err
+	}
+	if err := currentTracker().Retrying(job, nil, time.Now()); err != nil {
+		log.Println("unable to track the job", err.Error())
+	}
+	return nil
+}
+
+// PurgeDeadJobs removes the dead-lettered jobs of the IDs, or all of them if none is given
+func PurgeDeadJobs(ids ...string) error {
+	return currentQueue().Purge(ids...)
+}
@@ -2,76 +2,393 @@ package worker
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/astaxie/beego"
+	"github.com/getsentry/sentry-go"
 	"github.com/globalsign/mgo/bson"
-	"github.com/mdg-iitr/Codephile/errors"
+	"github.com/go-redis/redis"
+	"github.com/google/uuid"
+	. "github.com/mdg-iitr/Codephile/errors"
+	"github.com/mdg-iitr/Codephile/services/lifecycle"
 )
 
-type User bson.ObjectId
-type Website string
+// Kinds of the jobs, their handlers are registered by the models
+const (
+	SubmissionsJob  = "submissions"
+	ProfileJob      = "profile"
+	VerifyHandleJob = "verify_handle"
+)
+
+// Priorities of the jobs, each is queued in its own lane
+const (
+	// Jobs requested by the users, eg. on refreshing their profile
+	Interactive = "interactive"
+	// Bulk refreshes of the users, performed when no interactive job is queued
+	Background = "background"
+)
 
+// Handler is called when a job is performed
+type Handler func(user bson.ObjectId, website string, ctx context.Context) error
+
+// Job is stored in the queue as JSON, so it names its handler by kind
 type Job struct {
-	user        User
-	websiteName Website
-	//handler func which is called when job is performed
-	handler func(user bson.ObjectId, website string, ctx context.Context) error
+	// Assigned when the job is queued, the status of the job is looked up by it
+	ID         string        `json:"id"`
+	User       bson.ObjectId `json:"user"`
+	Site       string        `json:"site"`
+	Kind       string        `json:"kind"`
+	Priority   string        `json:"priority"`
+	EnqueuedAt time.Time     `json:"enqueued_at"`
+	// Attempts made before, the job is retried with backoff if it fails
+	Attempt int `json:"attempt"`
+	// Job as encoded in the queue, which identifies its lease
+	payload string
+}
+
+// Tracker records the status of the jobs, so that the users could follow them
+type Tracker interface {
+	// Queued is called again for the repeated interactive requests, as they may have promoted
+	// a background job. The job is to be kept as it was first tracked
+	Queued(job Job) error
+	Started(job Job) error
+	// Finished is called with the results counted by the handler, and the error it returned
+	Finished(job Job, counts map[string]int, err error) error
+	// Retrying is called with the error of the failed attempt when the job is queued again
+	// to be attempted at the time, err is nil if a dead-lettered job is revived
+	Retrying(job Job, err error, at time.Time) error
+}
+
+type noTracker struct{}
+
+func (noTracker) Queued(Job) error                          { return nil }
+func (noTracker) Started(Job) error                         { return nil }
+func (noTracker) Finished(Job, map[string]int, error) error { return nil }
+func (noTracker) Retrying(Job, error, time.Time) error      { return nil }
+
+var (
+	tracker      Tracker = noTracker{}
+	trackerMutex sync.RWMutex
+)
+
+// UseTracker sets where the status of the jobs is recorded
+func UseTracker(t Tracker) {
+	trackerMutex.Lock()
+	defer trackerMutex.Unlock()
+	tracker = t
+}
+
+func currentTracker() Tracker {
+	trackerMutex.RLock()
+	defer trackerMutex.RUnlock()
+	return interactiveTracker{tracker}
+}
+
+// Tracks the jobs requested by the users only, the background refreshes would crowd their lists.
+// Background jobs promoted by the requests are tracked from then on
+type interactiveTracker struct {
+	Tracker
+}
+
+func (t interactiveTracker) Queued(job Job) error {
+	if job.Priority == Background {
+		return nil
+	}
+	return t.Tracker.Queued(job)
+}
+
+func (t interactiveTracker) Started(job Job) error {
+	if job.Priority == Background {
+		return nil
+	}
+	return t.Tracker.Started(job)
+}
+
+func (t interactiveTracker) Finished(job Job, counts map[string]int, err error) error {
+	if job.Priority == Background {
+		return nil
+	}
+	return t.Tracker.Finished(job, counts, err)
 }
 
-// Keeps track if a job corresponding to a user is already in the queue
-type UserQueue struct {
-	m map[User]bool
+func (t interactiveTracker) Retrying(job Job, err error, at time.Time) error {
+	if job.Priority == Background {
+		return nil
+	}
+	return t.Tracker.Retrying(job, err, at)
+}
+
+type countsKey struct{}
+
+// Results counted by the handler of a job
+type counts struct {
 	sync.Mutex
+	m map[string]int
 }
 
-var jobQueue chan Job
-var userQueue UserQueue
+// Count adds n to the results of the job performed with the context, eg. the submissions
+// added by it. Nothing is counted if the handler is called outside of a job
+func Count(ctx context.Context, name string, n int) {
+	c, ok := ctx.Value(countsKey{}).(*counts)
+	if !ok {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.m[name] += n
+}
+
+var (
+	handlers      = make(map[string]Handler)
+	handlersMutex sync.RWMutex
+)
+
+// Modes of running the workers, set as WORKER_MODE in app.conf
+const (
+	// Every API instance performs the queued jobs and runs the scheduler
+	EmbeddedMode = "embedded"
+	// API instances only queue the jobs, which are performed by the instances of cmd/worker
+	StandaloneMode = "standalone"
+)
 
-func NewJob(user bson.ObjectId, websiteName string, handler func(user bson.ObjectId, website string, ctx context.Context) error) Job {
-	return Job{user: User(user), websiteName: Website(websiteName), handler: handler}
+// Mode returns how the workers are run, EmbeddedMode unless configured otherwise
+func Mode() string {
+	return beego.AppConfig.DefaultString("WORKER_MODE", EmbeddedMode)
 }
 
-func init() {
-	jobQueue = make(chan Job, beego.AppConfig.DefaultInt("MAX_QUEUE_SIZE", 100))
-	userQueue = UserQueue{m: map[User]bool{}}
-	startWorkerCoRoutines()
+// Jobs are waited for this long when the queue is empty
+const pollInterval = time.Second
+
+var (
+	queue      Queue = newMemoryQueue(configuredLaneSizes())
+	queueMutex sync.RWMutex
+	startOnce  sync.Once
+)
+
+// Jobs are handed out again if their worker does not renew the lease for this long
+var visibilityTimeout = time.Duration(beego.AppConfig.DefaultInt("JOB_VISIBILITY_TIMEOUT", 300)) * time.Second
+
+// Returns an interactive job, its priority could be changed before it is queued
+func NewJob(user bson.ObjectId, websiteName string, kind string) Job {
+	return Job{User: user, Site: websiteName, Kind: kind, Priority: Interactive}
 }
 
-func work() {
-	for job := range jobQueue {
-		err := job.handler(bson.ObjectId(job.user), string(job.websiteName), context.Background())
+func configuredLaneSizes() laneSizes {
+	return laneSizes{
+		interactive: beego.AppConfig.DefaultInt("MAX_QUEUE_SIZE", 100),
+		background:  beego.AppConfig.DefaultInt("MAX_BACKGROUND_QUEUE_SIZE", 10000),
+	}
+}
+
+// RegisterHandler sets the handler performing the jobs of the kind
+func RegisterHandler(kind string, handler Handler) {
+	handlersMutex.Lock()
+	defer handlersMutex.Unlock()
+	handlers[kind] = handler
+}
+
+func handlerOf(kind string) (Handler, bool) {
+	handlersMutex.RLock()
+	defer handlersMutex.RUnlock()
+	handler, ok := handlers[kind]
+	return handler, ok
+}
+
+// UseRedisQueue keeps the jobs in redis, so that they survive restarts and are
+// performed by the workers of any instance. Without it, jobs are kept in the process
+func UseRedisQueue(client *redis.Client) {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+	queue = redisQueue{client: client, sizes: configuredLaneSizes()}
+}
+
+func currentQueue() Queue {
+	queueMutex.RLock()
+	defer queueMutex.RUnlock()
+	return queue
+}
+
+// Performs the queued jobs with the context till the process begins shutting down
+func work(ctx context.Context) {
+	for {
+		select {
+		case <-lifecycle.Stopping():
+			return
+		default:
+		}
+		job, ok, err := currentQueue().Pop(visibilityTimeout)
 		if err != nil {
-			log.Println("unable to fetch submissions/profile", err.Error())
+			log.Println("unable to take a job", err.Error())
 		}
-		userQueue.Lock()
-		delete(userQueue.m, job.user)
-		userQueue.Unlock()
+		if !ok {
+			select {
+			case <-lifecycle.Stopping():
+				return
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+		perform(ctx, job)
 	}
 }
 
+// Performs the job while renewing its lease. Once done, the job is acknowledged, or retried
+// or dead-lettered if it failed, depending upon the retry policy of the failure.
+// Jobs cut off by the cancellation of the context are handed out again at once
+func perform(ctx context.Context, job Job) {
+	q := currentQueue()
+	t := currentTracker()
+	handler, ok := handlerOf(job.Kind)
+	if !ok {
+		log.Println("no handler for the jobs of kind", job.Kind)
+		if err := q.Ack(job); err != nil {
+			log.Println("unable to acknowledge the job", err.Error())
+		}
+		if err := t.Finished(job, nil, errors.New("unknown kind of job: "+job.Kind)); err != nil {
+			log.Println("unable to track the job", err.Error())
+		}
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(visibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := q.Extend(job, visibilityTimeout); err != nil {
+					log.Println("unable to extend the job", err.Error())
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	if err := t.Started(job); err != nil {
+		log.Println("unable to track the job", err.Error())
+	}
+	results := &counts{m: make(map[string]int)}
+	// Every job is reported to sentry on a hub of its own
+	hub := sentry.CurrentHub()
+	if h := sentry.GetHubFromContext(ctx); h != nil {
+		hub = h
+	}
+	jobCtx := sentry.SetHubOnContext(context.WithValue(ctx, countsKey{}, results), hub.Clone())
+	panicked, err := runHandler(handler, job, jobCtx)
+	close(done)
+	if panicked {
+		// Retrying would panic again on the same response, the scrapper has to be fixed
+		log.Println("job panicked, dead-lettering it", err.Error())
+		hub.CaptureException(err)
+		dead := DeadJob{Job: job, ErrorKind: ParseFailure, Error: err.Error(), DiedAt: time.Now()}
+		if err := q.Bury(dead); err != nil {
+			log.Println("unable to dead-letter the job", err.Error())
+		}
+	} else if err != nil && ctx.Err() != nil {
+		// Lease is ended rather than acknowledged, the attempt does not count
+		log.Println("job cut off, handing it out again", job.ID)
+		if err := q.Release(job); err != nil {
+			log.Println("unable to requeue the job", err.Error())
+		}
+		if err := t.Retrying(job, nil, time.Now()); err != nil {
+			log.Println("unable to track the job", err.Error())
+		}
+		return
+	} else if err != nil {
+		log.Println("unable to fetch submissions/profile", err.Error())
+		if retried := retryOrBury(q, t, job, err); retried {
+			return
+		}
+	} else if err := q.Ack(job); err != nil {
+		log.Println("unable to acknowledge the job", err.Error())
+	}
+	results.Lock()
+	defer results.Unlock()
+	if err := t.Finished(job, results.m, err); err != nil {
+		log.Println("unable to track the job", err.Error())
+	}
+}
+
+// Runs the handler of the job. Scrappers asserting on the responses of the sites panic once
+// the sites change them, the panic is returned as a parse failure rather than taking the process down
+func runHandler(handler Handler, job Job, ctx context.Context) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+			err = NewScrapeError(ParseFailure, job.Site, fmt.Errorf("handler panicked: %v", r))
+		}
+	}()
+	return false, handler(job.User, job.Site, ctx)
+}
+
+// Queues the failed job again if it has retries left, else dead-letters it, or drops it
+// if the failure is not worth keeping. Returns whether the job is to be retried
+func retryOrBury(q Queue, t Tracker, job Job, err error) bool {
+	kind := FailureKind(err)
+	policy := retryPolicyOf(kind)
+	now := time.Now()
+	if job.Attempt < policy.MaxRetries {
+		at := now.Add(backoff(policy, job.Attempt, random))
+		next := job
+		next.Attempt++
+		if err := q.Retry(next, at); err != nil {
+			log.Println("unable to retry the job", err.Error())
+			return false
+		}
+		if err := t.Retrying(next, err, at); err != nil {
+			log.Println("unable to track the job", err.Error())
+		}
+		return true
+	}
+	if policy.DeadLetter {
+		dead := DeadJob{Job: job, ErrorKind: kind, Error: err.Error(), DiedAt: now}
+		if err := q.Bury(dead); err != nil {
+			log.Println("unable to dead-letter the job", err.Error())
+		}
+		return false
+	}
+	if err := q.Ack(job); err != nil {
+		log.Println("unable to acknowledge the job", err.Error())
+	}
+	return false
+}
+
 func startWorkerCoRoutines() {
 	for i := 0; i < beego.AppConfig.DefaultInt("MAX_WORKER_POOL", 1); i++ {
-		go work()
+		lifecycle.Go(context.Background(), work)
 	}
 }
 
-func Enqueue(job Job) error {
-	// Don't allow if a job corresponding to a user is already present
-	if _, ok := userQueue.m[job.user]; ok {
-		return errors.ErrJobQueueFull
+// Start runs the workers performing the queued jobs, once for the process.
+// On shutdown, the jobs in flight are waited for till the grace period is over
+func Start() {
+	startOnce.Do(startWorkerCoRoutines)
+}
+
+// Enqueue adds the job to the lane of its priority, and returns the ID its status is looked up by.
+// Repeated requests coalesce into the job of the same user, site and kind which is still queued.
+// Returns ErrJobQueueFull if the lane is full
+func Enqueue(job Job) (string, error) {
+	if job.Priority != Background {
+		job.Priority = Interactive
 	}
-	select {
-	case jobQueue <- job:
-		userQueue.Lock()
-		defer userQueue.Unlock()
-		//jobQueue <- job
-		userQueue.m[job.user] = true
-		return nil
-	//Check if queue is full
-	default:
-		return errors.ErrJobQueueFull
+	job.ID = uuid.New().String()
+	job.EnqueuedAt = time.Now()
+	id, err := currentQueue().Push(job)
+	if err != nil {
+		return "", err
 	}
-
+	// Coalesced jobs are already tracked, unless they were queued in the background
+	// and are promoted by this request
+	if id == job.ID || job.Priority == Interactive {
+		job.ID = id
+		if err := currentTracker().Queued(job); err != nil {
+			log.Println("unable to track the job", err.Error())
+		}
+	}
+	return id, nil
 }
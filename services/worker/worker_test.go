@@ -0,0 +1,343 @@
+package worker
+
+import (
+	"context"
+	e "errors"
+	"testing"
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/mdg-iitr/Codephile/errors"
+)
+
+// Pushes a new job, as Enqueue does
+func push(q Queue, user bson.ObjectId, site string, kind string, priority string) (string, error) {
+	job := NewJob(user, site, kind)
+	job.ID = bson.NewObjectId().Hex()
+	job.Priority = priority
+	return q.Push(job)
+}
+
+func TestMemoryQueue(t *testing.T) {
+	Convey("Subject: Queue of jobs\n", t, func() {
+		q := newMemoryQueue(laneSizes{interactive: 2, background: 2})
+		now := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+		q.now = func() time.Time { return now }
+		alice, bob, carol := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+		first, err := push(q, alice, "codeforces", SubmissionsJob, Interactive)
+		So(err, ShouldBeNil)
+
+		Convey("Jobs should be taken in order", func() {
+			push(q, bob, "spoj", ProfileJob, Interactive)
+			job, ok, err := q.Pop(time.Minute)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(job.ID, ShouldEqual, first)
+			So(job.Kind, ShouldEqual, SubmissionsJob)
+			job, _, _ = q.Pop(time.Minute)
+			So(job.User, ShouldEqual, bob)
+			_, ok, err = q.Pop(time.Minute)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("Interactive jobs should be taken before the background ones", func() {
+			push(q, bob, "spoj", ProfileJob, Background)
+			push(q, carol, "spoj", ProfileJob, Interactive)
+			job, _, _ := q.Pop(time.Minute)
+			So(job.User, ShouldEqual, alice)
+			job, _, _ = q.Pop(time.Minute)
+			So(job.User, ShouldEqual, carol)
+			job, _, _ = q.Pop(time.Minute)
+			So(job.User, ShouldEqual, bob)
+			So(job.Priority, ShouldEqual, Background)
+		})
+		Convey("Repeated jobs should coalesce into the queued one", func() {
+			id, err := push(q, alice, "codeforces", SubmissionsJob, Interactive)
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, first)
+			// Jobs of other sites and kinds are queued on their own
+			id, _ = push(q, alice, "codeforces", ProfileJob, Interactive)
+			So(id, ShouldNotEqual, first)
+			job, _, _ := q.Pop(time.Minute)
+			So(job.ID, ShouldEqual, first)
+			// Job being performed may have missed the latest changes
+			id, _ = push(q, alice, "codeforces", SubmissionsJob, Background)
+			So(id, ShouldNotEqual, first)
+		})
+		Convey("Background jobs should be promoted by the interactive requests", func() {
+			queued, _ := push(q, bob, "spoj", ProfileJob, Background)
+			push(q, carol, "spoj", ProfileJob, Background)
+			id, _ := push(q, bob, "spoj", ProfileJob, Interactive)
+			So(id, ShouldEqual, queued)
+			q.Pop(time.Minute)
+			job, _, _ := q.Pop(time.Minute)
+			So(job.ID, ShouldEqual, queued)
+			So(job.Priority, ShouldEqual, Interactive)
+		})
+		Convey("Full lane should reject the jobs of its priority only", func() {
+			push(q, bob, "spoj", ProfileJob, Interactive)
+			_, err := push(q, carol, "spoj", ProfileJob, Interactive)
+			So(err, ShouldEqual, errors.ErrJobQueueFull)
+			_, err = push(q, carol, "spoj", ProfileJob, Background)
+			So(err, ShouldBeNil)
+		})
+		Convey("Jobs should be handed out again once their lease expires", func() {
+			job, _, _ := q.Pop(time.Minute)
+			now = now.Add(30 * time.Second)
+			So(q.Extend(job, time.Minute), ShouldBeNil)
+			now = now.Add(45 * time.Second)
+			_, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeFalse)
+			now = now.Add(time.Minute)
+			again, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeTrue)
+			So(again.ID, ShouldEqual, first)
+			So(again.Attempt, ShouldEqual, 1)
+		})
+		Convey("Jobs whose leases keep expiring should be dead-lettered", func() {
+			maxRetries := retryPolicyOf(LeaseExpired).MaxRetries
+			for i := 0; i <= maxRetries; i++ {
+				job, ok, _ := q.Pop(time.Minute)
+				So(ok, ShouldBeTrue)
+				So(job.Attempt, ShouldEqual, i)
+				now = now.Add(2 * time.Minute)
+			}
+			_, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeFalse)
+			dead, _ := q.Dead()
+			So(dead, ShouldHaveLength, 1)
+			So(dead[0].Job.ID, ShouldEqual, first)
+			So(dead[0].ErrorKind, ShouldEqual, LeaseExpired)
+		})
+		Convey("Acknowledged jobs should not be handed out again", func() {
+			job, _, _ := q.Pop(time.Minute)
+			So(q.Ack(job), ShouldBeNil)
+			now = now.Add(time.Hour)
+			_, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
+
+// Tracker keeping the states the jobs went through
+type recordingTracker struct {
+	states []string
+	counts map[string]int
+	err    error
+	// Time the last retry is due at
+	retryAt time.Time
+}
+
+func (t *recordingTracker) Queued(Job) error {
+	t.states = append(t.states, "queued")
+	return nil
+}
+
+func (t *recordingTracker) Started(Job) error {
+	t.states = append(t.states, "started")
+	return nil
+}
+
+func (t *recordingTracker) Finished(job Job, counts map[string]int, err error) error {
+	t.states = append(t.states, "finished")
+	t.counts, t.err = counts, err
+	return nil
+}
+
+func (t *recordingTracker) Retrying(job Job, err error, at time.Time) error {
+	t.states = append(t.states, "retrying")
+	t.err, t.retryAt = err, at
+	return nil
+}
+
+func TestPerform(t *testing.T) {
+	Convey("Subject: Performing jobs\n", t, func() {
+		q := newMemoryQueue(laneSizes{interactive: 1, background: 1})
+		queue = q
+		tracked := &recordingTracker{}
+		tracker = tracked
+		user := bson.NewObjectId()
+		var performed []string
+		RegisterHandler("test", func(uid bson.ObjectId, site string, ctx context.Context) error {
+			So(uid, ShouldEqual, user)
+			performed = append(performed, site)
+			Count(ctx, "submissions", 2)
+			Count(ctx, "submissions", 1)
+			return nil
+		})
+		id, err := Enqueue(NewJob(user, "codeforces", "test"))
+		So(err, ShouldBeNil)
+		// Repeated request coalesces into the tracked job
+		again, err := Enqueue(NewJob(user, "codeforces", "test"))
+		So(err, ShouldBeNil)
+		So(again, ShouldEqual, id)
+		job, _, _ := q.Pop(time.Minute)
+		So(job.ID, ShouldEqual, id)
+		So(job.EnqueuedAt.IsZero(), ShouldBeFalse)
+		perform(context.Background(), job)
+		So(performed, ShouldResemble, []string{"codeforces"})
+		So(tracked.states, ShouldResemble, []string{"queued", "queued", "started", "finished"})
+		So(tracked.counts, ShouldResemble, map[string]int{"submissions": 3})
+		So(tracked.err, ShouldBeNil)
+		_, err = Enqueue(NewJob(user, "spoj", "unknown"))
+		So(err, ShouldBeNil)
+
+		Convey("Jobs cut off by the shutdown should be handed out again", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			RegisterHandler("test", func(_ bson.ObjectId, _ string, ctx context.Context) error {
+				cancel()
+				<-ctx.Done()
+				return ctx.Err()
+			})
+			unknown, _, _ := q.Pop(time.Minute)
+			q.Ack(unknown)
+			Enqueue(NewJob(user, "codechef", "test"))
+			job, _, _ := q.Pop(time.Minute)
+			perform(ctx, job)
+			So(tracked.states[len(tracked.states)-1], ShouldEqual, "retrying")
+			again, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeTrue)
+			So(again.ID, ShouldEqual, job.ID)
+			So(again.Attempt, ShouldEqual, 0)
+		})
+		Convey("Background jobs should be tracked once promoted by a request", func() {
+			unknown, _, _ := q.Pop(time.Minute)
+			q.Ack(unknown)
+			tracked.states = nil
+			job := NewJob(user, "codechef", "test")
+			job.Priority = Background
+			id, _ := Enqueue(job)
+			So(tracked.states, ShouldBeEmpty)
+			promoted, _ := Enqueue(NewJob(user, "codechef", "test"))
+			So(promoted, ShouldEqual, id)
+			So(tracked.states, ShouldResemble, []string{"queued"})
+		})
+		Convey("Panicking jobs should be dead-lettered as parse failures", func() {
+			RegisterHandler("test", func(bson.ObjectId, string, context.Context) error {
+				var problem map[string]interface{}
+				_ = problem["name"].(string)
+				return nil
+			})
+			unknown, _, _ := q.Pop(time.Minute)
+			q.Ack(unknown)
+			Enqueue(NewJob(user, "codeforces", "test"))
+			job, _, _ := q.Pop(time.Minute)
+			So(func() { perform(context.Background(), job) }, ShouldNotPanic)
+			So(tracked.states[len(tracked.states)-1], ShouldEqual, "finished")
+			dead, _ := q.Dead()
+			So(dead, ShouldHaveLength, 1)
+			So(dead[0].ErrorKind, ShouldEqual, errors.ParseFailure)
+		})
+		Convey("Jobs of unknown kinds should fail", func() {
+			job, _, _ := q.Pop(time.Minute)
+			perform(context.Background(), job)
+			So(tracked.err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	Convey("Subject: Backoff between the retries\n", t, func() {
+		policy := RetryPolicy{MaxRetries: 10, Backoff: time.Minute, MaxBackoff: 10 * time.Minute}
+		full := func() float64 { return 1 }
+		none := func() float64 { return 0 }
+
+		Convey("Backoff should double with every attempt", func() {
+			So(backoff(policy, 0, full), ShouldEqual, time.Minute)
+			So(backoff(policy, 1, full), ShouldEqual, 2*time.Minute)
+			So(backoff(policy, 3, full), ShouldEqual, 8*time.Minute)
+		})
+		Convey("Backoff should not exceed the maximum", func() {
+			So(backoff(policy, 4, full), ShouldEqual, 10*time.Minute)
+			So(backoff(policy, 100, full), ShouldEqual, 10*time.Minute)
+		})
+		Convey("Jitter should take off at most half of the backoff", func() {
+			So(backoff(policy, 1, none), ShouldEqual, time.Minute)
+		})
+	})
+}
+
+func TestFailureKind(t *testing.T) {
+	Convey("Subject: Kinds of the failures\n", t, func() {
+		So(FailureKind(errors.NewScrapeError(errors.RateLimited, "codeforces", e.New("429"))), ShouldEqual, errors.RateLimited)
+		So(FailureKind(errors.HandleNotFoundError), ShouldEqual, errors.HandleNotFound)
+		So(FailureKind(errors.UserNotFoundError), ShouldEqual, PermanentFailure)
+		So(retryPolicyOf(errors.SubmissionsTruncated).MaxRetries, ShouldEqual, 0)
+		So(FailureKind(e.New("no reachable servers")), ShouldEqual, UnknownFailure)
+		So(retryPolicyOf("unheard of"), ShouldResemble, retryPolicyOf(UnknownFailure))
+	})
+}
+
+func TestRetry(t *testing.T) {
+	Convey("Subject: Retrying the failed jobs\n", t, func() {
+		q := newMemoryQueue(laneSizes{interactive: 1, background: 1})
+		now := time.Now()
+		q.now = func() time.Time { return now }
+		queue = q
+		tracked := &recordingTracker{}
+		tracker = tracked
+		failure := e.New("site is down")
+		RegisterHandler("failing", func(bson.ObjectId, string, context.Context) error {
+			return errors.NewScrapeError(errors.PlatformUnavailable, "spoj", failure)
+		})
+		SetRetryPolicy(errors.PlatformUnavailable, RetryPolicy{MaxRetries: 1, Backoff: time.Minute, MaxBackoff: time.Minute, DeadLetter: true})
+		id, _ := Enqueue(NewJob(bson.NewObjectId(), "spoj", "failing"))
+		job, _, _ := q.Pop(time.Minute)
+		perform(context.Background(), job)
+		So(tracked.states, ShouldResemble, []string{"queued", "started", "retrying"})
+		So(tracked.retryAt, ShouldHappenAfter, now)
+
+		Convey("Failed job should be retried after the backoff", func() {
+			_, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeFalse)
+			now = tracked.retryAt
+			retried, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeTrue)
+			So(retried.ID, ShouldEqual, id)
+			So(retried.Attempt, ShouldEqual, 1)
+		})
+		Convey("Job should be dead-lettered once its retries are exhausted", func() {
+			now = tracked.retryAt
+			retried, _, _ := q.Pop(time.Minute)
+			perform(context.Background(), retried)
+			So(tracked.states[len(tracked.states)-1], ShouldEqual, "finished")
+			So(tracked.err, ShouldNotBeNil)
+			now = now.Add(time.Hour)
+			_, ok, _ := q.Pop(time.Minute)
+			So(ok, ShouldBeFalse)
+			dead, err := DeadJobs()
+			So(err, ShouldBeNil)
+			So(len(dead), ShouldEqual, 1)
+			So(dead[0].Job.ID, ShouldEqual, id)
+			So(dead[0].ErrorKind, ShouldEqual, errors.PlatformUnavailable)
+
+			Convey("Revived job should be queued with its retries renewed", func() {
+				So(ReviveDeadJob(id), ShouldBeNil)
+				So(ReviveDeadJob(id), ShouldEqual, errors.DeadJobNotFoundError)
+				revived, ok, _ := q.Pop(time.Minute)
+				So(ok, ShouldBeTrue)
+				So(revived.ID, ShouldEqual, id)
+				So(revived.Attempt, ShouldEqual, 0)
+			})
+			Convey("Purged jobs should be removed", func() {
+				So(PurgeDeadJobs("unknown"), ShouldEqual, errors.DeadJobNotFoundError)
+				So(PurgeDeadJobs(id), ShouldBeNil)
+				dead, _ := DeadJobs()
+				So(dead, ShouldBeEmpty)
+			})
+		})
+		Convey("Permanent failures should not be retried", func() {
+			RegisterHandler("failing", func(bson.ObjectId, string, context.Context) error {
+				return errors.UserNotFoundError
+			})
+			now = tracked.retryAt
+			retried, _, _ := q.Pop(time.Minute)
+			perform(context.Background(), retried)
+			So(tracked.err, ShouldEqual, errors.UserNotFoundError)
+			dead, _ := DeadJobs()
+			So(dead, ShouldBeEmpty)
+		})
+	})
+}